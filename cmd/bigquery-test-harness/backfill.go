@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	bigquery2 "github.com/bradmwilliams/jira-migration/pkg/bigquery"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog/v2"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type BackfillOptions struct {
+	*Options
+
+	// Start and End are the dates, as YYYY-MM-DD, between which issues are backfilled. End defaults to now.
+	Start string
+	End   string
+
+	Window      time.Duration
+	WindowField string
+	Workers     int
+	PageSize    int
+
+	// CheckpointDir holds the windows that were written for every feed. It defaults to --state-dir.
+	CheckpointDir string
+}
+
+func newBackfillCommand(parent *Options) *cobra.Command {
+	opt := &BackfillOptions{
+		Options:     parent,
+		Window:      7 * 24 * time.Hour,
+		WindowField: bigquery2.BackfillFieldCreated,
+		Workers:     4,
+		PageSize:    100,
+	}
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Load the history of every feed in date windows, resuming from the last checkpoint",
+		Run: func(cmd *cobra.Command, arguments []string) {
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+
+			if err := opt.Validate(ctx); err != nil {
+				klog.Exitf("error: %v", err)
+			}
+			if err := opt.Run(ctx); err != nil {
+				klog.Exitf("error: %v", err)
+			}
+		},
+	}
+	opt.AddFlags(cmd.Flags())
+	return cmd
+}
+
+func (o *BackfillOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Start, "start", o.Start, "The date, as YYYY-MM-DD, of the first window to backfill.")
+	fs.StringVar(&o.End, "end", o.End, "The date, as YYYY-MM-DD, at which the last window ends. Defaults to now.")
+	fs.DurationVar(&o.Window, "window", o.Window, "The length of the date windows that the query of every feed is split into.")
+	fs.StringVar(&o.WindowField, "window-field", o.WindowField, "The date field that windows are split on, \"created\" or \"updated\".")
+	fs.IntVar(&o.Workers, "workers", o.Workers, "The number of windows fetched at the same time.")
+	fs.IntVar(&o.PageSize, "page-size", o.PageSize, "The number of issues requested per search.")
+	fs.StringVar(&o.CheckpointDir, "checkpoint-dir", o.CheckpointDir, "A directory to remember the written windows of every feed in, so that an interrupted backfill resumes where it stopped. Defaults to --state-dir.")
+}
+
+func (o *BackfillOptions) Validate(ctx context.Context) error {
+	if err := o.Options.Validate(ctx); err != nil {
+		return err
+	}
+	if len(o.Start) == 0 {
+		return errors.New("--start must be set")
+	}
+	if _, _, err := o.dates(); err != nil {
+		return err
+	}
+	if o.Window < time.Minute {
+		return errors.New("--window must be at least a minute")
+	}
+	switch o.WindowField {
+	case bigquery2.BackfillFieldCreated, bigquery2.BackfillFieldUpdated:
+	default:
+		return fmt.Errorf("--window-field %q is not supported, must be %q or %q", o.WindowField, bigquery2.BackfillFieldCreated, bigquery2.BackfillFieldUpdated)
+	}
+	if o.Workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+	if o.PageSize < 1 {
+		return errors.New("--page-size must be at least 1")
+	}
+	if len(o.CheckpointDir) == 0 {
+		o.CheckpointDir = o.StateDir
+	}
+	if len(o.CheckpointDir) == 0 {
+		return errors.New("--checkpoint-dir or --state-dir must be set to resume an interrupted backfill")
+	}
+	return nil
+}
+
+// dates parses --start and --end.
+func (o *BackfillOptions) dates() (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", o.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("--start %q must be a date of the form YYYY-MM-DD", o.Start)
+	}
+	end := time.Now()
+	if len(o.End) > 0 {
+		if end, err = time.Parse("2006-01-02", o.End); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("--end %q must be a date of the form YYYY-MM-DD", o.End)
+		}
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--start %s must be before --end %s", o.Start, end.Format("2006-01-02"))
+	}
+	return start, end, nil
+}
+
+func (o *BackfillOptions) Run(ctx context.Context) error {
+	config, err := o.configWithSearch()
+	if err != nil {
+		return err
+	}
+
+	jc, err := o.jira.Client()
+	if err != nil {
+		klog.Fatalf("Unable to create jira client: %v", err)
+	}
+	backend, err := helpers.NewSearchBackend(ctx, jc, config.APIVersion)
+	if err != nil {
+		return err
+	}
+	sink, err := o.Sink()
+	if err != nil {
+		klog.Fatalf("Unable to configure sink: %v", err)
+	}
+	defer sink.Close()
+
+	go o.logRateLimits(ctx, time.Minute)
+
+	catalog, err := helpers.GetFieldCatalog(ctx, jc)
+	if err != nil {
+		klog.Warningf("Custom fields will not be named: %v", err)
+	}
+	for _, feed := range config.Feeds {
+		converter, err := o.Converter(config, feed, backend)
+		if err != nil {
+			return err
+		}
+		converter.Catalog = catalog
+		if err := o.backfill(ctx, backend, sink, converter, feed); err != nil {
+			return fmt.Errorf("unable to backfill feed %s: %v", feed.Name, err)
+		}
+	}
+	return nil
+}
+
+// backfill fetches the windows of the feed that were not written by a previous run with a pool of workers, and
+// checkpoints every window once its rows are written. A window that fails is not checkpointed and is fetched again by
+// the next run.
+func (o *BackfillOptions) backfill(ctx context.Context, backend helpers.SearchBackend, sink bigquery2.TicketSink, converter *bigquery2.Converter, feed bigquery2.Feed) error {
+	checkpoint, err := bigquery2.NewBackfillCheckpoint(filepath.Join(o.CheckpointDir, feed.Name+"-backfill.json"), feed.JQL, o.WindowField)
+	if err != nil {
+		return err
+	}
+	start, end, err := o.dates()
+	if err != nil {
+		return err
+	}
+	windows := bigquery2.SplitBackfillWindows(start, end, o.Window)
+	remaining := checkpoint.Remaining(windows)
+	klog.Infof("Backfilling %d of %d windows of feed %s", len(remaining), len(windows), feed.Name)
+
+	// rows are written one window at a time, as the change state is saved to a single file
+	var lock sync.Mutex
+	completed := len(windows) - len(remaining)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(o.Workers)
+	for _, window := range remaining {
+		window := window
+		g.Go(func() error {
+			rows, err := o.fetchWindow(ctx, backend, converter, feed, window)
+			if err != nil {
+				return fmt.Errorf("unable to fetch window %s: %v", window, err)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if err := o.write(ctx, sink, converter, feed, rows); err != nil {
+				return fmt.Errorf("unable to write window %s: %v", window, err)
+			}
+			if o.DryRun {
+				return nil
+			}
+			if err := checkpoint.Complete(window); err != nil {
+				return fmt.Errorf("unable to save backfill checkpoint: %v", err)
+			}
+			completed++
+			klog.V(2).Infof("Backfilled window %s of feed %s, %d/%d windows done", window, feed.Name, completed, len(windows))
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if report := converter.Scrubber.Report(); len(report) > 0 {
+		klog.Infof("Scrubbed %s from feed %s", report, feed.Name)
+	}
+	return nil
+}
+
+// fetchWindow searches every page of issues in the window, with the fields of an export, and converts them into rows.
+func (o *BackfillOptions) fetchWindow(ctx context.Context, backend helpers.SearchBackend, converter *bigquery2.Converter, feed bigquery2.Feed, window bigquery2.BackfillWindow) (*bigquery2.Rows, error) {
+	jql := bigquery2.BackfillJQL(feed.JQL, o.WindowField, window)
+	all := &bigquery2.Rows{}
+	it := helpers.NewSearchIterator(backend, jql, &jiraBaseClient.SearchOptions{Fields: exportFields, MaxResults: o.PageSize}, func(fetched, total int) {
+		klog.V(4).Infof("Fetched %d/%d issues of window %s of feed %s", fetched, total, window, feed.Name)
+	})
+	for it.Next(ctx) {
+		rows, err := o.convert(ctx, backend, converter, it.Page())
+		if err != nil {
+			return nil, err
+		}
+		all.Tickets = append(all.Tickets, rows.Tickets...)
+		all.Changelog = append(all.Changelog, rows.Changelog...)
+		all.Metrics = append(all.Metrics, rows.Metrics...)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
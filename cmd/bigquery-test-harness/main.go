@@ -8,6 +8,7 @@ import (
 	"fmt"
 	jiraBaseClient "github.com/andygrunwald/go-jira"
 	bigquery2 "github.com/bradmwilliams/jira-migration/pkg/bigquery"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
 	"github.com/openshift/ci-search/jira"
 	"github.com/openshift/ci-search/pkg/bigquery"
 
@@ -15,19 +16,37 @@ import (
 	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
 	"os"
-	"sigs.k8s.io/prow/prow/flagutil"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 type Options struct {
 	DryRun bool
 
-	jira flagutil.JiraOptions
+	jira       helpers.JiraOptions
+	JiraSearch string
 
 	// BigQuery Options
 	GoogleProjectID                    string
 	GoogleServiceAccountCredentialFile string
 	BigQueryRefreshInterval            time.Duration
+	BigQueryDataset                    string
+	BigQueryTable                      string
+
+	// Sinks lists where rows are written, either "bigquery", "ndjson:<directory>", "sqlite:<directory>" or
+	// "parquet:<directory>".
+	Sinks []string
+
+	// ConfigPath points to a file of named feeds. When it is not set, a single feed is built from the flags.
+	ConfigPath string
+
+	// StateDir holds the last written change time of every issue per feed. When it is not set, the state is only
+	// kept for the lifetime of the process.
+	StateDir string
+
+	// ScrubPatterns are additional name=regex secret detectors, applied after the scrubPatterns of the config.
+	ScrubPatterns []string
 }
 
 func main() {
@@ -38,6 +57,9 @@ func main() {
 
 	opt := &Options{
 		BigQueryRefreshInterval: 1 * time.Minute,
+		BigQueryDataset:         "jira_data",
+		BigQueryTable:           "tickets",
+		Sinks:                   []string{"bigquery"},
 	}
 	cmd := &cobra.Command{
 		Run: func(cmd *cobra.Command, arguments []string) {
@@ -51,7 +73,7 @@ func main() {
 			}
 		},
 	}
-	flagset := cmd.Flags()
+	flagset := cmd.PersistentFlags()
 
 	goFlagSet := flag.NewFlagSet("prowflags", flag.ContinueOnError)
 	opt.jira.AddFlags(goFlagSet)
@@ -61,6 +83,8 @@ func main() {
 
 	opt.AddFlags(flagset)
 
+	cmd.AddCommand(newSyncCommand(opt), newSchemaCommand(opt), newBackfillCommand(opt))
+
 	if err := cmd.Execute(); err != nil {
 		klog.Exitf("error: %v", err)
 	}
@@ -69,11 +93,44 @@ func main() {
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.GoogleProjectID, "google-project-id", os.Getenv("GOOGLE_PROJECT_ID"), "Google project name.")
 	fs.StringVar(&o.GoogleServiceAccountCredentialFile, "google-service-account-credential-file", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "location of a credential file described by https://cloud.google.com/docs/authentication/production")
-	fs.DurationVar(&o.BigQueryRefreshInterval, "bigquery-refresh-interval", o.BigQueryRefreshInterval, "How often to push comments into BigQuery. Defaults to 15 minutes.")
+	fs.DurationVar(&o.BigQueryRefreshInterval, "bigquery-refresh-interval", o.BigQueryRefreshInterval, "How often to push changed issues into BigQuery, for the feeds that do not set a refreshInterval.")
+	fs.StringVar(&o.BigQueryDataset, "bigquery-dataset", o.BigQueryDataset, "The BigQuery dataset to write to when --config is not set.")
+	fs.StringVar(&o.BigQueryTable, "bigquery-table", o.BigQueryTable, "The BigQuery table to write tickets to when --config is not set. Changelog and metrics rows are written to the tables suffixed with _changelog and _metrics.")
+	fs.StringVar(&o.JiraSearch, "jira-search", o.JiraSearch, "A JQL query to search for issues when --config is not set.")
+	fs.StringVar(&o.ConfigPath, "config", o.ConfigPath, "A file of named feeds that each map a JQL query to a BigQuery dataset and table.")
+	fs.StringSliceVar(&o.Sinks, "sink", o.Sinks, "Where to write rows to: \"bigquery\", \"ndjson:<directory>\", \"sqlite:<directory>\" or \"parquet:<directory>\". May be repeated to write to several sinks at once.")
+	fs.StringVar(&o.StateDir, "state-dir", o.StateDir, "A directory to remember the last written change time of every issue in, so that unchanged issues are skipped across restarts.")
+	fs.StringArrayVar(&o.ScrubPatterns, "scrub-pattern", o.ScrubPatterns, "A name=regex pattern of text to redact from descriptions, comments and logged issues, in addition to the built-in secret and email detectors. May be repeated.")
 	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "Perform no actions.")
 }
 
 func (o *Options) Validate(ctx context.Context) error {
+	if err := o.jira.Validate(); err != nil {
+		return err
+	}
+	if len(o.Sinks) == 0 {
+		return errors.New("at least one --sink must be set")
+	}
+	for _, sink := range o.Sinks {
+		switch {
+		case sink == "bigquery":
+			if err := o.validateBigQuery(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(sink, "ndjson:") && len(strings.TrimPrefix(sink, "ndjson:")) > 0:
+		case strings.HasPrefix(sink, "sqlite:") && len(strings.TrimPrefix(sink, "sqlite:")) > 0:
+			if !bigquery2.SQLiteSupported {
+				return fmt.Errorf("--sink %q is not supported by this binary, which was built without cgo", sink)
+			}
+		case strings.HasPrefix(sink, "parquet:") && len(strings.TrimPrefix(sink, "parquet:")) > 0:
+		default:
+			return fmt.Errorf("--sink %q is not supported, must be \"bigquery\", \"ndjson:<directory>\", \"sqlite:<directory>\" or \"parquet:<directory>\"", sink)
+		}
+	}
+	return nil
+}
+
+func (o *Options) validateBigQuery() error {
 	if len(o.GoogleProjectID) == 0 {
 		return errors.New("--google-project-id flag must be set")
 	}
@@ -83,61 +140,261 @@ func (o *Options) Validate(ctx context.Context) error {
 	return nil
 }
 
-func (o *Options) Run() error {
-	c, err := o.jira.Client()
+// Sink creates the sink that every configured --sink is written to.
+func (o *Options) Sink() (bigquery2.TicketSink, error) {
+	var sinks []bigquery2.TicketSink
+	for _, sink := range o.Sinks {
+		switch {
+		case sink == "bigquery":
+			bqc, err := bigquery.NewBigQueryClient(o.GoogleProjectID, o.GoogleServiceAccountCredentialFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to configure bigquery client: %v", err)
+			}
+			sinks = append(sinks, bigquery2.NewBigQuerySink(bqc))
+		case strings.HasPrefix(sink, "ndjson:"):
+			sinks = append(sinks, bigquery2.NewNDJSONSink(strings.TrimPrefix(sink, "ndjson:")))
+		case strings.HasPrefix(sink, "sqlite:"):
+			sinks = append(sinks, bigquery2.NewSQLiteSink(strings.TrimPrefix(sink, "sqlite:")))
+		case strings.HasPrefix(sink, "parquet:"):
+			sinks = append(sinks, bigquery2.NewParquetSink(strings.TrimPrefix(sink, "parquet:")))
+		}
+	}
+	return bigquery2.NewMultiSink(sinks...), nil
+}
+
+// Config returns the configured feeds, either loaded from --config or built from the individual flags.
+func (o *Options) Config() (*bigquery2.Config, error) {
+	config := &bigquery2.Config{}
+	if len(o.ConfigPath) > 0 {
+		var err error
+		if config, err = bigquery2.LoadConfig(o.ConfigPath); err != nil {
+			return nil, err
+		}
+	} else {
+		config.Feeds = []bigquery2.Feed{{
+			Name:    "default",
+			JQL:     o.JiraSearch,
+			Dataset: o.BigQueryDataset,
+			Table:   o.BigQueryTable,
+		}}
+	}
+	for _, pattern := range o.ScrubPatterns {
+		name, re, ok := strings.Cut(pattern, "=")
+		if !ok {
+			return nil, fmt.Errorf("--scrub-pattern %q must be of the form name=regex", pattern)
+		}
+		config.ScrubPatterns = append(config.ScrubPatterns, helpers.ScrubPattern{Name: name, Pattern: re})
+	}
+	config.Default(o.BigQueryRefreshInterval)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Converter creates the converter of the feed, with the change state loaded from --state-dir and the redaction policy
+// and scrub patterns of the config.
+func (o *Options) Converter(config *bigquery2.Config, feed bigquery2.Feed, backend helpers.SearchBackend) (*bigquery2.Converter, error) {
+	path := ""
+	if len(o.StateDir) > 0 {
+		path = filepath.Join(o.StateDir, feed.Name+".json")
+	}
+	state, err := bigquery2.NewChangeStore(path)
+	if err != nil {
+		return nil, err
+	}
+	scrubber, err := helpers.NewScrubber(config.ScrubPatterns)
+	if err != nil {
+		return nil, err
+	}
+	return &bigquery2.Converter{
+		State:      state,
+		Redaction:  config.Redaction,
+		Scrubber:   scrubber,
+		APIVersion: backend.APIVersion(),
+	}, nil
+}
+
+// configWithSearch returns the configuration and verifies that each of its feeds has a JQL query.
+func (o *Options) configWithSearch() (*bigquery2.Config, error) {
+	config, err := o.Config()
 	if err != nil {
-		klog.Fatalf("Unable to create jira client: %w", err)
+		return nil, err
+	}
+	for _, feed := range config.Feeds {
+		if len(feed.JQL) == 0 {
+			return nil, fmt.Errorf("feed %s must have a JQL query, set --jira-search or the jql of the feed", feed.Name)
+		}
 	}
+	return config, nil
+}
 
-	issue, err := c.GetIssue("OCPBUGS-35865")
+func (o *Options) Run() error {
+	config, err := o.configWithSearch()
 	if err != nil {
-		klog.Errorf("Unable to get jira issue: %w", err)
 		return err
 	}
 
-	b, err := json.MarshalIndent(issue, "", "    ")
+	c, err := o.jira.Client()
 	if err != nil {
-		klog.Errorf("unable to marshal Jira Issue: %v", err)
-		return nil
+		klog.Fatalf("Unable to create jira client: %v", err)
 	}
-	klog.V(2).Infof("Retrieved issue:\n%s", string(b))
 
-	bqc, err := bigquery.NewBigQueryClient(o.GoogleProjectID, o.GoogleServiceAccountCredentialFile)
+	sink, err := o.Sink()
 	if err != nil {
-		klog.Fatalf("Unable to configure bigquery client: %v", err)
+		klog.Fatalf("Unable to configure sink: %v", err)
 	}
+	defer sink.Close()
 
-	var tickets []*bigquery2.Ticket
-	timestamp := time.Now()
+	ctx := context.TODO()
+	backend, err := helpers.NewSearchBackend(ctx, c, config.APIVersion)
+	if err != nil {
+		return err
+	}
+	catalog, err := helpers.GetFieldCatalog(ctx, c)
+	if err != nil {
+		klog.Warningf("Custom fields will not be named: %v", err)
+	}
+	for _, feed := range config.Feeds {
+		converter, err := o.Converter(config, feed, backend)
+		if err != nil {
+			return err
+		}
+		converter.Catalog = catalog
+		if err := o.export(ctx, backend, sink, converter, feed); err != nil {
+			return fmt.Errorf("unable to export feed %s: %v", feed.Name, err)
+		}
+	}
+	if state := o.jira.RateLimiter().State(); state.Throttled > 0 {
+		klog.Infof("Jira throttled %d requests", state.Throttled)
+	}
+	return nil
+}
 
-	updated := jira.NewIssueComments(issue.ID, issue.Fields.Comments)
-	updated.Info = jiraBaseClient.Issue{
-		ID:     issue.ID,
-		Key:    issue.Key,
-		Fields: issue.Fields,
+// logRateLimits reports the state of the rate limiter shared by every Jira client whenever Jira throttled requests
+// since the last report, until the context is done.
+func (o *Options) logRateLimits(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	reported := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		state := o.jira.RateLimiter().State()
+		if state.Throttled == reported {
+			continue
+		}
+		reported = state.Throttled
+		if state.BackingOff(time.Now()) {
+			klog.Infof("Jira throttled %d requests, backing off until %s (%d of %d requests remaining, %.2f requests/s)", state.Throttled, state.BackoffUntil.Format(time.RFC3339), state.Remaining, state.Limit, float64(state.Rate))
+		} else {
+			klog.Infof("Jira throttled %d requests (%d of %d requests remaining, %.2f requests/s)", state.Throttled, state.Remaining, state.Limit, float64(state.Rate))
+		}
 	}
-	updated.RefreshTime = timestamp
+}
 
-	tickets = append(tickets, bigquery2.ConvertToTicket(updated, timestamp))
+// exportFields are the fields of the issues searched by an export or a backfill.
+var exportFields = []string{"*all"}
 
-	b, err = json.MarshalIndent(tickets, "", "    ")
-	if err != nil {
-		klog.Errorf("unable to marshal tickets: %v", err)
-		return nil
+func (o *Options) export(ctx context.Context, backend helpers.SearchBackend, sink bigquery2.TicketSink, converter *bigquery2.Converter, feed bigquery2.Feed) error {
+	if fields := bigquery2.ConvertToFieldCatalogEntries(converter.Catalog, time.Now()); len(fields) > 0 && !o.DryRun {
+		if err := sink.WriteRows(ctx, feed.Dataset, feed.FieldCatalogTable, fields); err != nil {
+			return fmt.Errorf("unable to write field catalog: %v", err)
+		}
+	}
+	it := helpers.NewSearchIterator(backend, feed.JQL, &jiraBaseClient.SearchOptions{Fields: exportFields}, func(fetched, total int) {
+		klog.V(2).Infof("Fetched %d/%d issues of feed %s", fetched, total, feed.Name)
+	})
+	for it.Next(ctx) {
+		rows, err := o.convert(ctx, backend, converter, it.Page())
+		if err != nil {
+			return err
+		}
+		if err := o.write(ctx, sink, converter, feed, rows); err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		klog.Errorf("Unable to search jira issues: %v", err)
+		return err
+	}
+	if report := converter.Scrubber.Report(); len(report) > 0 {
+		klog.Infof("Scrubbed %s from feed %s", report, feed.Name)
 	}
-	klog.V(2).Infof("Tickets:\n%s", string(b))
 
-	if len(tickets) > 0 {
-		if o.DryRun {
-			klog.Infof("[Dry Run] Syncing %d issues to bigquery", len(tickets))
-		} else {
-			klog.V(5).Infof("Syncing %d issues to bigquery", len(tickets))
-			err := bqc.WriteRows(context.TODO(), bigquery2.BigqueryDatasetId, bigquery2.BigqueryTableId, tickets)
+	return nil
+}
+
+// convert fetches the changelogs, comments and remote links of a page of issues and converts them into rows.
+func (o *Options) convert(ctx context.Context, backend helpers.SearchBackend, converter *bigquery2.Converter, page helpers.SearchPage) (*bigquery2.Rows, error) {
+	timestamp := time.Now()
+
+	var ids []string
+	var issues []*jira.IssueComments
+	for _, issue := range page.Issues {
+		updated := jira.NewIssueComments(issue.ID, issue.Fields.Comments)
+		updated.Info = jiraBaseClient.Issue{
+			ID:     issue.ID,
+			Key:    issue.Key,
+			Fields: issue.Fields,
+		}
+		updated.RefreshTime = timestamp
+		issues = append(issues, updated)
+		ids = append(ids, issue.ID)
+
+		// only log what the redaction policy allows to be exported, with secrets scrubbed
+		if redacted, ok := converter.Redaction.RedactIssue(updated); ok && klog.V(2).Enabled() {
+			b, err := converter.Scrubber.MarshalIndent(redacted.Info)
 			if err != nil {
-				return fmt.Errorf("unable to write to bigquery: %v", err)
+				klog.Errorf("unable to marshal Jira Issue: %v", err)
+				continue
 			}
+			klog.V(2).Infof("Retrieved issue:\n%s", b)
 		}
 	}
+	changelogs, err := helpers.IssueChangelogs(ctx, backend, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get issue changelogs: %v", err)
+	}
+	comments, err := helpers.PageComments(ctx, backend, page)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get issue comments: %v", err)
+	}
+	issues = bigquery2.WithComments(issues, comments)
+	remoteLinks, err := helpers.IssueRemoteLinks(ctx, backend, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get issue remote links: %v", err)
+	}
+	return converter.Convert(issues, changelogs, remoteLinks, timestamp), nil
+}
 
+// write writes the rows to the sink and records the written tickets in the change state of the converter.
+func (o *Options) write(ctx context.Context, sink bigquery2.TicketSink, converter *bigquery2.Converter, feed bigquery2.Feed, rows *bigquery2.Rows) error {
+	if klog.V(2).Enabled() {
+		b, err := json.MarshalIndent(rows.Tickets, "", "    ")
+		if err != nil {
+			klog.Errorf("unable to marshal tickets: %v", err)
+		} else {
+			klog.V(2).Infof("Tickets:\n%s", string(b))
+		}
+	}
+
+	if len(rows.Tickets) == 0 {
+		return nil
+	}
+	if o.DryRun {
+		klog.Infof("[Dry Run] Syncing %d issues and %d changelog entries", len(rows.Tickets), len(rows.Changelog))
+		return nil
+	}
+	klog.V(5).Infof("Syncing %d issues and %d changelog entries", len(rows.Tickets), len(rows.Changelog))
+	if err := rows.Write(ctx, sink, feed); err != nil {
+		return err
+	}
+	if err := rows.Record(converter.State); err != nil {
+		return fmt.Errorf("unable to save change state: %v", err)
+	}
 	return nil
 }
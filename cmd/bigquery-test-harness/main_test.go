@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	bigquery2 "github.com/bradmwilliams/jira-migration/pkg/bigquery"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/bradmwilliams/jira-migration/pkg/jira/fakejira"
+	"github.com/bradmwilliams/jira-migration/pkg/parquet"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deployments are the deployments of Jira that every command is tested against.
+var deployments = []string{"Server", "Cloud"}
+
+// newFakeJira serves the dataset of fakejira as the deployment, whose clock is the clock of the test.
+func newFakeJira(t *testing.T, deployment string) (*fakejira.Server, string) {
+	t.Helper()
+	dataset, err := fakejira.LoadDataset("../../pkg/jira/fakejira/testdata/dataset.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataset.ServerInfo.DeploymentType = deployment
+	fake, err := fakejira.New(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// comments and changelogs beyond two are paged, as they are beyond a hundred on Jira
+	fake.EmbeddedLimit = 2
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server.URL
+}
+
+// newTestOptions returns the options of the command for the endpoint with the defaults of main, writing NDJSON to a
+// temporary directory.
+func newTestOptions(t *testing.T, endpoint string) (*Options, string) {
+	t.Helper()
+	dir := t.TempDir()
+	opt := &Options{
+		BigQueryRefreshInterval: 1 * time.Minute,
+		BigQueryDataset:         "jira_data",
+		BigQueryTable:           "tickets",
+		JiraSearch:              "project=OCPBUGS",
+		Sinks:                   []string{"ndjson:" + filepath.Join(dir, "rows")},
+		StateDir:                filepath.Join(dir, "state"),
+	}
+	opt.jira.Endpoint = endpoint
+	opt.jira.MaxRetries = 1
+	if err := opt.Validate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return opt, filepath.Join(dir, "rows")
+}
+
+// readRows decodes the rows written to the NDJSON file of the table, or none if it does not exist.
+func readRows(t *testing.T, dir, table string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, "jira_data", table+".ndjson"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 10*1024*1024)
+	for scanner.Scan() {
+		row := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+// ticketKeys returns the sorted keys of the ticket rows.
+func ticketKeys(rows []map[string]interface{}) []string {
+	var keys []string
+	for _, row := range rows {
+		keys = append(keys, row["issue"].(map[string]interface{})["key"].(string))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestExport(t *testing.T) {
+	for _, deployment := range deployments {
+		t.Run(deployment, func(t *testing.T) {
+			fake, endpoint := newFakeJira(t, deployment)
+			opt, dir := newTestOptions(t, endpoint)
+			if err := opt.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			// the issue with a security level is dropped by the default redaction policy
+			tickets := readRows(t, dir, "tickets")
+			if actual, expected := ticketKeys(tickets), []string{"OCPBUGS-35865", "OCPBUGS-35866", "OCPBUGS-35867"}; !reflect.DeepEqual(actual, expected) {
+				t.Fatalf("expected tickets %v, got %v", expected, actual)
+			}
+			var ticket map[string]interface{}
+			for _, row := range tickets {
+				if row["issue"].(map[string]interface{})["key"] == "OCPBUGS-35865" {
+					ticket = row
+				}
+			}
+			if comments := ticket["comments"].([]interface{}); len(comments) != 3 {
+				t.Errorf("expected the comments beyond the embedded ones to be paged, got %d", len(comments))
+			}
+			if description := ticket["description"].(string); strings.Contains(description, "jdoe@example.com") || !strings.Contains(description, "<redacted:email>") {
+				t.Errorf("expected the email address to be scrubbed from the description, got %s", description)
+			}
+			if pulls := ticket["pull_requests"].([]interface{}); len(pulls) == 0 {
+				t.Errorf("expected the pull request of the remote link, got none")
+			}
+			if ticket["panic_signature"] == nil {
+				t.Errorf("expected the panic of the description to have a signature")
+			}
+			if changelog := readRows(t, dir, "tickets_changelog"); len(changelog) != 5 {
+				t.Errorf("expected every changelog item of the exported tickets, got %d", len(changelog))
+			}
+			if fields := readRows(t, dir, "tickets_fields"); len(fields) != 5 {
+				t.Errorf("expected the field catalog, got %d fields", len(fields))
+			}
+
+			// a second export skips the tickets that have not changed
+			requests := len(fake.Requests())
+			if err := opt.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if tickets := readRows(t, dir, "tickets"); len(tickets) != 3 {
+				t.Errorf("expected unchanged tickets not to be written again, got %d rows", len(tickets))
+			}
+			if len(fake.Requests()) == requests {
+				t.Errorf("expected the second export to search Jira again")
+			}
+		})
+	}
+}
+
+func TestExportToSeveralSinks(t *testing.T) {
+	_, endpoint := newFakeJira(t, "Server")
+	opt, dir := newTestOptions(t, endpoint)
+	files := filepath.Join(filepath.Dir(dir), "parquet")
+	databases := filepath.Join(filepath.Dir(dir), "databases")
+	opt.Sinks = append(opt.Sinks, "parquet:"+files, "sqlite:"+databases)
+	if !bigquery2.SQLiteSupported {
+		if err := opt.Validate(context.Background()); err == nil {
+			t.Fatal("expected the SQLite sink to be refused without cgo")
+		}
+		opt.Sinks = opt.Sinks[:len(opt.Sinks)-1]
+	}
+	if err := opt.Validate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := opt.Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := ticketKeys(readRows(t, dir, "tickets"))
+
+	paths, err := filepath.Glob(filepath.Join(files, "jira_data", "tickets", "*.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns, rows, err := parquet.Read(data)
+		if err != nil {
+			t.Fatalf("unable to read %s: %v", path, err)
+		}
+		for i, column := range columns {
+			if column.Name != "issue_key" {
+				continue
+			}
+			for _, row := range rows {
+				keys = append(keys, row[i].(string))
+			}
+		}
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected the tickets %v written to NDJSON, got %v in Parquet", expected, keys)
+	}
+	if !bigquery2.SQLiteSupported {
+		return
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(databases, "jira_data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	result, err := db.Query(`SELECT issue_key FROM tickets ORDER BY issue_key`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Close()
+	keys = nil
+	for result.Next() {
+		var key string
+		if err := result.Scan(&key); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected the tickets %v written to NDJSON, got %v in SQLite", expected, keys)
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	for _, deployment := range deployments {
+		t.Run(deployment, func(t *testing.T) {
+			_, endpoint := newFakeJira(t, deployment)
+			parent, dir := newTestOptions(t, endpoint)
+			opt := &BackfillOptions{
+				Options:     parent,
+				Start:       "2024-06-01",
+				End:         "2024-07-01",
+				Window:      7 * 24 * time.Hour,
+				WindowField: "created",
+				Workers:     2,
+				PageSize:    1,
+			}
+			if err := opt.Validate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := opt.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if actual, expected := ticketKeys(readRows(t, dir, "tickets")), []string{"OCPBUGS-35865", "OCPBUGS-35866", "OCPBUGS-35867"}; !reflect.DeepEqual(actual, expected) {
+				t.Fatalf("expected tickets %v, got %v", expected, actual)
+			}
+			data, err := os.ReadFile(filepath.Join(opt.CheckpointDir, "default-backfill.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var checkpoint struct {
+				Completed []json.RawMessage `json:"completed"`
+			}
+			if err := json.Unmarshal(data, &checkpoint); err != nil {
+				t.Fatal(err)
+			}
+			if len(checkpoint.Completed) != 5 {
+				t.Errorf("expected every window to be checkpointed, got %v", checkpoint.Completed)
+			}
+
+			// a completed backfill fetches nothing when it is run again
+			if err := opt.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if tickets := readRows(t, dir, "tickets"); len(tickets) != 3 {
+				t.Errorf("expected no tickets to be written again, got %d rows", len(tickets))
+			}
+		})
+	}
+}
+
+// startSync runs the sync command with the options until the end of the test.
+func startSync(t *testing.T, parent *Options) {
+	t.Helper()
+	parent.BigQueryRefreshInterval = 50 * time.Millisecond
+	opt := &SyncOptions{
+		Options:                parent,
+		JiraRefreshInterval:    50 * time.Millisecond,
+		JiraMaxWatchInterval:   time.Hour,
+		JiraResyncInterval:     time.Hour,
+		JiraWatchOverlap:       time.Minute,
+		CommentRefreshInterval: time.Hour,
+	}
+	if err := opt.Validate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- opt.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// waitForTickets waits until the ticket rows written to the directory meet the condition.
+func waitForTickets(t *testing.T, fake *fakejira.Server, dir, description string, condition func([]map[string]interface{}) bool) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for !condition(readRows(t, dir, "tickets")) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s, got %v\n%s", description, ticketKeys(readRows(t, dir, "tickets")), strings.Join(fake.Requests(), "\n"))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSync(t *testing.T) {
+	for _, deployment := range deployments {
+		t.Run(deployment, func(t *testing.T) {
+			fake, endpoint := newFakeJira(t, deployment)
+			opt, dir := newTestOptions(t, endpoint)
+			startSync(t, opt)
+			waitForTickets(t, fake, dir, "the listed tickets", func(rows []map[string]interface{}) bool {
+				return reflect.DeepEqual(ticketKeys(rows), []string{"OCPBUGS-35865", "OCPBUGS-35866", "OCPBUGS-35867"})
+			})
+
+			// a change made while the sync runs is picked up by the watch
+			if err := fake.UpdateIssue("OCPBUGS-35866", map[string]interface{}{"summary": "Console shows a stale route"}); err != nil {
+				t.Fatal(err)
+			}
+			waitForTickets(t, fake, dir, "the changed ticket", func(rows []map[string]interface{}) bool {
+				for _, row := range rows {
+					if row["summary"] == "Console shows a stale route" {
+						return true
+					}
+				}
+				return false
+			})
+		})
+	}
+}
+
+func TestSyncRefusesToRecordCassette(t *testing.T) {
+	_, endpoint := newFakeJira(t, "Server")
+	parent, _ := newTestOptions(t, endpoint)
+	parent.jira.CassettePath = filepath.Join(t.TempDir(), "cassette.jsonl")
+	parent.jira.CassetteMode = string(helpers.CassetteRecord)
+	opt := &SyncOptions{Options: parent}
+	if err := opt.Validate(context.Background()); err == nil {
+		t.Errorf("expected recording a cassette of the sync to be refused")
+	}
+	parent.jira.CassetteMode = string(helpers.CassetteReplay)
+	if err := opt.Validate(context.Background()); err != nil {
+		t.Errorf("expected replaying a cassette in the sync to be allowed, got %v", err)
+	}
+}
+
+// TestSyncSecurityLevelPlaceholder syncs the issue with a security level as a placeholder, as the redaction policy of
+// the config asks, rather than dropping it with the issues that ci-search filters out.
+func TestSyncSecurityLevelPlaceholder(t *testing.T) {
+	fake, endpoint := newFakeJira(t, "Server")
+	opt, dir := newTestOptions(t, endpoint)
+	opt.ConfigPath = filepath.Join(t.TempDir(), "config.yaml")
+	config := `feeds:
+- name: ocpbugs
+  jql: project=OCPBUGS
+  dataset: jira_data
+  table: tickets
+redaction:
+  default: placeholder
+  securityLevel: placeholder
+`
+	if err := os.WriteFile(opt.ConfigPath, []byte(config), 0640); err != nil {
+		t.Fatal(err)
+	}
+	startSync(t, opt)
+	waitForTickets(t, fake, dir, "the listed tickets", func(rows []map[string]interface{}) bool {
+		return reflect.DeepEqual(ticketKeys(rows), []string{"OCPBUGS-35865", "OCPBUGS-35866", "OCPBUGS-35867", "OCPBUGS-35868"})
+	})
+	for _, row := range readRows(t, dir, "tickets") {
+		if row["issue"].(map[string]interface{})["key"] != "OCPBUGS-35868" {
+			continue
+		}
+		if row["summary"] != helpers.PrivateIssuePlaceholder || row["description"] != helpers.PrivateIssuePlaceholder {
+			t.Errorf("expected the text of the issue with a security level to be replaced, got %q and %q", row["summary"], row["description"])
+		}
+	}
+}
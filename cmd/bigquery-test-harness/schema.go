@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	bigquery2 "github.com/bradmwilliams/jira-migration/pkg/bigquery"
+	"github.com/openshift/ci-search/pkg/bigquery"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+)
+
+type SchemaOptions struct {
+	*Options
+}
+
+func newSchemaCommand(parent *Options) *cobra.Command {
+	opt := &SchemaOptions{
+		Options: parent,
+	}
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Manage the schema of the BigQuery tables",
+	}
+	cmd.AddCommand(
+		opt.newCommand("create", "Create any missing tables", opt.Create),
+		opt.newCommand("diff", "Print the difference between the row types and the live tables", opt.Diff),
+		opt.newCommand("migrate", "Apply additive schema changes to the live tables", opt.Migrate),
+	)
+	return cmd
+}
+
+func (o *SchemaOptions) newCommand(use, short string, run func(context.Context, *bigquery2.SchemaManager, bigquery2.Feed) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Run: func(cmd *cobra.Command, arguments []string) {
+			ctx := context.Background()
+			if err := o.validateBigQuery(); err != nil {
+				klog.Exitf("error: %v", err)
+			}
+			config, err := o.Config()
+			if err != nil {
+				klog.Exitf("error: %v", err)
+			}
+			bqc, err := bigquery.NewBigQueryClient(o.GoogleProjectID, o.GoogleServiceAccountCredentialFile)
+			if err != nil {
+				klog.Exitf("Unable to configure bigquery client: %v", err)
+			}
+			for _, feed := range config.Feeds {
+				manager := bigquery2.NewSchemaManager(bigquery2.NewTableMetadataClient(bqc), feed.Dataset)
+				if err := run(ctx, manager, feed); err != nil {
+					klog.Exitf("error: %v", err)
+				}
+			}
+		},
+	}
+}
+
+func (o *SchemaOptions) Create(ctx context.Context, manager *bigquery2.SchemaManager, feed bigquery2.Feed) error {
+	for _, definition := range feed.TableDefinitions() {
+		if o.DryRun {
+			klog.Infof("[Dry Run] Creating table %s.%s", feed.Dataset, definition.Table)
+			continue
+		}
+		created, err := manager.Create(ctx, definition)
+		if err != nil {
+			return err
+		}
+		if created {
+			klog.Infof("Created table %s.%s", feed.Dataset, definition.Table)
+		} else {
+			klog.Infof("Table %s.%s already exists", feed.Dataset, definition.Table)
+		}
+	}
+	return nil
+}
+
+func (o *SchemaOptions) Diff(ctx context.Context, manager *bigquery2.SchemaManager, feed bigquery2.Feed) error {
+	for _, definition := range feed.TableDefinitions() {
+		changes, err := manager.Diff(ctx, definition)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s.%s: %d changes\n", feed.Dataset, definition.Table, len(changes))
+		for _, change := range changes {
+			fmt.Printf("  %s\n", change)
+		}
+	}
+	return nil
+}
+
+func (o *SchemaOptions) Migrate(ctx context.Context, manager *bigquery2.SchemaManager, feed bigquery2.Feed) error {
+	for _, definition := range feed.TableDefinitions() {
+		if o.DryRun {
+			changes, err := manager.Diff(ctx, definition)
+			if err != nil {
+				return err
+			}
+			klog.Infof("[Dry Run] Applying %d changes to table %s.%s", len(changes), feed.Dataset, definition.Table)
+			continue
+		}
+		changes, err := manager.Migrate(ctx, definition)
+		if err != nil {
+			return err
+		}
+		klog.Infof("Applied %d changes to table %s.%s", len(changes), feed.Dataset, definition.Table)
+		for _, change := range changes {
+			klog.Infof("  %s", change)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	bigquery2 "github.com/bradmwilliams/jira-migration/pkg/bigquery"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/openshift/ci-search/jira"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type SyncOptions struct {
+	*Options
+
+	JiraRefreshInterval    time.Duration
+	JiraMaxWatchInterval   time.Duration
+	JiraResyncInterval     time.Duration
+	JiraWatchOverlap       time.Duration
+	CommentRefreshInterval time.Duration
+}
+
+func newSyncCommand(parent *Options) *cobra.Command {
+	opt := &SyncOptions{
+		Options:                parent,
+		JiraRefreshInterval:    2 * time.Minute,
+		JiraMaxWatchInterval:   30 * time.Minute,
+		JiraResyncInterval:     8 * time.Hour,
+		JiraWatchOverlap:       helpers.DefaultWatchOverlap,
+		CommentRefreshInterval: 15 * time.Minute,
+	}
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Continuously sync the issues of every feed into the configured sinks",
+		Run: func(cmd *cobra.Command, arguments []string) {
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+
+			if err := opt.Validate(ctx); err != nil {
+				klog.Exitf("error: %v", err)
+			}
+			if err := opt.Run(ctx); err != nil {
+				klog.Exitf("error: %v", err)
+			}
+		},
+	}
+	opt.AddFlags(cmd.Flags())
+	return cmd
+}
+
+func (o *SyncOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&o.JiraRefreshInterval, "jira-refresh-interval", o.JiraRefreshInterval, "How often to poll Jira for changed issues.")
+	fs.DurationVar(&o.JiraMaxWatchInterval, "jira-max-watch-interval", o.JiraMaxWatchInterval, "The maximum duration of a single watch before the issue list is re-listed.")
+	fs.DurationVar(&o.JiraResyncInterval, "jira-resync-interval", o.JiraResyncInterval, "How often the informer resyncs its cache.")
+	fs.DurationVar(&o.JiraWatchOverlap, "jira-watch-overlap", o.JiraWatchOverlap, "How far before the last observed change every poll searches again, to find changes indexed late or made within the same minute.")
+	fs.DurationVar(&o.CommentRefreshInterval, "comment-refresh-interval", o.CommentRefreshInterval, "How often to refresh the comments of every known issue.")
+}
+
+func (o *SyncOptions) Validate(ctx context.Context) error {
+	if err := o.Options.Validate(ctx); err != nil {
+		return err
+	}
+	// the sync polls Jira until it is stopped, so its recording would grow without bounds
+	if len(o.jira.CassettePath) > 0 && helpers.CassetteMode(o.jira.CassetteMode) == helpers.CassetteRecord {
+		return errors.New("--jira-cassette-mode=record can not be used with sync, record a cassette with export or backfill instead")
+	}
+	return nil
+}
+
+func (o *SyncOptions) Run(ctx context.Context) error {
+	config, err := o.configWithSearch()
+	if err != nil {
+		return err
+	}
+
+	jc, err := o.jira.Client()
+	if err != nil {
+		klog.Fatalf("Unable to create jira client: %v", err)
+	}
+	backend, err := helpers.NewSearchBackend(ctx, jc, config.APIVersion)
+	if err != nil {
+		return err
+	}
+	// the informer pages with startAt, which the search client translates to the pagination of the backend
+	c := &jira.Client{
+		Client: helpers.NewSearchClient(backend),
+	}
+
+	sink, err := o.Sink()
+	if err != nil {
+		klog.Fatalf("Unable to configure sink: %v", err)
+	}
+	defer sink.Close()
+
+	go o.logRateLimits(ctx, time.Minute)
+
+	var wg sync.WaitGroup
+	for _, feed := range config.Feeds {
+		converter, err := o.Converter(config, feed, backend)
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func(feed bigquery2.Feed) {
+			defer wg.Done()
+			o.runFeed(ctx, c, backend, sink, converter, feed)
+		}(feed)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (o *SyncOptions) runFeed(ctx context.Context, c *jira.Client, backend helpers.SearchBackend, sink bigquery2.TicketSink, converter *bigquery2.Converter, feed bigquery2.Feed) {
+	informer := helpers.NewWatchInformer(
+		backend,
+		helpers.WatchOptions{
+			Interval:       o.JiraRefreshInterval,
+			MaxInterval:    o.JiraMaxWatchInterval,
+			ResyncInterval: o.JiraResyncInterval,
+			Overlap:        o.JiraWatchOverlap,
+		},
+		func(metav1.ListOptions) jira.SearchIssuesArgs {
+			return jira.SearchIssuesArgs{
+				Jql: feed.JQL,
+			}
+		},
+		// issues with a security level are kept, the redaction policy of the converter decides what is exported
+		nil,
+	)
+
+	syncer := bigquery2.NewSyncer(backend, sink, converter, feed, o.DryRun)
+	store := jira.NewCommentStore(c, o.CommentRefreshInterval, syncer)
+	syncer.SetStore(store)
+	if err := syncer.SetInformer(informer); err != nil {
+		klog.Errorf("Unable to follow the issue updates of feed %s: %v", feed.Name, err)
+		return
+	}
+
+	go func() {
+		if err := store.Run(ctx, informer); err != nil && !errors.Is(err, context.Canceled) {
+			klog.Errorf("Comment store of feed %s exited: %v", feed.Name, err)
+		}
+	}()
+	go informer.Run(ctx.Done())
+
+	klog.Infof("Waiting for the issue informer of feed %s to sync", feed.Name)
+	if cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		klog.Infof("Issue informer of feed %s synced, syncing changed issues to %s.%s every %s", feed.Name, feed.Dataset, feed.Table, feed.RefreshInterval.Duration)
+	}
+
+	syncer.Run(ctx)
+}
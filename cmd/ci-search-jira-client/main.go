@@ -4,15 +4,18 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"github.com/openshift/ci-search/jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
 	"github.com/spf13/cobra"
 	"k8s.io/klog/v2"
-	"sigs.k8s.io/prow/prow/flagutil"
 )
 
+// defaultJiraSearch is the query of the issues indexed when --jira-search is not set.
+const defaultJiraSearch = "project=OCPBUGS&created>='-14d'&status!='CLOSED'&affectedVersion IN (4.14,4.13,4.12,4.11,4.10,4.9,4.8,4.7,4.6,4.5,4.4,4.3,4.2)"
+
 type options struct {
-	jira       flagutil.JiraOptions
-	JiraSearch string
+	jira           helpers.JiraOptions
+	JiraSearch     string
+	JiraAPIVersion string
 }
 
 func main() {
@@ -22,7 +25,7 @@ func main() {
 	original.Set("v", "2")
 
 	opt := &options{
-		JiraSearch: "project=OCPBUGS&created>='-14d'&status!='CLOSED'&affectedVersion IN (4.14,4.13,4.12,4.11,4.10,4.9,4.8,4.7,4.6,4.5,4.4,4.3,4.2)",
+		JiraSearch: defaultJiraSearch,
 	}
 
 	cmd := &cobra.Command{
@@ -41,6 +44,7 @@ func main() {
 	flagset.AddGoFlag(original.Lookup("v"))
 
 	flagset.StringVar(&opt.JiraSearch, "jira-search", opt.JiraSearch, "A JQL query to search for issues to index.")
+	flagset.StringVar(&opt.JiraAPIVersion, "jira-api-version", opt.JiraAPIVersion, "The version of the Jira REST API to use. Defaults to 2 on Jira Server and 3 on Jira Cloud.")
 
 	if err := cmd.Execute(); err != nil {
 		klog.Exitf("error: %v", err)
@@ -48,32 +52,30 @@ func main() {
 }
 
 func (o *options) Run() error {
-	err := o.jira.Validate(true)
+	err := o.jira.Validate()
 	if err != nil {
-		klog.Fatalf("Invalid Jira options specified: %w", err)
+		klog.Fatalf("Invalid Jira options specified: %v", err)
 		return err
 	}
 
 	jc, err := o.jira.Client()
 	if err != nil {
-		klog.Fatalf("Unable to create jira client: %w", err)
-	}
-
-	c := &jira.Client{
-		Client: jc,
+		klog.Fatalf("Unable to create jira client: %v", err)
 	}
 
 	ctx := context.Background()
 
-	issues, err := c.SearchIssues(ctx, jira.SearchIssuesArgs{
-		Jql: o.JiraSearch,
-	})
+	backend, err := helpers.NewSearchBackend(ctx, jc, helpers.APIVersion(o.JiraAPIVersion))
 	if err != nil {
 		return err
 	}
-
-	for _, issue := range issues {
-		fmt.Println("Found issue: ", issue.ID)
+	it := helpers.NewSearchIterator(backend, o.JiraSearch, nil, func(fetched, total int) {
+		klog.V(2).Infof("Fetched %d/%d issues", fetched, total)
+	})
+	for it.Next(ctx) {
+		for _, issue := range it.Page().Issues {
+			fmt.Println("Found issue: ", issue.ID)
+		}
 	}
-	return nil
+	return it.Err()
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/bradmwilliams/jira-migration/pkg/jira/fakejira"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		deployment string
+		search     string
+	}{
+		{deployment: "Server", search: "GET /rest/api/2/search"},
+		{deployment: "Cloud", search: "GET /rest/api/3/search/jql"},
+	} {
+		t.Run(tt.deployment, func(t *testing.T) {
+			dataset, err := fakejira.LoadDataset("../../pkg/jira/fakejira/testdata/dataset.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			dataset.ServerInfo.DeploymentType = tt.deployment
+			fake, err := fakejira.New(dataset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the default query only matches the issues created in the last two weeks
+			fake.Now = func() time.Time { return time.Date(2024, 6, 18, 12, 0, 0, 0, time.UTC) }
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			opt := &options{JiraSearch: defaultJiraSearch}
+			opt.jira.Endpoint = server.URL
+			if err := opt.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if requests := strings.Join(fake.Requests(), "\n"); !strings.Contains(requests, tt.search) {
+				t.Errorf("expected the issues to be searched with %s, got\n%s", tt.search, requests)
+			}
+		})
+	}
+}
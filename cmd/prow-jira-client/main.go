@@ -6,10 +6,10 @@ import (
 	"flag"
 	"fmt"
 	"github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
 	"github.com/spf13/cobra"
 	"k8s.io/klog/v2"
 	"reflect"
-	"sigs.k8s.io/prow/prow/flagutil"
 	jira2 "sigs.k8s.io/prow/prow/jira"
 	"strings"
 )
@@ -19,7 +19,7 @@ const (
 )
 
 type options struct {
-	jira flagutil.JiraOptions
+	jira helpers.JiraOptions
 }
 
 func main() {
@@ -52,38 +52,43 @@ func main() {
 func (o *options) Run() error {
 	c, err := o.jira.Client()
 	if err != nil {
-		klog.Fatalf("Unable to create jira client: %w", err)
+		klog.Fatalf("Unable to create jira client: %v", err)
 	}
 
 	// issue, err := c.GetIssue("TRT-1716")
 	issue, err := c.GetIssue("OCPBUGS-35865")
 	if err != nil {
-		klog.Errorf("Unable to get jira issue: %w", err)
+		klog.Errorf("Unable to get jira issue: %v", err)
 		return err
 	}
 
-	b, err := json.MarshalIndent(issue, "", "    ")
+	scrubber, err := helpers.NewScrubber(nil)
+	if err != nil {
+		return err
+	}
+
+	dump, err := scrubber.MarshalIndent(issue)
 	if err != nil {
 		klog.Errorf("unable to marshal Jira Issue: %v", err)
 		return nil
 	}
-	klog.V(2).Infof("Retrieved issue:\n%s", string(b))
+	klog.V(2).Infof("Retrieved issue:\n%s", dump)
 
-	fields := getCustomFields(*issue)
+	fields := getCustomFields(*issue, scrubber)
 
-	b, err = json.MarshalIndent(fields, "", "    ")
+	dump, err = scrubber.MarshalIndent(fields)
 	if err != nil {
 		klog.Errorf("failed to marshall the custon fields struct. Error: %v", err)
 		return nil
 	}
-	klog.V(2).Infof("Custom Fields:\n%s", string(b))
+	klog.V(2).Infof("Custom Fields:\n%s", dump)
 
-	b, err = json.Marshal(fields)
+	b, err := json.Marshal(fields)
 	if err != nil {
 		klog.Errorf("failed to marshall the custon fields struct. Error: %v", err)
 		return nil
 	}
-	klog.V(2).Infof("Custom Fields:\n%s", string(b))
+	klog.V(2).Infof("Custom Fields:\n%s", scrubber.ScrubString(string(b)))
 
 	//targetVersion, err := helpers.GetIssueTargetVersion(issue)
 	//
@@ -261,14 +266,14 @@ type CustomField struct {
 	Votes       float64 `bigquery:"votes" json:"votes,omitempty"`
 }
 
-func getCustomFields(i jira.Issue) []CustomField {
+func getCustomFields(i jira.Issue, scrubber *helpers.Scrubber) []CustomField {
 	var customFields []CustomField
 	klog.Infof("Issue: %s", i.Key)
 	for k, v := range i.Fields.Unknowns {
 		if v == nil {
 			continue
 		}
-		klog.Infof("Field: %s -> %s", k, v)
+		klog.Infof("Field: %s -> %s", k, scrubber.ScrubString(fmt.Sprintf("%s", v)))
 		field := processCustomFieldValue(k, v)
 		if field != nil {
 			customFields = append(customFields, *field)
@@ -0,0 +1,78 @@
+package main
+
+import (
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/bradmwilliams/jira-migration/pkg/jira/fakejira"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dataset, err := fakejira.LoadDataset("../../pkg/jira/fakejira/testdata/dataset.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, err := fakejira.New(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	opt := &options{}
+	opt.jira.Endpoint = server.URL
+	if err := opt.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := fake.Requests(), []string{"GET /rest/api/2/issue/OCPBUGS-35865"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected requests %v, got %v", expected, actual)
+	}
+
+	// an issue that does not exist is an error
+	dataset.Issues = dataset.Issues[1:]
+	if fake, err = fakejira.New(dataset); err != nil {
+		t.Fatal(err)
+	}
+	missing := httptest.NewServer(fake)
+	defer missing.Close()
+	opt = &options{}
+	opt.jira.Endpoint = missing.URL
+	if err := opt.Run(); err == nil {
+		t.Errorf("expected a missing issue to be an error")
+	}
+}
+
+// TestGetCustomFieldsCassette reads the custom fields of an issue replayed from a cassette, as it was read from Jira.
+func TestGetCustomFieldsCassette(t *testing.T) {
+	opt := &options{}
+	opt.jira.Endpoint = "https://issues.example.com"
+	opt.jira.CassettePath = "../../pkg/jira/testdata/cassettes/OCPBUGS-35865.jsonl"
+	opt.jira.CassetteMode = string(helpers.CassetteReplay)
+	client, err := opt.jira.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue, err := client.GetIssue("OCPBUGS-35865")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrubber, err := helpers.NewScrubber(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := getCustomFields(*issue, scrubber)
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].FieldName < fields[j].FieldName
+	})
+	expected := []CustomField{
+		{FieldName: "creator", Name: "jdoe", Key: "jdoe", DisplayName: "Jane Doe"},
+		{FieldName: "customfield_12313441", ID: "27227", Value: "Approved"},
+		{FieldName: "customfield_12316243", Name: "qa", Key: "qa", DisplayName: "QA Contact"},
+		{FieldName: "customfield_12319940", Value: `[{"id":"12400001","name":"4.17.0"}]`},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected custom fields %#v, got %#v", expected, fields)
+	}
+}
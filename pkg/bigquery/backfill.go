@@ -0,0 +1,166 @@
+package bigquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The date fields that a backfill can be split on.
+const (
+	BackfillFieldCreated = "created"
+	BackfillFieldUpdated = "updated"
+)
+
+// backfillDateFormat is the minute precision date format of JQL.
+const backfillDateFormat = "2006/01/02 15:04"
+
+// BackfillWindow is the range of dates [Start, End) of one search of a backfill.
+type BackfillWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (w BackfillWindow) String() string {
+	return fmt.Sprintf("%s-%s", w.Start.Format(backfillDateFormat), w.End.Format(backfillDateFormat))
+}
+
+// SplitBackfillWindows splits [start, end) into consecutive windows of the given size, the last of which may be
+// shorter. The boundaries are truncated to the minute, which is the precision of dates in JQL.
+func SplitBackfillWindows(start, end time.Time, size time.Duration) []BackfillWindow {
+	start, end = start.UTC().Truncate(time.Minute), end.UTC().Truncate(time.Minute)
+	if size < time.Minute {
+		size = time.Minute
+	}
+	var windows []BackfillWindow
+	for next := start; next.Before(end); next = next.Add(size) {
+		windowEnd := next.Add(size)
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+		windows = append(windows, BackfillWindow{Start: next, End: windowEnd})
+	}
+	return windows
+}
+
+var backfillOrderByPattern = regexp.MustCompile(`(?i)\s+order\s+by\s+.*$`)
+
+// BackfillJQL restricts the query to the issues whose date field is within the window. Any ORDER BY clause of the query
+// is kept at the end, and issues are otherwise ordered by key so that the pages of the window do not shift while they
+// are fetched. Jira compares the dates in the time zone of the user, which shifts every window by the same offset, so
+// consecutive windows never leave a gap.
+func BackfillJQL(jql, field string, window BackfillWindow) string {
+	orderBy := backfillOrderByPattern.FindString(jql)
+	jql = jql[:len(jql)-len(orderBy)]
+	if len(orderBy) == 0 {
+		orderBy = " ORDER BY key ASC"
+	}
+	restriction := fmt.Sprintf(`%s >= "%s" AND %s < "%s"`, field, window.Start.Format(backfillDateFormat), field, window.End.Format(backfillDateFormat))
+	if len(jql) == 0 {
+		return restriction + orderBy
+	}
+	return fmt.Sprintf("(%s) AND %s%s", jql, restriction, orderBy)
+}
+
+// BackfillCheckpoint remembers the windows of a backfill that were written, so that an interrupted backfill resumes
+// with the windows that were not. When path is empty the checkpoint is only kept in memory.
+type BackfillCheckpoint struct {
+	path string
+
+	lock  sync.Mutex
+	state backfillState
+}
+
+type backfillState struct {
+	JQL       string           `json:"jql"`
+	Field     string           `json:"field"`
+	Completed []BackfillWindow `json:"completed"`
+}
+
+// NewBackfillCheckpoint loads the checkpoint of a backfill of the query split on field. A checkpoint of a different
+// query or field cannot be resumed and is an error.
+func NewBackfillCheckpoint(path, jql, field string) (*BackfillCheckpoint, error) {
+	c := &BackfillCheckpoint{
+		path:  path,
+		state: backfillState{JQL: jql, Field: field},
+	}
+	if len(path) == 0 {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read backfill checkpoint %s: %v", path, err)
+	}
+	var state backfillState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("unable to parse backfill checkpoint %s: %v", path, err)
+	}
+	if state.JQL != jql || state.Field != field {
+		return nil, fmt.Errorf("backfill checkpoint %s is for %s windows of %q, remove it to backfill %s windows of %q", path, state.Field, state.JQL, field, jql)
+	}
+	c.state.Completed = state.Completed
+	return c, nil
+}
+
+// Completed returns true if the window was written.
+func (c *BackfillCheckpoint) Completed(window BackfillWindow) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, completed := range c.state.Completed {
+		if completed.Start.Equal(window.Start) && completed.End.Equal(window.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// Remaining returns the windows that were not written yet.
+func (c *BackfillCheckpoint) Remaining(windows []BackfillWindow) []BackfillWindow {
+	var remaining []BackfillWindow
+	for _, window := range windows {
+		if !c.Completed(window) {
+			remaining = append(remaining, window)
+		}
+	}
+	return remaining
+}
+
+// Complete marks the window as written and saves the checkpoint.
+func (c *BackfillCheckpoint) Complete(window BackfillWindow) error {
+	c.lock.Lock()
+	c.state.Completed = append(c.state.Completed, window)
+	sort.Slice(c.state.Completed, func(i, j int) bool {
+		return c.state.Completed[i].Start.Before(c.state.Completed[j].Start)
+	})
+	c.lock.Unlock()
+	return c.Save()
+}
+
+// Save atomically persists the checkpoint to disk.
+func (c *BackfillCheckpoint) Save() error {
+	if len(c.path) == 0 {
+		return nil
+	}
+	c.lock.Lock()
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	c.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0750); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
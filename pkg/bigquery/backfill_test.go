@@ -0,0 +1,80 @@
+package bigquery
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitBackfillWindows(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC)
+	end := time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	expected := []BackfillWindow{
+		{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), End: end},
+	}
+	if actual := SplitBackfillWindows(start, end, 7*day); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, actual)
+	}
+	if actual := SplitBackfillWindows(end, start, day); len(actual) != 0 {
+		t.Errorf("expected no windows when the end is before the start, got %v", actual)
+	}
+}
+
+func TestBackfillJQL(t *testing.T) {
+	window := BackfillWindow{
+		Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 1, 8, 12, 30, 0, 0, time.UTC),
+	}
+	for _, tt := range []struct {
+		jql      string
+		expected string
+	}{
+		{
+			jql:      "project=OCPBUGS OR project=OCPBUGSM",
+			expected: `(project=OCPBUGS OR project=OCPBUGSM) AND created >= "2024/01/01 00:00" AND created < "2024/01/08 12:30" ORDER BY key ASC`,
+		},
+		{
+			jql:      "project=OCPBUGS order by key ASC",
+			expected: `(project=OCPBUGS) AND created >= "2024/01/01 00:00" AND created < "2024/01/08 12:30" order by key ASC`,
+		},
+		{
+			jql:      "",
+			expected: `created >= "2024/01/01 00:00" AND created < "2024/01/08 12:30" ORDER BY key ASC`,
+		},
+	} {
+		if actual := BackfillJQL(tt.jql, BackfillFieldCreated, window); actual != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, actual)
+		}
+	}
+}
+
+func TestBackfillCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints", "default.json")
+	windows := SplitBackfillWindows(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), 24*time.Hour)
+
+	checkpoint, err := NewBackfillCheckpoint(path, "project=OCPBUGS", BackfillFieldCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.Complete(windows[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.Complete(windows[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := NewBackfillCheckpoint(path, "project=OCPBUGS", BackfillFieldCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := resumed.Remaining(windows); !reflect.DeepEqual(actual, windows[1:2]) {
+		t.Errorf("expected only the second window to remain, got %v", actual)
+	}
+	if _, err := NewBackfillCheckpoint(path, "project=OCPBUGS", BackfillFieldUpdated); err == nil {
+		t.Errorf("expected a checkpoint of another field to be rejected")
+	}
+}
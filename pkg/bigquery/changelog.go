@@ -0,0 +1,87 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"crypto/sha256"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"strings"
+	"time"
+)
+
+// ChangelogEntry is a single field change from an issue's changelog. Rows join to Ticket on issue.id, and IssueKey
+// repeats issue.key as a top-level column that the table can be clustered on.
+type ChangelogEntry struct {
+	RecordCreated time.Time `bigquery:"record_created"`
+	Issue         Issue     `bigquery:"issue"`
+	IssueKey      string    `bigquery:"issue_key"`
+	HistoryID     string    `bigquery:"history_id"`
+	Author        string    `bigquery:"author"`
+	Created       time.Time `bigquery:"created"`
+	Field         string    `bigquery:"field"`
+	FieldType     string    `bigquery:"field_type"`
+	From          string    `bigquery:"from"`
+	FromString    string    `bigquery:"from_string"`
+	To            string    `bigquery:"to"`
+	ToString      string    `bigquery:"to_string"`
+}
+
+func (c *ChangelogEntry) Save() (map[string]bigquery.Value, string, error) {
+	return map[string]bigquery.Value{
+		"record_created": c.RecordCreated,
+		"issue":          c.Issue,
+		"issue_key":      c.IssueKey,
+		"history_id":     c.HistoryID,
+		"author":         c.Author,
+		"created":        c.Created,
+		"field":          c.Field,
+		"field_type":     c.FieldType,
+		"from":           c.From,
+		"from_string":    c.FromString,
+		"to":             c.To,
+		"to_string":      c.ToString,
+	}, c.InsertID(), nil
+}
+
+// InsertID identifies the changed field within a history entry. Jira never rewrites a history entry, so the same
+// entry always produces the same ID. A history entry may change a field more than once (for example when several
+// links are added), so the values are part of the ID.
+func (c *ChangelogEntry) InsertID() string {
+	item := sha256.Sum256([]byte(strings.Join([]string{c.Field, c.From, c.To}, "\x00")))
+	return fmt.Sprintf("%s-%s-%x", c.Issue.ID, c.HistoryID, item[:8])
+}
+
+func ConvertToChangelogEntries(issue jiraBaseClient.Issue, histories []jiraBaseClient.ChangelogHistory, timestamp time.Time) []*ChangelogEntry {
+	var entries []*ChangelogEntry
+	for _, history := range histories {
+		created := getCreatedTime(history.Created)
+		for _, item := range history.Items {
+			entries = append(entries, &ChangelogEntry{
+				RecordCreated: timestamp,
+				Issue: Issue{
+					ID:  issue.ID,
+					Key: issue.Key,
+				},
+				IssueKey:   issue.Key,
+				HistoryID:  history.Id,
+				Author:     helpers.UserFieldDisplayName(&history.Author),
+				Created:    created,
+				Field:      item.Field,
+				FieldType:  item.FieldType,
+				From:       getChangelogValue(item.From),
+				FromString: item.FromString,
+				To:         getChangelogValue(item.To),
+				ToString:   item.ToString,
+			})
+		}
+	}
+	return entries
+}
+
+func getChangelogValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
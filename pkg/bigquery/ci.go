@@ -0,0 +1,40 @@
+package bigquery
+
+import (
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+)
+
+// CIReference is a CI artifact referenced from the description or a comment of an issue, for joining issues with the
+// results of CI jobs. Kind is job, test, payload or must_gather. For jobs, Value is the name of the job and BuildID
+// the ID of the run. CommentID is set for references found in a comment.
+type CIReference struct {
+	Kind      string `bigquery:"kind"`
+	Value     string `bigquery:"value"`
+	URL       string `bigquery:"url"`
+	BuildID   string `bigquery:"build_id"`
+	Source    string `bigquery:"source"`
+	CommentID string `bigquery:"comment_id"`
+}
+
+// getCIReferences returns the CI artifacts referenced by the plain text of the description and comments of a ticket,
+// whose lines delimit test names. Every artifact is returned once per source in which it appears.
+func getCIReferences(ticket *Ticket) []CIReference {
+	var references []CIReference
+	add := func(text, source, commentID string) {
+		for _, reference := range helpers.FindCIReferences(text) {
+			references = append(references, CIReference{
+				Kind:      string(reference.Kind),
+				Value:     reference.Value,
+				URL:       reference.URL,
+				BuildID:   reference.BuildID,
+				Source:    source,
+				CommentID: commentID,
+			})
+		}
+	}
+	add(ticket.DescriptionText, ReferenceSourceDescription, "")
+	for _, comment := range ticket.Comments {
+		add(comment.MessageText, ReferenceSourceComment, comment.ID)
+	}
+	return references
+}
@@ -0,0 +1,51 @@
+package bigquery
+
+import (
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/openshift/ci-search/jira"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetCIReferences(t *testing.T) {
+	ticket := &Ticket{
+		DescriptionText: "Seen on 4.16.0-0.nightly-2024-03-01-123456",
+		Comments: []Comment{
+			{ID: "10", MessageText: "[sig-network] Services should serve endpoints [Suite:k8s] failed again on 4.16.0-0.nightly-2024-03-01-123456"},
+			{ID: "11", MessageText: "nothing to see"},
+		},
+	}
+	expected := []CIReference{
+		{Kind: "payload", Value: "4.16.0-0.nightly-2024-03-01-123456", Source: ReferenceSourceDescription},
+		{Kind: "test", Value: "[sig-network] Services should serve endpoints [Suite:k8s]", Source: ReferenceSourceComment, CommentID: "10"},
+		{Kind: "payload", Value: "4.16.0-0.nightly-2024-03-01-123456", Source: ReferenceSourceComment, CommentID: "10"},
+	}
+	if actual := getCIReferences(ticket); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected\n%#v\ngot\n%#v", expected, actual)
+	}
+}
+
+// TestGetCIReferencesOfConvertedTicket extracts the references of a converted ticket, whose raw description is flattened
+// to a single line that would run a test name into the text that follows it.
+func TestGetCIReferencesOfConvertedTicket(t *testing.T) {
+	issue := &jira.IssueComments{
+		Info: jiraBaseClient.Issue{ID: "1", Key: "OCPBUGS-1", Fields: &jiraBaseClient.IssueFields{
+			Description: "Failing test:\n{noformat}\n[sig-network] Services should serve endpoints on the same port\n{noformat}\nSeen on 4.16.0-0.nightly-2024-03-01-123456",
+		}},
+		Comments: []*jiraBaseClient.Comment{
+			{ID: "10", Body: "Failed again in [this run|https://prow.ci.openshift.org/view/gs/test-platform-results/logs/periodic-ci-openshift-release-master-nightly-4.16-e2e-aws/1763212345678901248]:\n* [sig-arch] Only known images used by tests\n* see the must-gather"},
+		},
+	}
+	expected := []CIReference{
+		{Kind: "test", Value: "[sig-network] Services should serve endpoints on the same port", Source: ReferenceSourceDescription},
+		{Kind: "payload", Value: "4.16.0-0.nightly-2024-03-01-123456", Source: ReferenceSourceDescription},
+		{Kind: "job", Value: "periodic-ci-openshift-release-master-nightly-4.16-e2e-aws", URL: "https://prow.ci.openshift.org/view/gs/test-platform-results/logs/periodic-ci-openshift-release-master-nightly-4.16-e2e-aws/1763212345678901248", BuildID: "1763212345678901248", Source: ReferenceSourceComment, CommentID: "10"},
+		{Kind: "test", Value: "[sig-arch] Only known images used by tests", Source: ReferenceSourceComment, CommentID: "10"},
+	}
+	ticket := ConvertToTicket(issue, nil, helpers.APIVersion2, time.Now())
+	if actual := getCIReferences(ticket); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected\n%#v\ngot\n%#v", expected, actual)
+	}
+}
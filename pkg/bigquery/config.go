@@ -0,0 +1,134 @@
+package bigquery
+
+import (
+	"errors"
+	"fmt"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+// Feed maps a JQL query to the BigQuery dataset and table its issues are written to. The changelog, metrics and field
+// catalog tables default to the ticket table name suffixed with _changelog, _metrics and _fields.
+type Feed struct {
+	Name              string          `json:"name"`
+	JQL               string          `json:"jql"`
+	Dataset           string          `json:"dataset"`
+	Table             string          `json:"table"`
+	ChangelogTable    string          `json:"changelogTable,omitempty"`
+	MetricsTable      string          `json:"metricsTable,omitempty"`
+	FieldCatalogTable string          `json:"fieldCatalogTable,omitempty"`
+	RefreshInterval   metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// Config is the list of feeds that the commands read from and write to, and the redaction policy applied to all of
+// them:
+//
+//	feeds:
+//	- name: ocpbugs
+//	  jql: project=OCPBUGS
+//	  dataset: jira_data
+//	  table: tickets
+//	  refreshInterval: 15m
+//	redaction:
+//	  default: placeholder
+//	  securityLevel: drop
+//	scrubPatterns:
+//	- name: internal-hostname
+//	  pattern: '[a-z0-9-]+\.corp\.example\.com'
+//
+// ScrubPatterns are applied after the built-in secret detectors of helpers.Scrubber. APIVersion overrides the version of
+// the Jira REST API, which is otherwise 2 on Jira Server and 3 on Jira Cloud as detected from /serverInfo.
+type Config struct {
+	Feeds         []Feed                   `json:"feeds"`
+	APIVersion    helpers.APIVersion       `json:"apiVersion,omitempty"`
+	Redaction     *helpers.RedactionPolicy `json:"redaction,omitempty"`
+	ScrubPatterns []helpers.ScrubPattern   `json:"scrubPatterns,omitempty"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config %s: %v", path, err)
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// Default fills in the derived table names, the refresh interval and the redaction policy when they are not set.
+func (c *Config) Default(refreshInterval time.Duration) {
+	if c.Redaction == nil {
+		c.Redaction = helpers.DefaultRedactionPolicy()
+	}
+	c.Redaction.Complete()
+	for i := range c.Feeds {
+		feed := &c.Feeds[i]
+		if len(feed.ChangelogTable) == 0 && len(feed.Table) > 0 {
+			feed.ChangelogTable = feed.Table + "_changelog"
+		}
+		if len(feed.MetricsTable) == 0 && len(feed.Table) > 0 {
+			feed.MetricsTable = feed.Table + "_metrics"
+		}
+		if len(feed.FieldCatalogTable) == 0 && len(feed.Table) > 0 {
+			feed.FieldCatalogTable = feed.Table + "_fields"
+		}
+		if feed.RefreshInterval.Duration == 0 {
+			feed.RefreshInterval.Duration = refreshInterval
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if len(c.Feeds) == 0 {
+		return errors.New("at least one feed must be configured")
+	}
+	if len(c.APIVersion) > 0 {
+		if err := c.APIVersion.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Redaction != nil {
+		if err := c.Redaction.Validate(); err != nil {
+			return fmt.Errorf("invalid redaction policy: %v", err)
+		}
+	}
+	if _, err := helpers.NewScrubber(c.ScrubPatterns); err != nil {
+		return err
+	}
+	names := make(map[string]struct{}, len(c.Feeds))
+	for _, feed := range c.Feeds {
+		if len(feed.Name) == 0 {
+			return errors.New("every feed must have a name")
+		}
+		if _, ok := names[feed.Name]; ok {
+			return fmt.Errorf("feed %s is defined more than once", feed.Name)
+		}
+		names[feed.Name] = struct{}{}
+		if len(feed.Dataset) == 0 {
+			return fmt.Errorf("feed %s must have a dataset", feed.Name)
+		}
+		if len(feed.Table) == 0 {
+			return fmt.Errorf("feed %s must have a table", feed.Name)
+		}
+		if feed.RefreshInterval.Duration <= 0 {
+			return fmt.Errorf("feed %s must have a refresh interval greater than zero", feed.Name)
+		}
+	}
+	return nil
+}
+
+// TableDefinitions returns the tables that the feed writes to. The tables of issues are clustered on issue_key, so that
+// queries filtering on an issue only read its blocks.
+func (f Feed) TableDefinitions() []TableDefinition {
+	return []TableDefinition{
+		{Table: f.Table, Row: Ticket{}, PartitionField: "record_created", Clustering: []string{"issue_key"}},
+		{Table: f.ChangelogTable, Row: ChangelogEntry{}, PartitionField: "record_created", Clustering: []string{"issue_key"}},
+		{Table: f.MetricsTable, Row: TicketMetrics{}, PartitionField: "record_created", Clustering: []string{"issue_key"}},
+		{Table: f.FieldCatalogTable, Row: FieldCatalogEntry{}, PartitionField: "record_created"},
+	}
+}
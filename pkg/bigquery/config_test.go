@@ -0,0 +1,167 @@
+package bigquery
+
+import (
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		config      string
+		expected    *Config
+		expectedErr bool
+	}{
+		{
+			name: "feeds and redaction policy",
+			config: `feeds:
+- name: ocpbugs
+  jql: project=OCPBUGS
+  dataset: jira_data
+  table: tickets
+  refreshInterval: 15m
+redaction:
+  default: placeholder
+  securityLevel: drop
+`,
+			expected: &Config{
+				Feeds: []Feed{
+					{Name: "ocpbugs", JQL: "project=OCPBUGS", Dataset: "jira_data", Table: "tickets", RefreshInterval: metav1.Duration{Duration: 15 * time.Minute}},
+				},
+				Redaction: &helpers.RedactionPolicy{Default: helpers.RedactionPlaceholder, SecurityLevel: helpers.RedactionDrop},
+			},
+		},
+		{
+			name: "unknown field",
+			config: `feeds:
+- name: ocpbugs
+  tabel: tickets
+`,
+			expectedErr: true,
+		},
+		{
+			name:        "invalid refresh interval",
+			config:      "feeds:\n- name: ocpbugs\n  refreshInterval: often\n",
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tc.config), 0640); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadConfig(path)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			if !reflect.DeepEqual(tc.expected, config) {
+				t.Errorf("unexpected config:\nexpected: %#v\nactual:   %#v", tc.expected, config)
+			}
+		})
+	}
+}
+
+func TestConfigDefault(t *testing.T) {
+	testCases := []struct {
+		name     string
+		feed     Feed
+		expected Feed
+	}{
+		{
+			name:     "derived tables and interval",
+			feed:     Feed{Name: "ocpbugs", Dataset: "jira_data", Table: "tickets"},
+			expected: Feed{Name: "ocpbugs", Dataset: "jira_data", Table: "tickets", ChangelogTable: "tickets_changelog", MetricsTable: "tickets_metrics", FieldCatalogTable: "tickets_fields", RefreshInterval: metav1.Duration{Duration: time.Minute}},
+		},
+		{
+			name:     "set tables and interval are kept",
+			feed:     Feed{Name: "ocpbugs", Dataset: "jira_data", Table: "tickets", ChangelogTable: "history", MetricsTable: "metrics", FieldCatalogTable: "fields", RefreshInterval: metav1.Duration{Duration: time.Hour}},
+			expected: Feed{Name: "ocpbugs", Dataset: "jira_data", Table: "tickets", ChangelogTable: "history", MetricsTable: "metrics", FieldCatalogTable: "fields", RefreshInterval: metav1.Duration{Duration: time.Hour}},
+		},
+		{
+			name:     "no table",
+			feed:     Feed{Name: "ocpbugs", Dataset: "jira_data"},
+			expected: Feed{Name: "ocpbugs", Dataset: "jira_data", RefreshInterval: metav1.Duration{Duration: time.Minute}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &Config{Feeds: []Feed{tc.feed}}
+			config.Default(time.Minute)
+			if !reflect.DeepEqual(tc.expected, config.Feeds[0]) {
+				t.Errorf("unexpected feed:\nexpected: %#v\nactual:   %#v", tc.expected, config.Feeds[0])
+			}
+			if config.Redaction == nil {
+				t.Error("expected the default redaction policy")
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	feed := func(name string) Feed {
+		return Feed{Name: name, Dataset: "jira_data", Table: name, RefreshInterval: metav1.Duration{Duration: time.Minute}}
+	}
+	testCases := []struct {
+		name        string
+		config      Config
+		expectedErr bool
+	}{
+		{
+			name:   "valid",
+			config: Config{Feeds: []Feed{feed("ocpbugs"), feed("ocpstrat")}},
+		},
+		{
+			name:        "no feeds",
+			config:      Config{},
+			expectedErr: true,
+		},
+		{
+			name:        "duplicate feed names",
+			config:      Config{Feeds: []Feed{feed("ocpbugs"), feed("ocpbugs")}},
+			expectedErr: true,
+		},
+		{
+			name:        "missing name",
+			config:      Config{Feeds: []Feed{feed("")}},
+			expectedErr: true,
+		},
+		{
+			name:        "missing dataset",
+			config:      Config{Feeds: []Feed{{Name: "ocpbugs", Table: "tickets", RefreshInterval: metav1.Duration{Duration: time.Minute}}}},
+			expectedErr: true,
+		},
+		{
+			name:        "missing table",
+			config:      Config{Feeds: []Feed{{Name: "ocpbugs", Dataset: "jira_data", RefreshInterval: metav1.Duration{Duration: time.Minute}}}},
+			expectedErr: true,
+		},
+		{
+			name:        "missing refresh interval",
+			config:      Config{Feeds: []Feed{{Name: "ocpbugs", Dataset: "jira_data", Table: "tickets"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid API version",
+			config:      Config{Feeds: []Feed{feed("ocpbugs")}, APIVersion: "4"},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid scrub pattern",
+			config:      Config{Feeds: []Feed{feed("ocpbugs")}, ScrubPatterns: []helpers.ScrubPattern{{Name: "broken", Pattern: "("}}},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.config.Validate(); (err != nil) != tc.expectedErr {
+				t.Errorf("expected error %t, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"encoding/json"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"k8s.io/klog/v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CustomFieldDecoder fills the typed columns of field from the raw value of a custom field, as decoded from the JSON
+// returned by Jira.
+type CustomFieldDecoder func(field *CustomField, value interface{}) error
+
+const (
+	sprintCustomType = "com.pyxis.greenhopper.jira:gh-sprint"
+	teamCustomType   = "com.atlassian.teams:rm-teams-custom-field-team"
+)
+
+var (
+	customFieldDecodersLock sync.RWMutex
+	// customFieldDecoders is keyed on the custom type of a field, such as com.pyxis.greenhopper.jira:gh-sprint, or on
+	// its schema type. Arrays are keyed on array:<item type>.
+	customFieldDecoders = map[string]CustomFieldDecoder{
+		"number":            decodeNumber,
+		"date":              decodeTimestamp("2006-01-02"),
+		"datetime":          decodeTimestamp("2006-01-02T15:04:05.000-0700"),
+		"user":              decodeUser,
+		"option":            decodeOption,
+		"option-with-child": decodeOptionWithChild,
+		"version":           decodeVersion,
+		"team":              decodeTeam,
+		"array:string":      decodeArray(stringName),
+		"array:option":      decodeArray(optionName),
+		"array:user":        decodeArray(userItemName),
+		"array:version":     decodeArray(namedItemName),
+		"array:component":   decodeArray(namedItemName),
+		sprintCustomType:    decodeSprints,
+		teamCustomType:      decodeTeam,
+	}
+)
+
+// RegisterCustomFieldDecoder registers the decoder for fields with the given custom type or schema type, replacing any
+// existing decoder.
+func RegisterCustomFieldDecoder(key string, decoder CustomFieldDecoder) {
+	customFieldDecodersLock.Lock()
+	defer customFieldDecodersLock.Unlock()
+	customFieldDecoders[key] = decoder
+}
+
+func lookupCustomFieldDecoder(schema jiraBaseClient.FieldSchema) (CustomFieldDecoder, bool) {
+	customFieldDecodersLock.RLock()
+	defer customFieldDecodersLock.RUnlock()
+	if decoder, ok := customFieldDecoders[schema.Custom]; ok && len(schema.Custom) > 0 {
+		return decoder, true
+	}
+	key := schema.Type
+	if key == "array" {
+		key = "array:" + schema.Items
+	}
+	decoder, ok := customFieldDecoders[key]
+	return decoder, ok
+}
+
+// decodeCustomField decodes the value with the decoder registered for its schema. Values without a decoder, or that
+// the decoder rejects, fall back to processCustomFieldValue.
+func decodeCustomField(name string, value interface{}, schema jiraBaseClient.FieldSchema) *CustomField {
+	if decoder, ok := lookupCustomFieldDecoder(schema); ok {
+		field := &CustomField{FieldName: name}
+		err := decoder(field, value)
+		if err == nil {
+			return field
+		}
+		klog.V(4).Infof("Unable to decode custom field %s of type %s: %v", name, schema.Type, err)
+	}
+	return processCustomFieldValue(name, value)
+}
+
+func decodeNumber(field *CustomField, value interface{}) error {
+	var n float64
+	switch v := value.(type) {
+	case float64:
+		n = v
+	case string:
+		var err error
+		if n, err = strconv.ParseFloat(v, 64); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("expected a number, got %T", value)
+	}
+	field.Value = strconv.FormatFloat(n, 'f', -1, 64)
+	field.NumericValue = bigquery.NullFloat64{Float64: n, Valid: true}
+	return nil
+}
+
+func decodeTimestamp(layout string) CustomFieldDecoder {
+	return func(field *CustomField, value interface{}) error {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %T", value)
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		field.Value = s
+		field.TimestampValue = bigquery.NullTimestamp{Timestamp: t, Valid: true}
+		return nil
+	}
+}
+
+func decodeUser(field *CustomField, value interface{}) error {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected a user, got %T", value)
+	}
+	field.ID = stringValue(m, "accountId")
+	field.Key = stringValue(m, "key")
+	field.DisplayName = stringValue(m, "displayName")
+	field.Value = userName(m)
+	return nil
+}
+
+func decodeOption(field *CustomField, value interface{}) error {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected an option, got %T", value)
+	}
+	field.ID = stringValue(m, "id")
+	field.Value = stringValue(m, "value")
+	return nil
+}
+
+// decodeOptionWithChild decodes a cascading select. Value holds the parent option and Values holds the parent and the
+// child option, if one is selected.
+func decodeOptionWithChild(field *CustomField, value interface{}) error {
+	if err := decodeOption(field, value); err != nil {
+		return err
+	}
+	field.Values = []string{field.Value}
+	if child, ok := value.(map[string]interface{})["child"].(map[string]interface{}); ok {
+		field.Values = append(field.Values, stringValue(child, "value"))
+	}
+	return nil
+}
+
+func decodeVersion(field *CustomField, value interface{}) error {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected a version, got %T", value)
+	}
+	field.ID = stringValue(m, "id")
+	field.Value = stringValue(m, "name")
+	return nil
+}
+
+// decodeTeam decodes an Advanced Roadmaps team, which is either the ID of the team or an object holding its ID and
+// title.
+func decodeTeam(field *CustomField, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		field.ID = v
+		field.Value = v
+	case float64:
+		field.ID = strconv.FormatFloat(v, 'f', -1, 64)
+		field.Value = field.ID
+	case map[string]interface{}:
+		field.ID = stringValue(v, "id")
+		field.Value = stringValue(v, "title")
+		if len(field.Value) == 0 {
+			field.Value = stringValue(v, "name")
+		}
+	default:
+		return fmt.Errorf("expected a team, got %T", value)
+	}
+	return nil
+}
+
+// decodeArray decodes a multi-valued field into Values, using name to turn every item into a string.
+func decodeArray(name func(interface{}) (string, error)) CustomFieldDecoder {
+	return func(field *CustomField, value interface{}) error {
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected an array, got %T", value)
+		}
+		values := make([]string, 0, len(items))
+		for _, item := range items {
+			s, err := name(item)
+			if err != nil {
+				return err
+			}
+			values = append(values, s)
+		}
+		field.Values = values
+		field.Value = strings.Join(values, ", ")
+		return nil
+	}
+}
+
+func stringName(item interface{}) (string, error) {
+	s, ok := item.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a string, got %T", item)
+	}
+	return s, nil
+}
+
+func optionName(item interface{}) (string, error) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected an option, got %T", item)
+	}
+	return stringValue(m, "value"), nil
+}
+
+// namedItemName returns the name of an item such as a version or a component.
+func namedItemName(item interface{}) (string, error) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected a named item, got %T", item)
+	}
+	return stringValue(m, "name"), nil
+}
+
+func userItemName(item interface{}) (string, error) {
+	if _, ok := item.(map[string]interface{}); !ok {
+		return "", fmt.Errorf("expected a user, got %T", item)
+	}
+	return userName(item), nil
+}
+
+func userName(item interface{}) string {
+	m, _ := item.(map[string]interface{})
+	if name := stringValue(m, "name"); len(name) > 0 {
+		return name
+	}
+	return stringValue(m, "accountId")
+}
+
+// Sprint is a single sprint of a greenhopper sprint field.
+type Sprint struct {
+	ID           string `json:"id"`
+	RapidViewID  string `json:"rapidViewId,omitempty"`
+	State        string `json:"state,omitempty"`
+	Name         string `json:"name"`
+	StartDate    string `json:"startDate,omitempty"`
+	EndDate      string `json:"endDate,omitempty"`
+	CompleteDate string `json:"completeDate,omitempty"`
+	Goal         string `json:"goal,omitempty"`
+}
+
+// legacySprintPattern matches the string form that Jira Server uses for sprints, for example
+// com.atlassian.greenhopper.service.sprint.Sprint@1b5b1e0[id=123,rapidViewId=45,state=CLOSED,name=Sprint 1,...].
+var legacySprintPattern = regexp.MustCompile(`^com\.atlassian\.greenhopper\.service\.sprint\.Sprint@[0-9a-f]+\[(.*)\]$`)
+
+// legacySprintKeys are the keys of the legacy sprint string. Values may contain commas, so the string is split on the
+// known keys rather than on commas.
+var legacySprintKeys = regexp.MustCompile(`(?:^|,)(id|rapidViewId|state|name|startDate|endDate|completeDate|activatedDate|sequence|goal|synced|autoStartStop|incompleteIssuesDestinationId)=`)
+
+// decodeSprints decodes a sprint field into the names of its sprints in Values and the sprints themselves as JSON in
+// StructuredValue.
+func decodeSprints(field *CustomField, value interface{}) error {
+	items, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("expected an array of sprints, got %T", value)
+	}
+	sprints := make([]Sprint, 0, len(items))
+	for _, item := range items {
+		sprint, err := parseSprint(item)
+		if err != nil {
+			return err
+		}
+		sprints = append(sprints, sprint)
+	}
+	names := make([]string, 0, len(sprints))
+	for _, sprint := range sprints {
+		names = append(names, sprint.Name)
+	}
+	field.Values = names
+	field.Value = strings.Join(names, ", ")
+	field.StructuredValue = generateBigQueryJson(sprints)
+	return nil
+}
+
+func parseSprint(item interface{}) (Sprint, error) {
+	switch v := item.(type) {
+	case map[string]interface{}:
+		return Sprint{
+			ID:           stringValue(v, "id"),
+			RapidViewID:  stringValue(v, "boardId"),
+			State:        stringValue(v, "state"),
+			Name:         stringValue(v, "name"),
+			StartDate:    stringValue(v, "startDate"),
+			EndDate:      stringValue(v, "endDate"),
+			CompleteDate: stringValue(v, "completeDate"),
+			Goal:         stringValue(v, "goal"),
+		}, nil
+	case string:
+		return parseLegacySprint(v)
+	default:
+		return Sprint{}, fmt.Errorf("expected a sprint, got %T", item)
+	}
+}
+
+func parseLegacySprint(s string) (Sprint, error) {
+	m := legacySprintPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Sprint{}, fmt.Errorf("unrecognized sprint %q", s)
+	}
+	body := m[1]
+	values := make(map[string]string)
+	matches := legacySprintKeys.FindAllStringSubmatchIndex(body, -1)
+	for i, match := range matches {
+		end := len(body)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		value := body[match[1]:end]
+		if value == "<null>" {
+			value = ""
+		}
+		values[body[match[2]:match[3]]] = value
+	}
+	return Sprint{
+		ID:           values["id"],
+		RapidViewID:  values["rapidViewId"],
+		State:        values["state"],
+		Name:         values["name"],
+		StartDate:    values["startDate"],
+		EndDate:      values["endDate"],
+		CompleteDate: values["completeDate"],
+		Goal:         values["goal"],
+	}, nil
+}
+
+// stringValue returns the value of key as a string. Numeric IDs are formatted without a fraction.
+func stringValue(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,126 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"encoding/json"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeCustomField(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   jiraBaseClient.FieldSchema
+		value    string
+		expected CustomField
+	}{
+		{
+			name:     "number",
+			schema:   jiraBaseClient.FieldSchema{Type: "number"},
+			value:    `3.5`,
+			expected: CustomField{Value: "3.5", NumericValue: bigquery.NullFloat64{Float64: 3.5, Valid: true}},
+		},
+		{
+			name:     "date",
+			schema:   jiraBaseClient.FieldSchema{Type: "date"},
+			value:    `"2024-03-01"`,
+			expected: CustomField{Value: "2024-03-01", TimestampValue: bigquery.NullTimestamp{Timestamp: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Valid: true}},
+		},
+		{
+			name:     "datetime",
+			schema:   jiraBaseClient.FieldSchema{Type: "datetime"},
+			value:    `"2024-03-01T10:00:00.000+0000"`,
+			expected: CustomField{Value: "2024-03-01T10:00:00.000+0000", TimestampValue: bigquery.NullTimestamp{Timestamp: time.Date(2024, 3, 1, 10, 0, 0, 0, time.FixedZone("", 0)), Valid: true}},
+		},
+		{
+			name:     "user",
+			schema:   jiraBaseClient.FieldSchema{Type: "user"},
+			value:    `{"name":"jdoe","key":"JIRAUSER1","displayName":"Jane Doe"}`,
+			expected: CustomField{Key: "JIRAUSER1", DisplayName: "Jane Doe", Value: "jdoe"},
+		},
+		{
+			name:     "option",
+			schema:   jiraBaseClient.FieldSchema{Type: "option"},
+			value:    `{"id":"10","value":"Approved"}`,
+			expected: CustomField{ID: "10", Value: "Approved"},
+		},
+		{
+			name:     "option with child",
+			schema:   jiraBaseClient.FieldSchema{Type: "option-with-child"},
+			value:    `{"id":"10","value":"Networking","child":{"id":"11","value":"DNS"}}`,
+			expected: CustomField{ID: "10", Value: "Networking", Values: []string{"Networking", "DNS"}},
+		},
+		{
+			name:     "multi-select",
+			schema:   jiraBaseClient.FieldSchema{Type: "array", Items: "option"},
+			value:    `[{"id":"1","value":"Yes"},{"id":"2","value":"No"}]`,
+			expected: CustomField{Value: "Yes, No", Values: []string{"Yes", "No"}},
+		},
+		{
+			name:     "version",
+			schema:   jiraBaseClient.FieldSchema{Type: "array", Items: "version"},
+			value:    `[{"id":"1","name":"4.15.0"},{"id":"2","name":"4.16.0"}]`,
+			expected: CustomField{Value: "4.15.0, 4.16.0", Values: []string{"4.15.0", "4.16.0"}},
+		},
+		{
+			name:   "legacy sprint",
+			schema: jiraBaseClient.FieldSchema{Type: "array", Items: "string", Custom: sprintCustomType},
+			value:  `["com.atlassian.greenhopper.service.sprint.Sprint@1b5b1e0[id=123,rapidViewId=45,state=CLOSED,name=Sprint 1, the first,startDate=2024-03-01T10:00:00.000Z,endDate=2024-03-15T10:00:00.000Z,completeDate=<null>,sequence=123,goal=]"]`,
+			expected: CustomField{
+				Value:           "Sprint 1, the first",
+				Values:          []string{"Sprint 1, the first"},
+				StructuredValue: `[{"id":"123","rapidViewId":"45","state":"CLOSED","name":"Sprint 1, the first","startDate":"2024-03-01T10:00:00.000Z","endDate":"2024-03-15T10:00:00.000Z"}]`,
+			},
+		},
+		{
+			name:   "sprint",
+			schema: jiraBaseClient.FieldSchema{Type: "array", Items: "json", Custom: sprintCustomType},
+			value:  `[{"id":123,"boardId":45,"state":"active","name":"Sprint 2"}]`,
+			expected: CustomField{
+				Value:           "Sprint 2",
+				Values:          []string{"Sprint 2"},
+				StructuredValue: `[{"id":"123","rapidViewId":"45","state":"active","name":"Sprint 2"}]`,
+			},
+		},
+		{
+			name:     "team",
+			schema:   jiraBaseClient.FieldSchema{Type: "any", Custom: teamCustomType},
+			value:    `{"id":"42","title":"TRT"}`,
+			expected: CustomField{ID: "42", Value: "TRT"},
+		},
+		{
+			name:     "unknown schema falls back",
+			schema:   jiraBaseClient.FieldSchema{Type: "unknown"},
+			value:    `2`,
+			expected: CustomField{Value: "2"},
+		},
+		{
+			name:     "rejected value falls back",
+			schema:   jiraBaseClient.FieldSchema{Type: "date"},
+			value:    `"not a date"`,
+			expected: CustomField{Value: "not a date"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			tt.expected.FieldName = "customfield_1"
+			actual := decodeCustomField("customfield_1", value, tt.schema)
+			if actual == nil {
+				t.Fatalf("expected a custom field")
+			}
+			if !actual.TimestampValue.Timestamp.Equal(tt.expected.TimestampValue.Timestamp) {
+				t.Errorf("expected timestamp %s, got %s", tt.expected.TimestampValue.Timestamp, actual.TimestampValue.Timestamp)
+			}
+			actual.TimestampValue.Timestamp = tt.expected.TimestampValue.Timestamp
+			if !reflect.DeepEqual(*actual, tt.expected) {
+				t.Errorf("expected\n%#v\ngot\n%#v", tt.expected, *actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"fmt"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"time"
+)
+
+// FieldCatalogEntry describes a field of the Jira instance. It is a dimension table that the custom_fields of Ticket
+// join to on field_name = id.
+type FieldCatalogEntry struct {
+	RecordCreated    time.Time `bigquery:"record_created"`
+	ID               string    `bigquery:"id"`
+	Key              string    `bigquery:"key"`
+	Name             string    `bigquery:"name"`
+	Custom           bool      `bigquery:"custom"`
+	ClauseNames      []string  `bigquery:"clause_names"`
+	SchemaType       string    `bigquery:"schema_type"`
+	SchemaItems      string    `bigquery:"schema_items"`
+	SchemaCustomType string    `bigquery:"schema_custom_type"`
+	SchemaSystem     string    `bigquery:"schema_system"`
+}
+
+func (e *FieldCatalogEntry) Save() (map[string]bigquery.Value, string, error) {
+	return map[string]bigquery.Value{
+		"record_created":     e.RecordCreated,
+		"id":                 e.ID,
+		"key":                e.Key,
+		"name":               e.Name,
+		"custom":             e.Custom,
+		"clause_names":       e.ClauseNames,
+		"schema_type":        e.SchemaType,
+		"schema_items":       e.SchemaItems,
+		"schema_custom_type": e.SchemaCustomType,
+		"schema_system":      e.SchemaSystem,
+	}, fmt.Sprintf("%s-%d", e.ID, e.RecordCreated.UnixNano()), nil
+}
+
+func ConvertToFieldCatalogEntries(catalog *helpers.FieldCatalog, timestamp time.Time) []*FieldCatalogEntry {
+	var entries []*FieldCatalogEntry
+	for _, field := range catalog.Fields() {
+		entries = append(entries, &FieldCatalogEntry{
+			RecordCreated:    timestamp,
+			ID:               field.ID,
+			Key:              field.Key,
+			Name:             field.Name,
+			Custom:           field.Custom,
+			ClauseNames:      field.ClauseNames,
+			SchemaType:       field.Schema.Type,
+			SchemaItems:      field.Schema.Items,
+			SchemaCustomType: field.Schema.Custom,
+			SchemaSystem:     field.Schema.System,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,37 @@
+package bigquery
+
+import (
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"testing"
+	"time"
+)
+
+func TestGetCustomFieldsUsesCatalog(t *testing.T) {
+	catalog := helpers.NewFieldCatalog([]jiraBaseClient.Field{{
+		ID:     "customfield_12319940",
+		Name:   "Target Version",
+		Custom: true,
+		Schema: jiraBaseClient.FieldSchema{Type: "array", Items: "version", Custom: "com.atlassian.jira.plugin.system.customfieldtypes:multiversion"},
+	}})
+	issue := jiraBaseClient.Issue{Fields: &jiraBaseClient.IssueFields{Unknowns: map[string]interface{}{
+		"customfield_12319940": "4.16",
+		"customfield_1":        "unknown",
+	}}}
+
+	fields := map[string]CustomField{}
+	for _, field := range getCustomFields(issue, catalog) {
+		fields[field.FieldName] = field
+	}
+	if field := fields["customfield_12319940"]; field.Name != "Target Version" || field.SchemaType != "array" || field.SchemaCustomType != "com.atlassian.jira.plugin.system.customfieldtypes:multiversion" {
+		t.Errorf("expected the field to be named from the catalog, got %#v", field)
+	}
+	if field := fields["customfield_1"]; field.Name != "" || field.Value != "unknown" {
+		t.Errorf("expected a field missing from the catalog to be left unnamed, got %#v", field)
+	}
+
+	entries := ConvertToFieldCatalogEntries(catalog, time.Now())
+	if len(entries) != 1 || entries[0].ID != "customfield_12319940" || entries[0].SchemaItems != "version" {
+		t.Errorf("unexpected field catalog entries: %#v", entries)
+	}
+}
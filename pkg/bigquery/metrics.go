@@ -0,0 +1,171 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/openshift/ci-search/jira"
+	"sort"
+	"time"
+)
+
+type StatusDuration struct {
+	Status  string `bigquery:"status"`
+	Seconds int64  `bigquery:"seconds"`
+	Entries int64  `bigquery:"entries"`
+}
+
+// TicketMetrics holds the cycle-time metrics derived from an issue's changelog and comments. Durations are in seconds
+// from issue creation and are NULL when the event has not happened yet. Rows join to Ticket on issue.id, and IssueKey
+// repeats issue.key as a top-level column that the table can be clustered on.
+type TicketMetrics struct {
+	RecordCreated         time.Time          `bigquery:"record_created"`
+	Issue                 Issue              `bigquery:"issue"`
+	IssueKey              string             `bigquery:"issue_key"`
+	TimeInStatus          []StatusDuration   `bigquery:"time_in_status"`
+	TimeToFirstAssignment bigquery.NullInt64 `bigquery:"time_to_first_assignment"`
+	TimeToFirstComment    bigquery.NullInt64 `bigquery:"time_to_first_comment"`
+	TimeToResolution      bigquery.NullInt64 `bigquery:"time_to_resolution"`
+	Reopens               int64              `bigquery:"reopens"`
+	LastChangedTime       time.Time          `bigquery:"last_changed_time"`
+}
+
+func (m *TicketMetrics) Save() (map[string]bigquery.Value, string, error) {
+	return map[string]bigquery.Value{
+		"record_created":           m.RecordCreated,
+		"issue":                    m.Issue,
+		"issue_key":                m.IssueKey,
+		"time_in_status":           m.TimeInStatus,
+		"time_to_first_assignment": m.TimeToFirstAssignment,
+		"time_to_first_comment":    m.TimeToFirstComment,
+		"time_to_resolution":       m.TimeToResolution,
+		"reopens":                  m.Reopens,
+		"last_changed_time":        m.LastChangedTime,
+	}, insertID(m.Issue.ID, m.LastChangedTime), nil
+}
+
+type statusChange struct {
+	at   time.Time
+	from string
+	to   string
+}
+
+// ConvertToTicketMetrics derives the metrics of an issue as of timestamp. The time spent in the current status is
+// counted up to timestamp. A reopen is counted every time the resolution of the issue is cleared.
+func ConvertToTicketMetrics(issueComments *jira.IssueComments, histories []jiraBaseClient.ChangelogHistory, timestamp time.Time) *TicketMetrics {
+	fields := issueComments.Info.Fields
+	created := time.Time(fields.Created)
+
+	metrics := &TicketMetrics{
+		RecordCreated: timestamp,
+		Issue: Issue{
+			ID:  issueComments.Info.ID,
+			Key: issueComments.Info.Key,
+		},
+		IssueKey:        issueComments.Info.Key,
+		LastChangedTime: getUpdatedTime(fields.Updated),
+	}
+
+	var statusChanges []statusChange
+	var firstAssignment time.Time
+	// firstAssigneeChange is the earliest change of the assignee, whose previous assignee was set when the issue was
+	// created
+	var firstAssigneeChange *jiraBaseClient.ChangelogItems
+	var firstAssigneeChangeAt time.Time
+	for _, history := range histories {
+		at := getCreatedTime(history.Created)
+		for i, item := range history.Items {
+			switch item.Field {
+			case "status":
+				statusChanges = append(statusChanges, statusChange{at: at, from: item.FromString, to: item.ToString})
+			case "assignee":
+				if len(item.ToString) > 0 && (firstAssignment.IsZero() || at.Before(firstAssignment)) {
+					firstAssignment = at
+				}
+				if firstAssigneeChange == nil || at.Before(firstAssigneeChangeAt) {
+					firstAssigneeChange, firstAssigneeChangeAt = &history.Items[i], at
+				}
+			case "resolution":
+				if len(item.FromString) > 0 && len(item.ToString) == 0 {
+					metrics.Reopens++
+				}
+			}
+		}
+	}
+	// an issue that was created assigned either has no assignee history or was first reassigned or unassigned from
+	// the assignee it was created with
+	switch {
+	case firstAssigneeChange == nil && fields.Assignee != nil:
+		firstAssignment = created
+	case firstAssigneeChange != nil && (len(getChangelogValue(firstAssigneeChange.From)) > 0 || len(firstAssigneeChange.FromString) > 0):
+		firstAssignment = created
+	}
+
+	metrics.TimeInStatus = getTimeInStatus(created, helpers.StatusFieldName(fields.Status), statusChanges, timestamp)
+	metrics.TimeToFirstAssignment = secondsSince(created, firstAssignment)
+	metrics.TimeToFirstComment = secondsSince(created, getFirstCommentTime(issueComments.Comments))
+	if fields.Resolution != nil {
+		metrics.TimeToResolution = secondsSince(created, time.Time(fields.Resolutiondate))
+	}
+	return metrics
+}
+
+func getTimeInStatus(created time.Time, current string, changes []statusChange, now time.Time) []StatusDuration {
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].at.Before(changes[j].at)
+	})
+
+	status := current
+	if len(changes) > 0 {
+		status = changes[0].from
+	}
+
+	var order []string
+	durations := make(map[string]*StatusDuration)
+	enter := func(name string) {
+		d, ok := durations[name]
+		if !ok {
+			d = &StatusDuration{Status: name}
+			durations[name] = d
+			order = append(order, name)
+		}
+		d.Entries++
+	}
+	add := func(name string, from, to time.Time) {
+		if to.After(from) {
+			durations[name].Seconds += int64(to.Sub(from) / time.Second)
+		}
+	}
+
+	since := created
+	enter(status)
+	for _, change := range changes {
+		add(status, since, change.at)
+		status, since = change.to, change.at
+		enter(status)
+	}
+	add(status, since, now)
+
+	result := make([]StatusDuration, 0, len(order))
+	for _, name := range order {
+		result = append(result, *durations[name])
+	}
+	return result
+}
+
+func getFirstCommentTime(comments []*jiraBaseClient.Comment) time.Time {
+	var first time.Time
+	for _, comment := range comments {
+		if t := getCreatedTime(comment.Created); !t.IsZero() && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	return first
+}
+
+func secondsSince(start, end time.Time) bigquery.NullInt64 {
+	if start.IsZero() || end.IsZero() {
+		return bigquery.NullInt64{}
+	}
+	return bigquery.NullInt64{Int64: int64(end.Sub(start) / time.Second), Valid: true}
+}
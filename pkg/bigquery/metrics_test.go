@@ -0,0 +1,134 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"github.com/openshift/ci-search/jira"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertToTicketMetrics(t *testing.T) {
+	created := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) string {
+		return created.Add(time.Duration(hours) * time.Hour).Format("2006-01-02T15:04:05.000-0700")
+	}
+	history := func(hours int, items ...jiraBaseClient.ChangelogItems) jiraBaseClient.ChangelogHistory {
+		return jiraBaseClient.ChangelogHistory{Created: at(hours), Items: items}
+	}
+	status := func(from, to string) jiraBaseClient.ChangelogItems {
+		return jiraBaseClient.ChangelogItems{Field: "status", FromString: from, ToString: to}
+	}
+	resolution := func(from, to string) jiraBaseClient.ChangelogItems {
+		return jiraBaseClient.ChangelogItems{Field: "resolution", FromString: from, ToString: to}
+	}
+	seconds := func(hours int) bigquery.NullInt64 {
+		return bigquery.NullInt64{Int64: int64(hours * 3600), Valid: true}
+	}
+
+	testCases := []struct {
+		name      string
+		fields    *jiraBaseClient.IssueFields
+		comments  []*jiraBaseClient.Comment
+		histories []jiraBaseClient.ChangelogHistory
+		expected  *TicketMetrics
+	}{
+		{
+			name: "new issue without history",
+			fields: &jiraBaseClient.IssueFields{
+				Created: jiraBaseClient.Time(created),
+				Status:  &jiraBaseClient.Status{Name: "New"},
+			},
+			expected: &TicketMetrics{
+				TimeInStatus: []StatusDuration{{Status: "New", Seconds: 10 * 3600, Entries: 1}},
+			},
+		},
+		{
+			name: "resolved, reopened and resolved again",
+			fields: &jiraBaseClient.IssueFields{
+				Created:        jiraBaseClient.Time(created),
+				Status:         &jiraBaseClient.Status{Name: "Closed"},
+				Resolution:     &jiraBaseClient.Resolution{Name: "Done"},
+				Resolutiondate: jiraBaseClient.Time(created.Add(8 * time.Hour)),
+			},
+			comments: []*jiraBaseClient.Comment{{Created: at(3)}, {Created: at(2)}},
+			histories: []jiraBaseClient.ChangelogHistory{
+				history(1, jiraBaseClient.ChangelogItems{Field: "assignee", ToString: "Jane Doe"}, status("New", "ASSIGNED")),
+				history(4, status("ASSIGNED", "Closed"), resolution("", "Done")),
+				history(5, status("Closed", "ASSIGNED"), resolution("Done", "")),
+				history(8, status("ASSIGNED", "Closed"), resolution("", "Done")),
+			},
+			expected: &TicketMetrics{
+				TimeInStatus: []StatusDuration{
+					{Status: "New", Seconds: 1 * 3600, Entries: 1},
+					{Status: "ASSIGNED", Seconds: 6 * 3600, Entries: 2},
+					{Status: "Closed", Seconds: 3 * 3600, Entries: 2},
+				},
+				TimeToFirstAssignment: seconds(1),
+				TimeToFirstComment:    seconds(2),
+				TimeToResolution:      seconds(8),
+				Reopens:               1,
+			},
+		},
+		{
+			name: "created assigned",
+			fields: &jiraBaseClient.IssueFields{
+				Created:  jiraBaseClient.Time(created),
+				Status:   &jiraBaseClient.Status{Name: "ASSIGNED"},
+				Assignee: &jiraBaseClient.User{DisplayName: "Jane Doe"},
+			},
+			expected: &TicketMetrics{
+				TimeInStatus:          []StatusDuration{{Status: "ASSIGNED", Seconds: 10 * 3600, Entries: 1}},
+				TimeToFirstAssignment: seconds(0),
+			},
+		},
+		{
+			name: "created assigned then reassigned",
+			fields: &jiraBaseClient.IssueFields{
+				Created:  jiraBaseClient.Time(created),
+				Status:   &jiraBaseClient.Status{Name: "ASSIGNED"},
+				Assignee: &jiraBaseClient.User{DisplayName: "John Smith"},
+			},
+			histories: []jiraBaseClient.ChangelogHistory{
+				history(3, jiraBaseClient.ChangelogItems{Field: "assignee", From: "jdoe", FromString: "Jane Doe", To: "jsmith", ToString: "John Smith"}),
+			},
+			expected: &TicketMetrics{
+				TimeInStatus:          []StatusDuration{{Status: "ASSIGNED", Seconds: 10 * 3600, Entries: 1}},
+				TimeToFirstAssignment: seconds(0),
+			},
+		},
+		{
+			name: "created assigned then unassigned",
+			fields: &jiraBaseClient.IssueFields{
+				Created: jiraBaseClient.Time(created),
+				Status:  &jiraBaseClient.Status{Name: "New"},
+			},
+			histories: []jiraBaseClient.ChangelogHistory{
+				history(3, jiraBaseClient.ChangelogItems{Field: "assignee", From: "jdoe", FromString: "Jane Doe"}),
+			},
+			expected: &TicketMetrics{
+				TimeInStatus:          []StatusDuration{{Status: "New", Seconds: 10 * 3600, Entries: 1}},
+				TimeToFirstAssignment: seconds(0),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			timestamp := created.Add(10 * time.Hour)
+			issue := &jira.IssueComments{
+				Info:     jiraBaseClient.Issue{ID: "1", Key: "OCPBUGS-1", Fields: tc.fields},
+				Comments: tc.comments,
+			}
+			tc.expected.RecordCreated = timestamp
+			tc.expected.Issue = Issue{ID: "1", Key: "OCPBUGS-1"}
+			tc.expected.IssueKey = "OCPBUGS-1"
+
+			actual := ConvertToTicketMetrics(issue, tc.histories, timestamp)
+			actual.LastChangedTime = time.Time{}
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Errorf("unexpected metrics:\nexpected: %#v\nactual:   %#v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package bigquery
+
+import (
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+)
+
+// PanicSignature is the signature of the Go panic or stack trace reported in an issue, for finding duplicate bugs and
+// joining issues with CI failures by crash. Signature is a hash of the normalized Message and Frames, and is empty when
+// the issue reports no panic. CommentID is set for a panic found in a comment.
+type PanicSignature struct {
+	Signature string   `bigquery:"signature"`
+	Message   string   `bigquery:"message"`
+	Frames    []string `bigquery:"frames"`
+	Source    string   `bigquery:"source"`
+	CommentID string   `bigquery:"comment_id"`
+}
+
+// getPanicSignature returns the signature of the first panic in the description of a ticket or, failing that, in its
+// comments. The plain text is searched, as the raw description is flattened to a single line and the raw comments of
+// API version 3 are ADF documents, neither of which keep the lines of a stack trace.
+func getPanicSignature(ticket *Ticket) PanicSignature {
+	signature := func(text, source, commentID string) (PanicSignature, bool) {
+		found, ok := helpers.FindPanic(text)
+		if !ok {
+			return PanicSignature{}, false
+		}
+		return PanicSignature{
+			Signature: found.Hash(),
+			Message:   found.Message,
+			Frames:    found.Frames,
+			Source:    source,
+			CommentID: commentID,
+		}, true
+	}
+	if found, ok := signature(ticket.DescriptionText, ReferenceSourceDescription, ""); ok {
+		return found
+	}
+	for _, comment := range ticket.Comments {
+		if found, ok := signature(comment.MessageText, ReferenceSourceComment, comment.ID); ok {
+			return found
+		}
+	}
+	return PanicSignature{}
+}
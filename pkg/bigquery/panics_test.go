@@ -0,0 +1,85 @@
+package bigquery
+
+import (
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/openshift/ci-search/jira"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetPanicSignature(t *testing.T) {
+	ticket := &Ticket{
+		DescriptionText: "The installer hangs",
+		Comments: []Comment{
+			{ID: "10", MessageText: "no stack here"},
+			{ID: "11", MessageText: "{noformat}\npanic: assignment to entry in nil map\n\ngoroutine 1 [running]:\nmain.run()\n\t/src/main.go:10 +0x2a\n{noformat}"},
+			{ID: "12", MessageText: "goroutine 1 [running]:\nmain.other()\n\t/src/main.go:20 +0x2a"},
+		},
+	}
+	actual := getPanicSignature(ticket)
+	expected := PanicSignature{
+		Signature: actual.Signature,
+		Message:   "assignment to entry in nil map",
+		Frames:    []string{"main.run"},
+		Source:    ReferenceSourceComment,
+		CommentID: "11",
+	}
+	if len(actual.Signature) == 0 || !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected\n%#v\ngot\n%#v", expected, actual)
+	}
+	if actual := getPanicSignature(&Ticket{DescriptionText: "nothing"}); !reflect.DeepEqual(actual, PanicSignature{}) {
+		t.Errorf("expected no signature, got %#v", actual)
+	}
+}
+
+// TestGetPanicSignatureOfConvertedTicket finds the panics of converted tickets, whose raw description is flattened to a
+// single line and whose raw comments are ADF documents on API version 3.
+func TestGetPanicSignatureOfConvertedTicket(t *testing.T) {
+	stack := "panic: runtime error: invalid memory address or nil pointer dereference\n\ngoroutine 1 [running]:\nmain.run()\n\t/src/main.go:10 +0x2a"
+	testCases := []struct {
+		name        string
+		version     helpers.APIVersion
+		description string
+		comment     string
+		expected    PanicSignature
+	}{
+		{
+			name:        "description",
+			version:     helpers.APIVersion2,
+			description: "The installer crashed:\n{noformat}\n" + stack + "\n{noformat}\nIt happens on every run.",
+			expected: PanicSignature{
+				Message: "runtime error: invalid memory address or nil pointer dereference",
+				Frames:  []string{"main.run"},
+				Source:  ReferenceSourceDescription,
+			},
+		},
+		{
+			name:        "ADF comment",
+			version:     helpers.APIVersion3,
+			description: `{"type":"doc","version":1,"content":[{"type":"paragraph","content":[{"type":"text","text":"The installer crashed"}]}]}`,
+			comment:     `{"type":"doc","version":1,"content":[{"type":"codeBlock","content":[{"type":"text","text":"` + strings.ReplaceAll(strings.ReplaceAll(stack, "\n", `\n`), "\t", `\t`) + `"}]}]}`,
+			expected: PanicSignature{
+				Message:   "runtime error: invalid memory address or nil pointer dereference",
+				Frames:    []string{"main.run"},
+				Source:    ReferenceSourceComment,
+				CommentID: "10",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			issue := &jira.IssueComments{Info: jiraBaseClient.Issue{ID: "1", Key: "OCPBUGS-1", Fields: &jiraBaseClient.IssueFields{Description: tc.description}}}
+			if len(tc.comment) > 0 {
+				issue.Comments = []*jiraBaseClient.Comment{{ID: "10", Body: tc.comment}}
+			}
+			actual := getPanicSignature(ConvertToTicket(issue, nil, tc.version, time.Now()))
+			tc.expected.Signature = actual.Signature
+			if len(actual.Signature) == 0 || !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected\n%#v\ngot\n%#v", tc.expected, actual)
+			}
+		})
+	}
+}
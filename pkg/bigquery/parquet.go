@@ -0,0 +1,189 @@
+package bigquery
+
+import (
+	"bytes"
+	"cloud.google.com/go/bigquery"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bradmwilliams/jira-migration/pkg/parquet"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ParquetSink writes the rows of every call to a new Parquet file in <dir>/<dataset>/<table>/, named after the time the
+// sink was created and a sequence number, so that the files of a run sort in the order they were written. Columns
+// match the top-level columns of the BigQuery schema as in SQLiteSink: scalar columns keep their type, while records
+// and repeated columns are stored as JSON text.
+type ParquetSink struct {
+	dir     string
+	started time.Time
+
+	lock     sync.Mutex
+	sequence int
+}
+
+func NewParquetSink(dir string) *ParquetSink {
+	return &ParquetSink{dir: dir, started: time.Now().UTC()}
+}
+
+func (s *ParquetSink) WriteRows(_ context.Context, dataset, table string, rows interface{}) error {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("rows must be a slice, got %T", rows)
+	}
+	if v.Len() == 0 {
+		return nil
+	}
+	rowType := v.Type().Elem()
+	for rowType.Kind() == reflect.Ptr {
+		rowType = rowType.Elem()
+	}
+	schema, err := bigquery.InferSchema(reflect.Zero(rowType).Interface())
+	if err != nil {
+		return fmt.Errorf("unable to infer schema of table %s.%s: %v", dataset, table, err)
+	}
+	columns := make([]parquet.Column, 0, len(schema))
+	for _, field := range schema {
+		columns = append(columns, parquet.Column{Name: field.Name, Type: parquetType(field)})
+	}
+
+	values := make([][]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		row, ok := toRow(v.Index(i)).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		columnValues := make([]interface{}, 0, len(schema))
+		for j, field := range schema {
+			value, err := parquetValue(field, columns[j].Type, row[field.Name])
+			if err != nil {
+				return fmt.Errorf("unable to convert column %s of row %d of %s.%s: %v", field.Name, i, dataset, table, err)
+			}
+			columnValues = append(columnValues, value)
+		}
+		values = append(values, columnValues)
+	}
+	var data bytes.Buffer
+	if err := parquet.Write(&data, columns, values); err != nil {
+		return fmt.Errorf("unable to encode rows of %s.%s: %v", dataset, table, err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sequence++
+	path := filepath.Join(s.dir, dataset, table, fmt.Sprintf("%s-%06d.parquet", s.started.Format("20060102T150405Z"), s.sequence))
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("unable to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, data.Bytes(), 0640); err != nil {
+		return fmt.Errorf("unable to write rows to %s: %v", path, err)
+	}
+	return nil
+}
+
+func (s *ParquetSink) Close() error {
+	return nil
+}
+
+// parquetType is the type of the column of the field. Records and repeated fields are stored as JSON text.
+func parquetType(field *bigquery.FieldSchema) parquet.ColumnType {
+	if field.Repeated || field.Type == bigquery.RecordFieldType {
+		return parquet.JSON
+	}
+	switch field.Type {
+	case bigquery.IntegerFieldType:
+		return parquet.Int64
+	case bigquery.FloatFieldType, bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		return parquet.Double
+	case bigquery.BooleanFieldType:
+		return parquet.Boolean
+	case bigquery.TimestampFieldType:
+		return parquet.Timestamp
+	case bigquery.BytesFieldType:
+		return parquet.Bytes
+	default:
+		return parquet.String
+	}
+}
+
+// parquetValue converts a value of toRow into the value of the column of the field.
+func parquetValue(field *bigquery.FieldSchema, columnType parquet.ColumnType, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if columnType == parquet.JSON {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	switch v := value.(type) {
+	case time.Time:
+		if columnType == parquet.Timestamp {
+			return v, nil
+		}
+		return v.UTC().Format(time.RFC3339Nano), nil
+	case bigquery.NullTimestamp:
+		if !v.Valid {
+			return nil, nil
+		}
+		return parquetValue(field, columnType, v.Timestamp)
+	case bigquery.NullInt64:
+		if !v.Valid {
+			return nil, nil
+		}
+		return parquetValue(field, columnType, v.Int64)
+	case bigquery.NullFloat64:
+		if !v.Valid {
+			return nil, nil
+		}
+		return parquetValue(field, columnType, v.Float64)
+	case bigquery.NullBool:
+		if !v.Valid {
+			return nil, nil
+		}
+		return parquetValue(field, columnType, v.Bool)
+	case bigquery.NullString:
+		if !v.Valid {
+			return nil, nil
+		}
+		return parquetValue(field, columnType, v.StringVal)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch columnType {
+	case parquet.Int64:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int(), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int64(rv.Uint()), nil
+		}
+	case parquet.Double:
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return rv.Float(), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int()), nil
+		}
+	case parquet.Boolean:
+		if rv.Kind() == reflect.Bool {
+			return rv.Bool(), nil
+		}
+	case parquet.Bytes:
+		if b, ok := value.([]byte); ok {
+			return b, nil
+		}
+	case parquet.String:
+		if rv.Kind() == reflect.String {
+			return rv.String(), nil
+		}
+		return fmt.Sprint(value), nil
+	}
+	return nil, fmt.Errorf("unsupported value %T for a %s column", value, field.Type)
+}
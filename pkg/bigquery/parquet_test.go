@@ -0,0 +1,83 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"context"
+	"github.com/bradmwilliams/jira-migration/pkg/parquet"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParquetSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewParquetSink(dir)
+	created := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	rows := []*TicketMetrics{
+		{
+			RecordCreated:      created,
+			Issue:              Issue{ID: "1", Key: "OCPBUGS-1"},
+			IssueKey:           "OCPBUGS-1",
+			TimeInStatus:       []StatusDuration{{Status: "New", Seconds: 60, Entries: 1}},
+			TimeToFirstComment: bigquery.NullInt64{Int64: 30, Valid: true},
+		},
+		{
+			RecordCreated: created,
+			Issue:         Issue{ID: "2", Key: "OCPBUGS-2"},
+			IssueKey:      "OCPBUGS-2",
+			Reopens:       2,
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if err := sink.WriteRows(context.Background(), "dataset", "metrics", rows[i:i+1]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "dataset", "metrics", "*.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected a file per write, got %v", files)
+	}
+	var actual [][]interface{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns, rows, err := parquet.Read(data)
+		if err != nil {
+			t.Fatalf("unable to read %s: %v", file, err)
+		}
+		expectedColumns := []parquet.Column{
+			{Name: "record_created", Type: parquet.Timestamp},
+			{Name: "issue", Type: parquet.JSON},
+			{Name: "issue_key", Type: parquet.String},
+			{Name: "time_in_status", Type: parquet.JSON},
+			{Name: "time_to_first_assignment", Type: parquet.Int64},
+			{Name: "time_to_first_comment", Type: parquet.Int64},
+			{Name: "time_to_resolution", Type: parquet.Int64},
+			{Name: "reopens", Type: parquet.Int64},
+			{Name: "last_changed_time", Type: parquet.Timestamp},
+		}
+		if !reflect.DeepEqual(expectedColumns, columns) {
+			t.Errorf("unexpected columns of %s:\nexpected: %v\nactual:   %v", file, expectedColumns, columns)
+		}
+		actual = append(actual, rows...)
+	}
+	expected := [][]interface{}{
+		{created, `{"id":"1","key":"OCPBUGS-1"}`, "OCPBUGS-1", `[{"entries":1,"seconds":60,"status":"New"}]`, nil, int64(30), nil, int64(0), time.Time{}},
+		{created, `{"id":"2","key":"OCPBUGS-2"}`, "OCPBUGS-2", nil, nil, nil, nil, int64(2), time.Time{}},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("unexpected rows:\nexpected: %v\nactual:   %v", expected, actual)
+	}
+}
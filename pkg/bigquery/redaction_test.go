@@ -0,0 +1,100 @@
+package bigquery
+
+import (
+	"context"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/openshift/ci-search/jira"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestConvertNeverExportsPrivateText writes the rows of public and private issues to a sink and verifies that none of
+// the restricted text appears in the output, whatever the policy.
+func TestConvertNeverExportsPrivateText(t *testing.T) {
+	const secret = "do-not-export"
+	updated := jiraBaseClient.Time(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	restricted := &jiraBaseClient.Comment{
+		ID:         "2",
+		Body:       "restricted " + secret,
+		Author:     jiraBaseClient.User{DisplayName: "Restricted " + secret},
+		Created:    "2024-03-01T11:00:00.000+0000",
+		Updated:    "2024-03-01T11:00:00.000+0000",
+		Visibility: jiraBaseClient.CommentVisibility{Type: "group", Value: "secret"},
+	}
+	public := &jiraBaseClient.Comment{ID: "1", Body: "public", Created: "2024-03-01T10:00:00.000+0000", Updated: "2024-03-01T10:00:00.000+0000"}
+	publicIssue := &jira.IssueComments{
+		Info: jiraBaseClient.Issue{ID: "1", Key: "OCPBUGS-1", Fields: &jiraBaseClient.IssueFields{
+			Summary:  "public issue",
+			Updated:  updated,
+			Comments: &jiraBaseClient.Comments{Comments: []*jiraBaseClient.Comment{public, restricted}},
+		}},
+		Comments: []*jiraBaseClient.Comment{public, restricted},
+	}
+	privateIssue := &jira.IssueComments{
+		Info: jiraBaseClient.Issue{ID: "2", Key: "OCPBUGS-2", Fields: &jiraBaseClient.IssueFields{
+			Summary:     "summary " + secret,
+			Description: "description " + secret,
+			Updated:     updated,
+			Unknowns: map[string]interface{}{
+				"security":          map[string]interface{}{"id": "1", "name": "Embargoed"},
+				"customfield_10000": "release note " + secret,
+			},
+		}},
+		Comments: []*jiraBaseClient.Comment{public},
+	}
+	changelogs := map[string][]jiraBaseClient.ChangelogHistory{"2": {{
+		Id:      "1",
+		Created: "2024-03-01T11:00:00.000+0000",
+		Items:   []jiraBaseClient.ChangelogItems{{Field: "description", FromString: "old " + secret, ToString: "new " + secret}},
+	}}}
+	remoteLinks := map[string][]jiraBaseClient.RemoteLink{"2": {{
+		ID:     1,
+		Object: &jiraBaseClient.RemoteLinkObject{URL: "https://github.com/openshift/" + secret + "/pull/1", Title: "fix " + secret},
+	}}}
+
+	for _, action := range []helpers.RedactionAction{helpers.RedactionDrop, helpers.RedactionPlaceholder} {
+		t.Run(string(action), func(t *testing.T) {
+			state, err := NewChangeStore("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			converter := &Converter{
+				State:     state,
+				Redaction: &helpers.RedactionPolicy{Default: action, SecurityLevel: action},
+			}
+			rows := converter.Convert([]*jira.IssueComments{publicIssue, privateIssue}, changelogs, remoteLinks, time.Now())
+
+			dir := t.TempDir()
+			feed := Feed{Dataset: "jira", Table: "tickets", ChangelogTable: "changelog", MetricsTable: "metrics", FieldCatalogTable: "fields"}
+			if err := rows.Write(context.Background(), NewNDJSONSink(dir), feed); err != nil {
+				t.Fatal(err)
+			}
+			err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				if strings.Contains(string(data), secret) {
+					t.Errorf("%s contains private text:\n%s", path, data)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows.Tickets) == 0 || rows.Tickets[0].Issue.Key != "OCPBUGS-1" {
+				t.Errorf("expected the public issue to be exported")
+			}
+			if expected := map[helpers.RedactionAction]int{helpers.RedactionDrop: 1, helpers.RedactionPlaceholder: 2}[action]; len(rows.Tickets) != expected {
+				t.Errorf("expected %d tickets, got %d", expected, len(rows.Tickets))
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package bigquery
+
+import (
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+)
+
+// The sources of the references extracted from an issue.
+const (
+	ReferenceSourceRemoteLink  = "remote_link"
+	ReferenceSourceDescription = "description"
+	ReferenceSourceComment     = "comment"
+)
+
+// RemoteLink is a link from an issue to a page outside of Jira, such as a GitHub pull request. Org, Repo and
+// PullNumber are set when the link is to a pull request.
+type RemoteLink struct {
+	ID           string `bigquery:"id"`
+	URL          string `bigquery:"url"`
+	Title        string `bigquery:"title"`
+	Relationship string `bigquery:"relationship"`
+	Application  string `bigquery:"application"`
+	Org          string `bigquery:"org"`
+	Repo         string `bigquery:"repo"`
+	PullNumber   int    `bigquery:"pull_number"`
+}
+
+// PullRequestReference is a GitHub pull request referenced by an issue, either through a remote link or by mentioning
+// its URL in the description or a comment. CommentID is set for references found in a comment.
+type PullRequestReference struct {
+	Org       string `bigquery:"org"`
+	Repo      string `bigquery:"repo"`
+	Number    int    `bigquery:"number"`
+	URL       string `bigquery:"url"`
+	Source    string `bigquery:"source"`
+	CommentID string `bigquery:"comment_id"`
+}
+
+func getRemoteLinks(links []jiraBaseClient.RemoteLink) []RemoteLink {
+	remoteLinks := make([]RemoteLink, 0, len(links))
+	for _, link := range links {
+		remoteLink := RemoteLink{
+			ID:           fmt.Sprint(link.ID),
+			Relationship: link.Relationship,
+		}
+		if link.Object != nil {
+			remoteLink.URL = link.Object.URL
+			remoteLink.Title = helpers.LineSafe(link.Object.Title)
+		}
+		if link.Application != nil {
+			remoteLink.Application = link.Application.Name
+		}
+		if pull, ok := helpers.ParsePullRequestURL(remoteLink.URL); ok {
+			remoteLink.Org, remoteLink.Repo, remoteLink.PullNumber = pull.Org, pull.Repo, pull.Number
+		}
+		remoteLinks = append(remoteLinks, remoteLink)
+	}
+	return remoteLinks
+}
+
+// getPullRequests returns the pull requests referenced by the remote links, description and comments of a ticket.
+// Every pull request is returned once per source in which it appears.
+func getPullRequests(ticket *Ticket) []PullRequestReference {
+	var references []PullRequestReference
+	add := func(pulls []helpers.PullRequest, source, commentID string) {
+		for _, pull := range pulls {
+			references = append(references, PullRequestReference{
+				Org:       pull.Org,
+				Repo:      pull.Repo,
+				Number:    pull.Number,
+				URL:       pull.URL(),
+				Source:    source,
+				CommentID: commentID,
+			})
+		}
+	}
+	var linked []helpers.PullRequest
+	seen := make(map[helpers.PullRequest]bool)
+	for _, link := range ticket.RemoteLinks {
+		pull := helpers.PullRequest{Org: link.Org, Repo: link.Repo, Number: link.PullNumber}
+		if link.PullNumber == 0 || seen[pull] {
+			continue
+		}
+		seen[pull] = true
+		linked = append(linked, pull)
+	}
+	add(linked, ReferenceSourceRemoteLink, "")
+	add(helpers.FindPullRequests(ticket.Description), ReferenceSourceDescription, "")
+	for _, comment := range ticket.Comments {
+		add(helpers.FindPullRequests(comment.Message), ReferenceSourceComment, comment.ID)
+	}
+	return references
+}
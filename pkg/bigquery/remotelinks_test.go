@@ -0,0 +1,59 @@
+package bigquery
+
+import (
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"github.com/openshift/ci-search/jira"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertPullRequests(t *testing.T) {
+	issue := &jira.IssueComments{
+		Info: jiraBaseClient.Issue{ID: "1", Key: "OCPBUGS-1", Fields: &jiraBaseClient.IssueFields{
+			Description: "Regressed by https://github.com/openshift/origin/pull/28000",
+			Updated:     jiraBaseClient.Time(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)),
+		}},
+		Comments: []*jiraBaseClient.Comment{
+			{ID: "10", Body: "Fix in https://github.com/openshift/installer/pull/7890"},
+			{ID: "11", Body: "No pull request here"},
+		},
+	}
+	remoteLinks := map[string][]jiraBaseClient.RemoteLink{"1": {
+		{
+			ID:           100,
+			Relationship: "links to",
+			Application:  &jiraBaseClient.RemoteLinkApplication{Name: "GitHub"},
+			Object:       &jiraBaseClient.RemoteLinkObject{URL: "https://github.com/openshift/installer/pull/7890", Title: "OCPBUGS-1: fix the installer"},
+		},
+		{
+			ID:     101,
+			Object: &jiraBaseClient.RemoteLinkObject{URL: "https://docs.example.com/runbook", Title: "Runbook"},
+		},
+	}}
+	state, err := NewChangeStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := (&Converter{State: state}).Convert([]*jira.IssueComments{issue}, nil, remoteLinks, time.Now())
+	if len(rows.Tickets) != 1 {
+		t.Fatalf("expected one ticket, got %d", len(rows.Tickets))
+	}
+	ticket := rows.Tickets[0]
+
+	expectedLinks := []RemoteLink{
+		{ID: "100", URL: "https://github.com/openshift/installer/pull/7890", Title: "OCPBUGS-1: fix the installer", Relationship: "links to", Application: "GitHub", Org: "openshift", Repo: "installer", PullNumber: 7890},
+		{ID: "101", URL: "https://docs.example.com/runbook", Title: "Runbook"},
+	}
+	if !reflect.DeepEqual(ticket.RemoteLinks, expectedLinks) {
+		t.Errorf("expected remote links\n%#v\ngot\n%#v", expectedLinks, ticket.RemoteLinks)
+	}
+	expectedPulls := []PullRequestReference{
+		{Org: "openshift", Repo: "installer", Number: 7890, URL: "https://github.com/openshift/installer/pull/7890", Source: ReferenceSourceRemoteLink},
+		{Org: "openshift", Repo: "origin", Number: 28000, URL: "https://github.com/openshift/origin/pull/28000", Source: ReferenceSourceDescription},
+		{Org: "openshift", Repo: "installer", Number: 7890, URL: "https://github.com/openshift/installer/pull/7890", Source: ReferenceSourceComment, CommentID: "10"},
+	}
+	if !reflect.DeepEqual(ticket.PullRequests, expectedPulls) {
+		t.Errorf("expected pull requests\n%#v\ngot\n%#v", expectedPulls, ticket.PullRequests)
+	}
+}
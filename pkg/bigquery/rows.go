@@ -0,0 +1,135 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/openshift/ci-search/jira"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+// Rows holds every row produced for a batch of issues.
+type Rows struct {
+	Tickets   []*Ticket
+	Changelog []*ChangelogEntry
+	Metrics   []*TicketMetrics
+	Fields    []*FieldCatalogEntry
+}
+
+// Converter turns a batch of issues into the rows of a feed.
+type Converter struct {
+	// Catalog names the custom fields of every ticket and may be nil.
+	Catalog *helpers.FieldCatalog
+	// State remembers the issues that were written before. Issues that have not changed since are skipped, and only
+	// the changelog entries created since then are kept.
+	State *ChangeStore
+	// Redaction decides which comments and issues may be exported. DefaultRedactionPolicy is used when it is nil.
+	Redaction *helpers.RedactionPolicy
+	// Scrubber redacts secrets from the free text of every row. A scrubber with only the built-in detectors is used
+	// when it is nil.
+	Scrubber *helpers.Scrubber
+	// APIVersion is the version of the Jira API that the issues were read with, which decides whether their
+	// descriptions and comments are wiki markup or ADF.
+	APIVersion helpers.APIVersion
+}
+
+// Convert converts a batch of issues, their changelogs and their remote links, keyed by issue ID, into rows. The
+// redaction policy is applied before any conversion, so restricted text never reaches the rows, and secrets are
+// scrubbed from the rows once converted.
+func (c *Converter) Convert(issues []*jira.IssueComments, changelogs map[string][]jiraBaseClient.ChangelogHistory, remoteLinks map[string][]jiraBaseClient.RemoteLink, timestamp time.Time) *Rows {
+	policy := c.Redaction
+	if policy == nil {
+		policy = helpers.DefaultRedactionPolicy()
+	}
+	scrubber := c.Scrubber
+	if scrubber == nil {
+		scrubber, _ = helpers.NewScrubber(nil)
+	}
+	rows := &Rows{}
+	for _, issue := range issues {
+		redacted, ok := policy.RedactIssue(issue)
+		if !ok {
+			klog.V(5).Infof("JiraIssue %s is private and will not be exported", issue.Info.Key)
+			continue
+		}
+		histories := policy.RedactChangelog(&issue.Info, changelogs[issue.Info.ID])
+
+		ticket := ConvertToTicket(redacted, c.Catalog, c.APIVersion, timestamp)
+		ticket.RemoteLinks = getRemoteLinks(policy.RedactRemoteLinks(&issue.Info, remoteLinks[issue.Info.ID]))
+		ticket.PullRequests = getPullRequests(ticket)
+		ticket.CIReferences = getCIReferences(ticket)
+		ticket.PanicSignature = getPanicSignature(ticket)
+		lastWritten := c.State.LastWritten(ticket.Issue.ID)
+		if !c.State.Changed(ticket.Issue.ID, ticket.LastChangedTime) {
+			klog.V(7).Infof("JiraIssue %s has not changed since %s", ticket.Issue.Key, lastWritten)
+			continue
+		}
+		report := ScrubTicket(scrubber, ticket)
+		rows.Tickets = append(rows.Tickets, ticket)
+		for _, entry := range ConvertToChangelogEntries(redacted.Info, histories, timestamp) {
+			if entry.Created.After(lastWritten) {
+				report.Add(ScrubChangelogEntry(scrubber, entry))
+				rows.Changelog = append(rows.Changelog, entry)
+			}
+		}
+		if len(report) > 0 {
+			klog.V(2).Infof("Scrubbed %s from JiraIssue %s", report, ticket.Issue.Key)
+		}
+		rows.Metrics = append(rows.Metrics, ConvertToTicketMetrics(redacted, histories, timestamp))
+	}
+	return rows
+}
+
+// WithComments returns the issues with their comments replaced by the raw comments fetched from Jira, keyed by issue ID,
+// whenever an issue has an entry. The comments of a CommentStore are filtered by ci-search, which replaces restricted
+// comments with placeholders without a visibility, so the redaction policy must be applied to the raw comments
+// instead. Issues are copied rather than modified, since they may be shared with a CommentStore.
+func WithComments(issues []*jira.IssueComments, comments map[string][]*jiraBaseClient.Comment) []*jira.IssueComments {
+	completed := make([]*jira.IssueComments, 0, len(issues))
+	for _, issue := range issues {
+		issueComments, ok := comments[issue.Info.ID]
+		if !ok {
+			completed = append(completed, issue)
+			continue
+		}
+		copied := *issue
+		copied.Comments = issueComments
+		completed = append(completed, &copied)
+	}
+	return completed
+}
+
+// Write writes the rows to the tables of the feed.
+func (r *Rows) Write(ctx context.Context, sink TicketSink, feed Feed) error {
+	if len(r.Tickets) > 0 {
+		if err := sink.WriteRows(ctx, feed.Dataset, feed.Table, r.Tickets); err != nil {
+			return fmt.Errorf("unable to write tickets: %v", err)
+		}
+	}
+	if len(r.Changelog) > 0 {
+		if err := sink.WriteRows(ctx, feed.Dataset, feed.ChangelogTable, r.Changelog); err != nil {
+			return fmt.Errorf("unable to write changelog: %v", err)
+		}
+	}
+	if len(r.Metrics) > 0 {
+		if err := sink.WriteRows(ctx, feed.Dataset, feed.MetricsTable, r.Metrics); err != nil {
+			return fmt.Errorf("unable to write ticket metrics: %v", err)
+		}
+	}
+	if len(r.Fields) > 0 {
+		if err := sink.WriteRows(ctx, feed.Dataset, feed.FieldCatalogTable, r.Fields); err != nil {
+			return fmt.Errorf("unable to write field catalog: %v", err)
+		}
+	}
+	return nil
+}
+
+// Record marks every written ticket in the state.
+func (r *Rows) Record(state *ChangeStore) error {
+	for _, ticket := range r.Tickets {
+		state.Record(ticket.Issue.ID, ticket.LastChangedTime)
+	}
+	return state.Save()
+}
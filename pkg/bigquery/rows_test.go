@@ -0,0 +1,79 @@
+package bigquery
+
+import (
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"github.com/openshift/ci-search/jira"
+	"testing"
+	"time"
+)
+
+func TestWithComments(t *testing.T) {
+	truncated := &jira.IssueComments{
+		Info:     jiraBaseClient.Issue{ID: "1"},
+		Comments: []*jiraBaseClient.Comment{{ID: "1"}},
+	}
+	// the comment store of ci-search replaces a restricted comment with a placeholder without visibility
+	filtered := &jira.IssueComments{
+		Info:     jiraBaseClient.Issue{ID: "2"},
+		Comments: []*jiraBaseClient.Comment{{ID: "3", Body: "<private comment>"}},
+	}
+	unfetched := &jira.IssueComments{
+		Info:     jiraBaseClient.Issue{ID: "3"},
+		Comments: []*jiraBaseClient.Comment{{ID: "4"}},
+	}
+	restricted := &jiraBaseClient.Comment{ID: "3", Body: "Internal only", Visibility: jiraBaseClient.CommentVisibility{Type: "group", Value: "Red Hat Employee"}}
+	comments := map[string][]*jiraBaseClient.Comment{
+		"1": {{ID: "1"}, {ID: "2"}},
+		"2": {restricted},
+	}
+
+	issues := WithComments([]*jira.IssueComments{truncated, filtered, unfetched}, comments)
+	if len(issues[0].Comments) != 2 {
+		t.Errorf("expected the truncated comments to be replaced, got %d comments", len(issues[0].Comments))
+	}
+	if len(truncated.Comments) != 1 {
+		t.Errorf("expected the original issue to be left unmodified")
+	}
+	if len(issues[1].Comments) != 1 || issues[1].Comments[0] != restricted {
+		t.Errorf("expected the filtered comments to be replaced by the raw comments, got %#v", issues[1].Comments)
+	}
+	if filtered.Comments[0].Body != "<private comment>" {
+		t.Errorf("expected the original issue to be left unmodified")
+	}
+	if issues[2] != unfetched {
+		t.Errorf("expected an issue without fetched comments to be returned as is")
+	}
+}
+
+func TestConvertIssuesSkipsUnchanged(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	issue := &jira.IssueComments{Info: jiraBaseClient.Issue{ID: "1", Key: "OCPBUGS-1", Fields: &jiraBaseClient.IssueFields{
+		Updated: jiraBaseClient.Time(updated),
+	}}}
+	histories := map[string][]jiraBaseClient.ChangelogHistory{"1": {
+		{Id: "1", Created: "2024-03-01T10:00:00.000+0000", Items: []jiraBaseClient.ChangelogItems{{Field: "status"}}},
+		{Id: "2", Created: "2024-03-01T12:00:00.000+0000", Items: []jiraBaseClient.ChangelogItems{{Field: "assignee"}}},
+	}}
+	state, err := NewChangeStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Record("1", updated.Add(-time.Hour))
+
+	converter := &Converter{State: state}
+	rows := converter.Convert([]*jira.IssueComments{issue}, histories, nil, time.Now())
+	if len(rows.Tickets) != 1 || len(rows.Metrics) != 1 {
+		t.Fatalf("expected a changed issue to be converted, got %d tickets", len(rows.Tickets))
+	}
+	if len(rows.Changelog) != 1 || rows.Changelog[0].HistoryID != "2" {
+		t.Errorf("expected only the changelog written since the last write, got %#v", rows.Changelog)
+	}
+
+	if err := rows.Record(state); err != nil {
+		t.Fatal(err)
+	}
+	rows = converter.Convert([]*jira.IssueComments{issue}, histories, nil, time.Now())
+	if len(rows.Tickets) != 0 || len(rows.Changelog) != 0 || len(rows.Metrics) != 0 {
+		t.Errorf("expected an unchanged issue to be skipped, got %#v", rows)
+	}
+}
@@ -0,0 +1,291 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"context"
+	"errors"
+	"fmt"
+	bigqueryClient "github.com/openshift/ci-search/pkg/bigquery"
+	"google.golang.org/api/googleapi"
+	"net/http"
+	"strings"
+)
+
+// TableDefinition describes a table that holds one of the row types in this package.
+//
+// BigQuery only allows top-level, non-repeated columns to be used for clustering, so nested columns such as issue.key
+// cannot be listed in Clustering. The rows of issues repeat issue.key in the top-level issue_key column to be clustered
+// on.
+type TableDefinition struct {
+	Table          string
+	Row            interface{}
+	PartitionField string
+	Clustering     []string
+}
+
+// Schema infers the schema of the table from its row type. Every column is NULLABLE so that the schema can always be
+// migrated additively.
+func (d TableDefinition) Schema() (bigquery.Schema, error) {
+	schema, err := bigquery.InferSchema(d.Row)
+	if err != nil {
+		return nil, fmt.Errorf("unable to infer schema of table %s: %v", d.Table, err)
+	}
+	return schema.Relax(), nil
+}
+
+func (d TableDefinition) Metadata() (*bigquery.TableMetadata, error) {
+	schema, err := d.Schema()
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range d.Clustering {
+		if strings.Contains(field, ".") {
+			return nil, fmt.Errorf("table %s can not be clustered on nested column %s", d.Table, field)
+		}
+	}
+	md := &bigquery.TableMetadata{
+		Name:   d.Table,
+		Schema: schema,
+	}
+	if len(d.PartitionField) > 0 {
+		md.TimePartitioning = &bigquery.TimePartitioning{
+			Type:  bigquery.DayPartitioningType,
+			Field: d.PartitionField,
+		}
+	}
+	if len(d.Clustering) > 0 {
+		md.Clustering = &bigquery.Clustering{Fields: d.Clustering}
+	}
+	return md, nil
+}
+
+// TableMetadataClient is the subset of the BigQuery API needed to manage table schemas.
+type TableMetadataClient interface {
+	Metadata(ctx context.Context, dataset, table string) (*bigquery.TableMetadata, error)
+	Create(ctx context.Context, dataset, table string, md *bigquery.TableMetadata) error
+	Update(ctx context.Context, dataset, table string, md bigquery.TableMetadataToUpdate, etag string) (*bigquery.TableMetadata, error)
+}
+
+var ErrTableNotFound = errors.New("table not found")
+
+type tableMetadataClient struct {
+	client *bigqueryClient.Client
+}
+
+func NewTableMetadataClient(client *bigqueryClient.Client) TableMetadataClient {
+	return &tableMetadataClient{client: client}
+}
+
+func (c *tableMetadataClient) Metadata(ctx context.Context, dataset, table string) (*bigquery.TableMetadata, error) {
+	md, err := c.client.Dataset(dataset).Table(table).Metadata(ctx)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return nil, ErrTableNotFound
+	}
+	return md, err
+}
+
+func (c *tableMetadataClient) Create(ctx context.Context, dataset, table string, md *bigquery.TableMetadata) error {
+	return c.client.Dataset(dataset).Table(table).Create(ctx, md)
+}
+
+func (c *tableMetadataClient) Update(ctx context.Context, dataset, table string, md bigquery.TableMetadataToUpdate, etag string) (*bigquery.TableMetadata, error) {
+	return c.client.Dataset(dataset).Table(table).Update(ctx, md, etag)
+}
+
+type SchemaChangeType string
+
+const (
+	SchemaFieldAdded       SchemaChangeType = "added"
+	SchemaFieldRemoved     SchemaChangeType = "removed"
+	SchemaFieldTypeChanged SchemaChangeType = "type changed"
+	SchemaFieldModeChanged SchemaChangeType = "mode changed"
+)
+
+type SchemaChange struct {
+	Type     SchemaChangeType
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (c SchemaChange) String() string {
+	switch c.Type {
+	case SchemaFieldAdded:
+		return fmt.Sprintf("+ %s %s", c.Field, c.Expected)
+	case SchemaFieldRemoved:
+		return fmt.Sprintf("- %s %s", c.Field, c.Actual)
+	default:
+		return fmt.Sprintf("~ %s %s: %s -> %s", c.Field, c.Type, c.Actual, c.Expected)
+	}
+}
+
+// Additive returns true if the change can be applied to a live table without rewriting it.
+func (c SchemaChange) Additive() bool {
+	return c.Type == SchemaFieldAdded
+}
+
+// Blocking returns true if rows can no longer be written to the live table until the change is applied manually. A
+// column that only exists in the live table is left NULL by every insert, so its removal does not block.
+func (c SchemaChange) Blocking() bool {
+	return c.Type == SchemaFieldTypeChanged || c.Type == SchemaFieldModeChanged
+}
+
+// DiffSchema compares the expected schema against the live schema of a table.
+func DiffSchema(expected, actual bigquery.Schema) []SchemaChange {
+	return diffSchema("", expected, actual)
+}
+
+func diffSchema(prefix string, expected, actual bigquery.Schema) []SchemaChange {
+	var changes []SchemaChange
+	live := make(map[string]*bigquery.FieldSchema, len(actual))
+	for _, field := range actual {
+		live[field.Name] = field
+	}
+	for _, field := range expected {
+		name := prefix + field.Name
+		existing, ok := live[field.Name]
+		if !ok {
+			changes = append(changes, SchemaChange{Type: SchemaFieldAdded, Field: name, Expected: fieldType(field)})
+			continue
+		}
+		delete(live, field.Name)
+		if existing.Type != field.Type {
+			changes = append(changes, SchemaChange{Type: SchemaFieldTypeChanged, Field: name, Expected: string(field.Type), Actual: string(existing.Type)})
+			continue
+		}
+		if fieldMode(existing) != fieldMode(field) {
+			changes = append(changes, SchemaChange{Type: SchemaFieldModeChanged, Field: name, Expected: fieldMode(field), Actual: fieldMode(existing)})
+		}
+		if field.Type == bigquery.RecordFieldType {
+			changes = append(changes, diffSchema(name+".", field.Schema, existing.Schema)...)
+		}
+	}
+	for _, field := range actual {
+		if _, ok := live[field.Name]; ok {
+			changes = append(changes, SchemaChange{Type: SchemaFieldRemoved, Field: prefix + field.Name, Actual: fieldType(field)})
+		}
+	}
+	return changes
+}
+
+func fieldMode(field *bigquery.FieldSchema) string {
+	switch {
+	case field.Repeated:
+		return "REPEATED"
+	case field.Required:
+		return "REQUIRED"
+	default:
+		return "NULLABLE"
+	}
+}
+
+func fieldType(field *bigquery.FieldSchema) string {
+	return fmt.Sprintf("%s %s", fieldMode(field), field.Type)
+}
+
+// mergeSchema returns the live schema with every field that only exists in the expected schema appended.
+func mergeSchema(expected, actual bigquery.Schema) bigquery.Schema {
+	merged := make(bigquery.Schema, 0, len(expected))
+	live := make(map[string]*bigquery.FieldSchema, len(actual))
+	for _, field := range actual {
+		copied := *field
+		if field.Type == bigquery.RecordFieldType {
+			copied.Schema = append(bigquery.Schema(nil), field.Schema...)
+		}
+		merged = append(merged, &copied)
+		live[field.Name] = &copied
+	}
+	for _, field := range expected {
+		existing, ok := live[field.Name]
+		if !ok {
+			merged = append(merged, field)
+			continue
+		}
+		if existing.Type == bigquery.RecordFieldType && field.Type == bigquery.RecordFieldType {
+			existing.Schema = mergeSchema(field.Schema, existing.Schema)
+		}
+	}
+	return merged
+}
+
+// SchemaManager creates, diffs and migrates the tables of a dataset.
+type SchemaManager struct {
+	client  TableMetadataClient
+	dataset string
+}
+
+func NewSchemaManager(client TableMetadataClient, dataset string) *SchemaManager {
+	return &SchemaManager{
+		client:  client,
+		dataset: dataset,
+	}
+}
+
+// Create creates the table if it does not exist. It returns false if the table already existed.
+func (m *SchemaManager) Create(ctx context.Context, definition TableDefinition) (bool, error) {
+	_, err := m.client.Metadata(ctx, m.dataset, definition.Table)
+	switch {
+	case err == nil:
+		return false, nil
+	case !errors.Is(err, ErrTableNotFound):
+		return false, fmt.Errorf("unable to get metadata of table %s.%s: %v", m.dataset, definition.Table, err)
+	}
+	md, err := definition.Metadata()
+	if err != nil {
+		return false, err
+	}
+	if err := m.client.Create(ctx, m.dataset, definition.Table, md); err != nil {
+		return false, fmt.Errorf("unable to create table %s.%s: %v", m.dataset, definition.Table, err)
+	}
+	return true, nil
+}
+
+// Diff returns the changes needed to bring the live table in line with its row type.
+func (m *SchemaManager) Diff(ctx context.Context, definition TableDefinition) ([]SchemaChange, error) {
+	expected, err := definition.Schema()
+	if err != nil {
+		return nil, err
+	}
+	md, err := m.client.Metadata(ctx, m.dataset, definition.Table)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get metadata of table %s.%s: %w", m.dataset, definition.Table, err)
+	}
+	return DiffSchema(expected, md.Schema), nil
+}
+
+// Migrate applies the additive changes to the live table and returns them. Columns that only exist in the live table
+// are kept and not returned. A type or mode change is returned as an error and nothing is applied.
+func (m *SchemaManager) Migrate(ctx context.Context, definition TableDefinition) ([]SchemaChange, error) {
+	expected, err := definition.Schema()
+	if err != nil {
+		return nil, err
+	}
+	md, err := m.client.Metadata(ctx, m.dataset, definition.Table)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get metadata of table %s.%s: %w", m.dataset, definition.Table, err)
+	}
+	var changes []SchemaChange
+	var unsupported []string
+	for _, change := range DiffSchema(expected, md.Schema) {
+		switch {
+		case change.Blocking():
+			unsupported = append(unsupported, change.String())
+		case change.Additive():
+			changes = append(changes, change)
+		}
+	}
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("table %s.%s requires non-additive changes that must be applied manually:\n%s", m.dataset, definition.Table, strings.Join(unsupported, "\n"))
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	update := bigquery.TableMetadataToUpdate{
+		Schema: mergeSchema(expected, md.Schema),
+	}
+	if _, err := m.client.Update(ctx, m.dataset, definition.Table, update, md.ETag); err != nil {
+		return nil, fmt.Errorf("unable to update schema of table %s.%s: %v", m.dataset, definition.Table, err)
+	}
+	return changes, nil
+}
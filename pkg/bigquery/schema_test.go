@@ -0,0 +1,205 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"context"
+	"reflect"
+	"testing"
+)
+
+type fakeTableMetadataClient struct {
+	tables  map[string]*bigquery.TableMetadata
+	updates int
+}
+
+func (c *fakeTableMetadataClient) Metadata(_ context.Context, dataset, table string) (*bigquery.TableMetadata, error) {
+	md, ok := c.tables[dataset+"."+table]
+	if !ok {
+		return nil, ErrTableNotFound
+	}
+	return md, nil
+}
+
+func (c *fakeTableMetadataClient) Create(_ context.Context, dataset, table string, md *bigquery.TableMetadata) error {
+	c.tables[dataset+"."+table] = md
+	return nil
+}
+
+func (c *fakeTableMetadataClient) Update(_ context.Context, dataset, table string, md bigquery.TableMetadataToUpdate, _ string) (*bigquery.TableMetadata, error) {
+	c.updates++
+	existing := c.tables[dataset+"."+table]
+	existing.Schema = md.Schema
+	return existing, nil
+}
+
+type schemaTestRow struct {
+	Name   string `bigquery:"name"`
+	Nested struct {
+		ID  string `bigquery:"id"`
+		Key string `bigquery:"key"`
+	} `bigquery:"nested"`
+	Labels []string `bigquery:"labels"`
+}
+
+func TestSchemaManagerCreate(t *testing.T) {
+	client := &fakeTableMetadataClient{tables: map[string]*bigquery.TableMetadata{}}
+	manager := NewSchemaManager(client, "dataset")
+	definition := TableDefinition{Table: "tickets", Row: Ticket{}, PartitionField: "record_created"}
+
+	created, err := manager.Create(context.Background(), definition)
+	if err != nil || !created {
+		t.Fatalf("expected table to be created: created=%t err=%v", created, err)
+	}
+	md := client.tables["dataset.tickets"]
+	if md.TimePartitioning == nil || md.TimePartitioning.Field != "record_created" || md.TimePartitioning.Type != bigquery.DayPartitioningType {
+		t.Errorf("unexpected partitioning: %#v", md.TimePartitioning)
+	}
+	changes, err := manager.Diff(context.Background(), definition)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes after create: %v %v", changes, err)
+	}
+
+	created, err = manager.Create(context.Background(), definition)
+	if err != nil || created {
+		t.Errorf("expected existing table to be left alone: created=%t err=%v", created, err)
+	}
+
+	definition.Clustering = []string{"issue.key"}
+	if _, err := definition.Metadata(); err == nil {
+		t.Errorf("expected clustering on a nested column to be rejected")
+	}
+}
+
+func TestSchemaManagerMigrate(t *testing.T) {
+	testCases := []struct {
+		name            string
+		live            bigquery.Schema
+		expectedChanges []SchemaChange
+		// expectedDiff are the changes left after the migration
+		expectedDiff []SchemaChange
+		expectedErr  bool
+	}{
+		{
+			name: "missing top level and nested columns",
+			live: bigquery.Schema{
+				{Name: "name", Type: bigquery.StringFieldType},
+				{Name: "nested", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+					{Name: "id", Type: bigquery.StringFieldType},
+				}},
+			},
+			expectedChanges: []SchemaChange{
+				{Type: SchemaFieldAdded, Field: "nested.key", Expected: "NULLABLE STRING"},
+				{Type: SchemaFieldAdded, Field: "labels", Expected: "REPEATED STRING"},
+			},
+		},
+		{
+			name: "type change is not applied",
+			live: bigquery.Schema{
+				{Name: "name", Type: bigquery.IntegerFieldType},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "mode change is not applied",
+			live: bigquery.Schema{
+				{Name: "name", Type: bigquery.StringFieldType, Repeated: true},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "removed column is kept and does not block the missing columns",
+			live: bigquery.Schema{
+				{Name: "name", Type: bigquery.StringFieldType},
+				{Name: "nested", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+					{Name: "id", Type: bigquery.StringFieldType},
+					{Name: "key", Type: bigquery.StringFieldType},
+				}},
+				{Name: "extra", Type: bigquery.StringFieldType},
+			},
+			expectedChanges: []SchemaChange{
+				{Type: SchemaFieldAdded, Field: "labels", Expected: "REPEATED STRING"},
+			},
+			expectedDiff: []SchemaChange{
+				{Type: SchemaFieldRemoved, Field: "extra", Actual: "NULLABLE STRING"},
+			},
+		},
+		{
+			name: "only removed columns",
+			live: bigquery.Schema{
+				{Name: "name", Type: bigquery.StringFieldType},
+				{Name: "nested", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+					{Name: "id", Type: bigquery.StringFieldType},
+					{Name: "key", Type: bigquery.StringFieldType},
+				}},
+				{Name: "labels", Type: bigquery.StringFieldType, Repeated: true},
+				{Name: "extra", Type: bigquery.StringFieldType},
+			},
+			expectedDiff: []SchemaChange{
+				{Type: SchemaFieldRemoved, Field: "extra", Actual: "NULLABLE STRING"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeTableMetadataClient{tables: map[string]*bigquery.TableMetadata{
+				"dataset.table": {Schema: tc.live},
+			}}
+			manager := NewSchemaManager(client, "dataset")
+			definition := TableDefinition{Table: "table", Row: schemaTestRow{}}
+
+			changes, err := manager.Migrate(context.Background(), definition)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				if client.updates != 0 {
+					t.Errorf("expected no updates, got %d", client.updates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.expectedChanges, changes) {
+				t.Errorf("unexpected changes:\nexpected: %v\nactual:   %v", tc.expectedChanges, changes)
+			}
+			changes, err = manager.Diff(context.Background(), definition)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.expectedDiff, changes) {
+				t.Errorf("unexpected changes after migration:\nexpected: %v\nactual:   %v", tc.expectedDiff, changes)
+			}
+		})
+	}
+}
+
+func TestSchemaManagerCreateFeedTables(t *testing.T) {
+	client := &fakeTableMetadataClient{tables: map[string]*bigquery.TableMetadata{}}
+	manager := NewSchemaManager(client, "dataset")
+	feed := Feed{Table: "tickets", ChangelogTable: "tickets_changelog", MetricsTable: "tickets_metrics", FieldCatalogTable: "tickets_fields"}
+	for _, definition := range feed.TableDefinitions() {
+		if _, err := manager.Create(context.Background(), definition); err != nil {
+			t.Fatalf("unable to create table %s: %v", definition.Table, err)
+		}
+	}
+
+	for _, table := range []string{"tickets", "tickets_changelog", "tickets_metrics"} {
+		md := client.tables["dataset."+table]
+		if md.Clustering == nil || !reflect.DeepEqual(md.Clustering.Fields, []string{"issue_key"}) {
+			t.Errorf("expected table %s to be clustered on issue_key, got %#v", table, md.Clustering)
+		}
+		var clustered *bigquery.FieldSchema
+		for _, field := range md.Schema {
+			if field.Name == "issue_key" {
+				clustered = field
+			}
+		}
+		if clustered == nil || clustered.Type != bigquery.StringFieldType || clustered.Repeated {
+			t.Errorf("expected table %s to have a top-level issue_key column, got %#v", table, clustered)
+		}
+	}
+	if md := client.tables["dataset.tickets_fields"]; md.Clustering != nil {
+		t.Errorf("expected the field catalog not to be clustered, got %#v", md.Clustering)
+	}
+}
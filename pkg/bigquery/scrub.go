@@ -0,0 +1,54 @@
+package bigquery
+
+import (
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+)
+
+// ScrubTicket redacts secrets from the free text of a ticket: its summary, description, comments, remote links, CI
+// references, panic message and the text values of its custom fields.
+func ScrubTicket(scrubber *helpers.Scrubber, ticket *Ticket) helpers.ScrubReport {
+	report := helpers.ScrubReport{}
+	scrub := func(text *string) {
+		var r helpers.ScrubReport
+		*text, r = scrubber.Scrub(*text)
+		report.Add(r)
+	}
+	scrub(&ticket.Summary)
+	scrub(&ticket.Description)
+	scrub(&ticket.DescriptionText)
+	scrub(&ticket.DescriptionMarkdown)
+	for i := range ticket.Comments {
+		scrub(&ticket.Comments[i].Message)
+		scrub(&ticket.Comments[i].MessageText)
+		scrub(&ticket.Comments[i].MessageMarkdown)
+	}
+	for i := range ticket.RemoteLinks {
+		scrub(&ticket.RemoteLinks[i].URL)
+		scrub(&ticket.RemoteLinks[i].Title)
+	}
+	for i := range ticket.CIReferences {
+		scrub(&ticket.CIReferences[i].Value)
+		scrub(&ticket.CIReferences[i].URL)
+	}
+	scrub(&ticket.PanicSignature.Message)
+	for i := range ticket.CustomFields {
+		scrub(&ticket.CustomFields[i].Value)
+		scrub(&ticket.CustomFields[i].Description)
+		scrub(&ticket.CustomFields[i].StructuredValue)
+		for j := range ticket.CustomFields[i].Values {
+			scrub(&ticket.CustomFields[i].Values[j])
+		}
+	}
+	return report
+}
+
+// ScrubChangelogEntry redacts secrets from the old and new values of a changelog entry.
+func ScrubChangelogEntry(scrubber *helpers.Scrubber, entry *ChangelogEntry) helpers.ScrubReport {
+	report := helpers.ScrubReport{}
+	for _, text := range []*string{&entry.From, &entry.FromString, &entry.To, &entry.ToString} {
+		var r helpers.ScrubReport
+		*text, r = scrubber.Scrub(*text)
+		report.Add(r)
+	}
+	return report
+}
@@ -0,0 +1,73 @@
+package bigquery
+
+import (
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/openshift/ci-search/jira"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConvertScrubsSecrets(t *testing.T) {
+	const email = "jane.doe@example.com"
+	comment := &jiraBaseClient.Comment{ID: "1", Body: "ping " + email, Created: "2024-03-01T10:00:00.000+0000"}
+	issue := &jira.IssueComments{
+		Info: jiraBaseClient.Issue{ID: "1", Key: "OCPBUGS-1", Fields: &jiraBaseClient.IssueFields{
+			Summary:     "summary",
+			Description: "contact " + email,
+			Updated:     jiraBaseClient.Time(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)),
+		}},
+		Comments: []*jiraBaseClient.Comment{comment},
+	}
+	changelogs := map[string][]jiraBaseClient.ChangelogHistory{"1": {{
+		Id:      "1",
+		Created: "2024-03-01T11:00:00.000+0000",
+		Items:   []jiraBaseClient.ChangelogItems{{Field: "description", ToString: "contact " + email}},
+	}}}
+	state, err := NewChangeStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := (&Converter{State: state}).Convert([]*jira.IssueComments{issue}, changelogs, nil, time.Now())
+	if len(rows.Tickets) != 1 || len(rows.Changelog) != 1 {
+		t.Fatalf("expected one ticket and one changelog entry, got %d and %d", len(rows.Tickets), len(rows.Changelog))
+	}
+	ticket := rows.Tickets[0]
+	for _, text := range []string{ticket.Description, ticket.Comments[0].Message, rows.Changelog[0].ToString} {
+		if strings.Contains(text, email) || !strings.Contains(text, "<redacted:email>") {
+			t.Errorf("expected the email to be scrubbed, got %q", text)
+		}
+	}
+	if comment.Body != "ping "+email {
+		t.Errorf("expected the source comment to be left unmodified")
+	}
+}
+
+func TestScrubTicketCustomFieldValues(t *testing.T) {
+	const email = "jane.doe@example.com"
+	scrubber, err := helpers.NewScrubber(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket := &Ticket{CustomFields: []CustomField{{
+		FieldName: "customfield_12316243",
+		Value:     "qa, " + email,
+		Values:    []string{"qa", email},
+	}}}
+
+	report := ScrubTicket(scrubber, ticket)
+	field := ticket.CustomFields[0]
+	for _, text := range []string{field.Value, field.Values[1]} {
+		if strings.Contains(text, email) || !strings.Contains(text, "<redacted:email>") {
+			t.Errorf("expected the email to be scrubbed, got %q", text)
+		}
+	}
+	if field.Values[0] != "qa" {
+		t.Errorf("expected a value without secrets to be left unmodified, got %q", field.Values[0])
+	}
+	if report["email"] != 2 {
+		t.Errorf("expected two secrets to be reported, got %v", report)
+	}
+}
@@ -0,0 +1,167 @@
+package bigquery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	bigqueryClient "github.com/openshift/ci-search/pkg/bigquery"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// TicketSink receives the rows produced by the converters in this package. Rows is a slice of one of the row types,
+// such as []*Ticket or []*ChangelogEntry.
+type TicketSink interface {
+	WriteRows(ctx context.Context, dataset, table string, rows interface{}) error
+	Close() error
+}
+
+type bigQuerySink struct {
+	client *bigqueryClient.Client
+}
+
+// NewBigQuerySink writes rows to BigQuery with the streaming insert API.
+func NewBigQuerySink(client *bigqueryClient.Client) TicketSink {
+	return &bigQuerySink{client: client}
+}
+
+func (s *bigQuerySink) WriteRows(ctx context.Context, dataset, table string, rows interface{}) error {
+	return s.client.WriteRows(ctx, dataset, table, rows)
+}
+
+func (s *bigQuerySink) Close() error {
+	return s.client.Close()
+}
+
+// NDJSONSink appends every row as a line of JSON to <dir>/<dataset>/<table>.ndjson. Column names match the BigQuery
+// schema so the files can be loaded with `bq load --source_format=NEWLINE_DELIMITED_JSON`.
+type NDJSONSink struct {
+	dir string
+
+	lock sync.Mutex
+}
+
+func NewNDJSONSink(dir string) *NDJSONSink {
+	return &NDJSONSink{dir: dir}
+}
+
+func (s *NDJSONSink) WriteRows(_ context.Context, dataset, table string, rows interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path := filepath.Join(s.dir, dataset, table+".ndjson")
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("unable to create directory for %s: %v", path, err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		f.Close()
+		return fmt.Errorf("rows must be a slice, got %T", rows)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := encoder.Encode(toRow(v.Index(i))); err != nil {
+			f.Close()
+			return fmt.Errorf("unable to write row %d to %s: %v", i, path, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *NDJSONSink) Close() error {
+	return nil
+}
+
+// toRow converts a row type into a map keyed by the bigquery struct tags of its fields.
+func toRow(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.CanInterface() {
+		if _, ok := v.Interface().(json.Marshaler); ok {
+			return v.Interface()
+		}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		row := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("bigquery"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if len(name) == 0 {
+				name = field.Name
+			}
+			row[name] = toRow(v.Field(i))
+		}
+		return row
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		values := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, toRow(v.Index(i)))
+		}
+		return values
+	default:
+		return v.Interface()
+	}
+}
+
+type multiSink []TicketSink
+
+// NewMultiSink writes every row to all of the given sinks. A failure in one sink does not prevent the rows from being
+// written to the others.
+func NewMultiSink(sinks ...TicketSink) TicketSink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return multiSink(sinks)
+}
+
+func (s multiSink) WriteRows(ctx context.Context, dataset, table string, rows interface{}) error {
+	var errs []error
+	for _, sink := range s {
+		if err := sink.WriteRows(ctx, dataset, table, rows); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (s multiSink) Close() error {
+	var errs []error
+	for _, sink := range s {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,71 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNDJSONSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewNDJSONSink(dir)
+	created := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	rows := []*TicketMetrics{
+		{
+			RecordCreated:      created,
+			Issue:              Issue{ID: "1", Key: "OCPBUGS-1"},
+			IssueKey:           "OCPBUGS-1",
+			TimeInStatus:       []StatusDuration{{Status: "New", Seconds: 60, Entries: 1}},
+			TimeToFirstComment: bigquery.NullInt64{Int64: 30, Valid: true},
+		},
+		{
+			RecordCreated: created,
+			Issue:         Issue{ID: "2", Key: "OCPBUGS-2"},
+			IssueKey:      "OCPBUGS-2",
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if err := sink.WriteRows(context.Background(), "dataset", "metrics", rows[i:i+1]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "dataset", "metrics.ndjson"))
+	if err != nil {
+		t.Fatalf("unable to read output: %v", err)
+	}
+	expected := `{"issue":{"id":"1","key":"OCPBUGS-1"},"issue_key":"OCPBUGS-1","last_changed_time":"0001-01-01T00:00:00Z","record_created":"2024-06-01T00:00:00Z","reopens":0,"time_in_status":[{"entries":1,"seconds":60,"status":"New"}],"time_to_first_assignment":null,"time_to_first_comment":30,"time_to_resolution":null}
+{"issue":{"id":"2","key":"OCPBUGS-2"},"issue_key":"OCPBUGS-2","last_changed_time":"0001-01-01T00:00:00Z","record_created":"2024-06-01T00:00:00Z","reopens":0,"time_in_status":null,"time_to_first_assignment":null,"time_to_first_comment":null,"time_to_resolution":null}
+`
+	if string(data) != expected {
+		t.Errorf("unexpected rows:\nexpected: %s\nactual:   %s", expected, data)
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) WriteRows(context.Context, string, string, interface{}) error {
+	return errors.New("unavailable")
+}
+
+func (failingSink) Close() error {
+	return nil
+}
+
+func TestMultiSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewMultiSink(failingSink{}, NewNDJSONSink(dir))
+
+	err := sink.WriteRows(context.Background(), "dataset", "tickets", []*Ticket{{Issue: Issue{ID: "1"}}})
+	if err == nil {
+		t.Errorf("expected the failing sink to return an error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dataset", "tickets.ndjson")); err != nil {
+		t.Errorf("expected rows to be written to the remaining sinks: %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLiteSink writes every row to the table of the same name in the SQLite database <dir>/<dataset>.db. Columns match
+// the top-level columns of the BigQuery schema: scalar columns keep their type, while records and repeated columns are
+// stored as JSON text that can be queried with the JSON functions of SQLite. Tables are created on the first write,
+// and columns added to a row type since are added to the existing table.
+type SQLiteSink struct {
+	dir string
+
+	lock      sync.Mutex
+	databases map[string]*sql.DB
+	// tables are the tables whose columns were checked, as <dataset>.<table>
+	tables sets.Set[string]
+}
+
+func NewSQLiteSink(dir string) *SQLiteSink {
+	return &SQLiteSink{
+		dir:       dir,
+		databases: make(map[string]*sql.DB),
+		tables:    sets.New[string](),
+	}
+}
+
+func (s *SQLiteSink) WriteRows(ctx context.Context, dataset, table string, rows interface{}) error {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("rows must be a slice, got %T", rows)
+	}
+	if v.Len() == 0 {
+		return nil
+	}
+	rowType := v.Type().Elem()
+	for rowType.Kind() == reflect.Ptr {
+		rowType = rowType.Elem()
+	}
+	schema, err := bigquery.InferSchema(reflect.Zero(rowType).Interface())
+	if err != nil {
+		return fmt.Errorf("unable to infer schema of table %s.%s: %v", dataset, table, err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	db, err := s.database(dataset)
+	if err != nil {
+		return err
+	}
+	if err := s.createTable(ctx, db, dataset, table, schema); err != nil {
+		return err
+	}
+
+	columns := make([]string, 0, len(schema))
+	for _, field := range schema {
+		columns = append(columns, quoteIdentifier(field.Name))
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdentifier(table), strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to write rows to %s.%s: %v", dataset, table, err)
+	}
+	defer tx.Rollback()
+	for i := 0; i < v.Len(); i++ {
+		row, ok := toRow(v.Index(i)).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		values := make([]interface{}, 0, len(schema))
+		for _, field := range schema {
+			value, err := sqliteValue(field, row[field.Name])
+			if err != nil {
+				return fmt.Errorf("unable to convert column %s of row %d of %s.%s: %v", field.Name, i, dataset, table, err)
+			}
+			values = append(values, value)
+		}
+		if _, err := tx.ExecContext(ctx, insert, values...); err != nil {
+			return fmt.Errorf("unable to write row %d to %s.%s: %v", i, dataset, table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to write rows to %s.%s: %v", dataset, table, err)
+	}
+	return nil
+}
+
+func (s *SQLiteSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var errs []error
+	for dataset, db := range s.databases {
+		if err := db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(s.databases, dataset)
+	}
+	s.tables = sets.New[string]()
+	return utilerrors.NewAggregate(errs)
+}
+
+// database opens the database of the dataset once.
+func (s *SQLiteSink) database(dataset string) (*sql.DB, error) {
+	if db, ok := s.databases[dataset]; ok {
+		return db, nil
+	}
+	path := filepath.Join(s.dir, dataset+".db")
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("unable to create directory for %s: %v", path, err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %v", path, err)
+	}
+	// SQLite allows a single writer
+	db.SetMaxOpenConns(1)
+	s.databases[dataset] = db
+	return db, nil
+}
+
+// createTable creates the table, or adds the columns of the schema that the existing table lacks.
+func (s *SQLiteSink) createTable(ctx context.Context, db *sql.DB, dataset, table string, schema bigquery.Schema) error {
+	if s.tables.Has(dataset + "." + table) {
+		return nil
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", quoteIdentifier(table)))
+	if err != nil {
+		return fmt.Errorf("unable to get columns of %s.%s: %v", dataset, table, err)
+	}
+	existing := sets.New[string]()
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			rows.Close()
+			return fmt.Errorf("unable to get columns of %s.%s: %v", dataset, table, err)
+		}
+		existing.Insert(name)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("unable to get columns of %s.%s: %v", dataset, table, err)
+	}
+
+	var statements []string
+	if existing.Len() == 0 {
+		columns := make([]string, 0, len(schema))
+		for _, field := range schema {
+			columns = append(columns, quoteIdentifier(field.Name)+" "+sqliteType(field))
+		}
+		statements = append(statements, fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdentifier(table), strings.Join(columns, ", ")))
+	} else {
+		for _, field := range schema {
+			if !existing.Has(field.Name) {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdentifier(table), quoteIdentifier(field.Name), sqliteType(field)))
+			}
+		}
+	}
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("unable to create table %s.%s: %v", dataset, table, err)
+		}
+	}
+	s.tables.Insert(dataset + "." + table)
+	return nil
+}
+
+// sqliteType is the type of the column of the field. Records and repeated fields are stored as JSON text.
+func sqliteType(field *bigquery.FieldSchema) string {
+	if field.Repeated || field.Type == bigquery.RecordFieldType {
+		return "TEXT"
+	}
+	switch field.Type {
+	case bigquery.IntegerFieldType, bigquery.BooleanFieldType:
+		return "INTEGER"
+	case bigquery.FloatFieldType, bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		return "REAL"
+	case bigquery.BytesFieldType:
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+}
+
+// sqliteValue converts a value of toRow into the value of the column of the field. Times are written in UTC as
+// RFC 3339 text, so that they sort and compare as text.
+func sqliteValue(field *bigquery.FieldSchema, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if field.Repeated || field.Type == bigquery.RecordFieldType {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	case bigquery.NullTimestamp:
+		if !v.Valid {
+			return nil, nil
+		}
+		return v.Timestamp.UTC().Format(time.RFC3339Nano), nil
+	case json.Marshaler:
+		// the nullable types of the bigquery package encode NULL or their value
+		data, err := v.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	default:
+		return value, nil
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
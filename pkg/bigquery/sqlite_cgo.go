@@ -0,0 +1,6 @@
+//go:build cgo
+
+package bigquery
+
+// SQLiteSupported is true when the binary is built with cgo, which the SQLite driver requires.
+const SQLiteSupported = true
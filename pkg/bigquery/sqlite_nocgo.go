@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package bigquery
+
+// SQLiteSupported is false when the binary is built without cgo, in which case the SQLite driver is a stub that fails
+// every query.
+const SQLiteSupported = false
@@ -0,0 +1,105 @@
+//go:build cgo
+
+package bigquery
+
+import (
+	"cloud.google.com/go/bigquery"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSQLiteSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewSQLiteSink(dir)
+	created := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	rows := []*TicketMetrics{
+		{
+			RecordCreated:      created,
+			Issue:              Issue{ID: "1", Key: "OCPBUGS-1"},
+			IssueKey:           "OCPBUGS-1",
+			TimeInStatus:       []StatusDuration{{Status: "New", Seconds: 60, Entries: 1}},
+			TimeToFirstComment: bigquery.NullInt64{Int64: 30, Valid: true},
+		},
+		{
+			RecordCreated: created,
+			Issue:         Issue{ID: "2", Key: "OCPBUGS-2"},
+			IssueKey:      "OCPBUGS-2",
+			Reopens:       2,
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if err := sink.WriteRows(context.Background(), "dataset", "metrics", rows[i:i+1]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "dataset.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	result, err := db.Query(`SELECT record_created, issue, issue_key, time_in_status, time_to_first_comment, time_to_resolution, reopens FROM metrics ORDER BY issue_key`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Close()
+	var actual [][]interface{}
+	for result.Next() {
+		var recordCreated, issue, issueKey string
+		var timeInStatus sql.NullString
+		var timeToFirstComment, timeToResolution sql.NullInt64
+		var reopens int64
+		if err := result.Scan(&recordCreated, &issue, &issueKey, &timeInStatus, &timeToFirstComment, &timeToResolution, &reopens); err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, []interface{}{recordCreated, issue, issueKey, timeInStatus, timeToFirstComment, timeToResolution, reopens})
+	}
+	if err := result.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]interface{}{
+		{"2024-06-01T00:00:00Z", `{"id":"1","key":"OCPBUGS-1"}`, "OCPBUGS-1", sql.NullString{String: `[{"entries":1,"seconds":60,"status":"New"}]`, Valid: true}, sql.NullInt64{Int64: 30, Valid: true}, sql.NullInt64{}, int64(0)},
+		{"2024-06-01T00:00:00Z", `{"id":"2","key":"OCPBUGS-2"}`, "OCPBUGS-2", sql.NullString{}, sql.NullInt64{}, sql.NullInt64{}, int64(2)},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("unexpected rows:\nexpected: %v\nactual:   %v", expected, actual)
+	}
+}
+
+func TestSQLiteSinkAddsColumns(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "dataset.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// a table written before the issue_key column existed
+	if _, err := db.Exec(`CREATE TABLE tickets_changelog (record_created TEXT, issue TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := NewSQLiteSink(dir)
+	entry := &ChangelogEntry{Issue: Issue{ID: "1", Key: "OCPBUGS-1"}, IssueKey: "OCPBUGS-1", Field: "status", FromString: "New", ToString: "ASSIGNED"}
+	if err := sink.WriteRows(context.Background(), "dataset", "tickets_changelog", []*ChangelogEntry{entry}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var issueKey, toString string
+	if err := db.QueryRow(`SELECT issue_key, to_string FROM tickets_changelog`).Scan(&issueKey, &toString); err != nil {
+		t.Fatal(err)
+	}
+	if issueKey != "OCPBUGS-1" || toString != "ASSIGNED" {
+		t.Errorf("expected the row to be written to the added columns, got %s %s", issueKey, toString)
+	}
+}
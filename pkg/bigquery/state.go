@@ -0,0 +1,82 @@
+package bigquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ChangeStore remembers the last change time written for every issue so that unchanged issues are not written again.
+// When path is empty the state is only kept in memory.
+type ChangeStore struct {
+	path string
+
+	lock    sync.Mutex
+	written map[string]time.Time
+}
+
+func NewChangeStore(path string) (*ChangeStore, error) {
+	s := &ChangeStore{
+		path:    path,
+		written: make(map[string]time.Time),
+	}
+	if len(path) == 0 {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read change state %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &s.written); err != nil {
+		return nil, fmt.Errorf("unable to parse change state %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// LastWritten returns the change time of the issue that was last written, or the zero time.
+func (s *ChangeStore) LastWritten(id string) time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.written[id]
+}
+
+// Changed returns true if the issue has changed since it was last written.
+func (s *ChangeStore) Changed(id string, changed time.Time) bool {
+	last := s.LastWritten(id)
+	return last.IsZero() || changed.After(last)
+}
+
+func (s *ChangeStore) Record(id string, changed time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if changed.After(s.written[id]) {
+		s.written[id] = changed
+	}
+}
+
+// Save atomically persists the state to disk.
+func (s *ChangeStore) Save() error {
+	if len(s.path) == 0 {
+		return nil
+	}
+	s.lock.Lock()
+	data, err := json.Marshal(s.written)
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,39 @@
+package bigquery
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChangeStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "default.json")
+	changed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	state, err := NewChangeStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Changed("1", changed) {
+		t.Errorf("expected an issue that was never written to be changed")
+	}
+	state.Record("1", changed)
+	state.Record("1", changed.Add(-time.Hour))
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewChangeStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.LastWritten("1").Equal(changed) {
+		t.Errorf("expected last written time %s, got %s", changed, loaded.LastWritten("1"))
+	}
+	if loaded.Changed("1", changed) {
+		t.Errorf("expected an issue with the same change time to be unchanged")
+	}
+	if !loaded.Changed("1", changed.Add(time.Second)) {
+		t.Errorf("expected an issue with a later change time to be changed")
+	}
+}
@@ -0,0 +1,210 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/openshift/ci-search/jira"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Syncer buffers the issues that the CommentStore reports as changed and periodically flushes them to a TicketSink.
+// It satisfies jira.PersistentCommentStore so that it can be handed directly to jira.NewCommentStore.
+type Syncer struct {
+	store     jira.CommentAccessor
+	issues    cache.Store
+	backend   helpers.SearchBackend
+	sink      TicketSink
+	converter *Converter
+	feed      Feed
+	dryRun    bool
+
+	// catalogWritten is set once the field catalog, which is fetched on the first flush, has been written.
+	catalogWritten bool
+
+	lock    sync.Mutex
+	pending sets.Set[int]
+}
+
+// NewSyncer creates a Syncer for the feed that converts issues with converter. The field catalog of the converter is
+// fetched on the first flush if it is not set.
+func NewSyncer(backend helpers.SearchBackend, sink TicketSink, converter *Converter, feed Feed, dryRun bool) *Syncer {
+	return &Syncer{
+		backend:   backend,
+		sink:      sink,
+		converter: converter,
+		feed:      feed,
+		dryRun:    dryRun,
+		pending:   sets.New[int](),
+	}
+}
+
+// SetStore sets the accessor used to look up the latest state of a changed issue at flush time.
+func (s *Syncer) SetStore(store jira.CommentAccessor) {
+	s.store = store
+}
+
+// SetInformer makes the syncer follow the updates of the issues of the informer. The CommentStore only takes the fields
+// of an issue from the informer when the issue is added, so without it a change delivered by a watch would only be
+// synced after the next list.
+func (s *Syncer) SetInformer(informer cache.SharedIndexInformer) error {
+	s.issues = informer.GetStore()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, new interface{}) {
+			previous, ok := old.(*jira.Issue)
+			if !ok {
+				return
+			}
+			issue, ok := new.(*jira.Issue)
+			if !ok || issue.ResourceVersion == previous.ResourceVersion {
+				return
+			}
+			if id, err := strconv.Atoi(issue.Name); err == nil {
+				s.NotifyChanged(id)
+			}
+		},
+	})
+	return err
+}
+
+// latest returns the issue with the fields last delivered by the informer, if it is set.
+func (s *Syncer) latest(issue *jira.IssueComments) *jira.IssueComments {
+	if s.issues == nil {
+		return issue
+	}
+	obj, ok, err := s.issues.GetByKey(issue.Name)
+	if err != nil || !ok {
+		return issue
+	}
+	updated := issue.DeepCopyObject().(*jira.IssueComments)
+	updated.Info = obj.(*jira.Issue).Info
+	return updated
+}
+
+func (s *Syncer) Sync(keys []string) ([]*jira.IssueComments, error) {
+	return nil, nil
+}
+
+func (s *Syncer) NotifyChanged(id int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.pending.Insert(id)
+}
+
+func (s *Syncer) DeleteIssue(*jira.Issue) error {
+	return nil
+}
+
+func (s *Syncer) CloseIssue(issue *jira.IssueComments) error {
+	id, err := strconv.Atoi(issue.Info.ID)
+	if err != nil {
+		return fmt.Errorf("unable to parse issue id %q: %v", issue.Info.ID, err)
+	}
+	s.NotifyChanged(id)
+	return nil
+}
+
+// Run flushes the buffered issues every refresh interval of the feed until the context is cancelled, then performs a
+// final flush so that no buffered rows are lost on shutdown.
+func (s *Syncer) Run(ctx context.Context) {
+	defer klog.V(2).Infof("Syncer for feed %s exited", s.feed.Name)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.Flush(ctx); err != nil {
+			klog.Errorf("Unable to sync issues of feed %s: %v", s.feed.Name, err)
+		}
+	}, s.feed.RefreshInterval.Duration)
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := s.Flush(flushCtx); err != nil {
+		klog.Errorf("Unable to sync remaining issues of feed %s: %v", s.feed.Name, err)
+	}
+}
+
+// Flush converts every pending issue into a Ticket, its changelog into ChangelogEntry rows and both into TicketMetrics
+// and writes them to the sink. Issues that fail to sync are returned to the pending set and retried on the next flush.
+func (s *Syncer) Flush(ctx context.Context) error {
+	s.lock.Lock()
+	ids := sets.List(s.pending)
+	s.pending = sets.New[int]()
+	s.lock.Unlock()
+
+	if len(ids) == 0 {
+		klog.V(5).Infof("No changed issues to sync")
+		return nil
+	}
+
+	timestamp := time.Now()
+	issues := make([]*jira.IssueComments, 0, len(ids))
+	issueIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		issue, ok := s.store.Get(id)
+		if !ok {
+			klog.V(5).Infof("JiraIssue %d is no longer in the comment store", id)
+			continue
+		}
+		issue = s.latest(issue)
+		if !s.converter.State.Changed(issue.Info.ID, getUpdatedTime(issue.Info.Fields.Updated)) {
+			klog.V(7).Infof("JiraIssue %s has not changed since it was last written", issue.Info.Key)
+			continue
+		}
+		issues = append(issues, issue)
+		issueIDs = append(issueIDs, issue.Info.ID)
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+
+	changelogs, err := helpers.IssueChangelogs(ctx, s.backend, issueIDs...)
+	if err != nil {
+		s.requeue(ids)
+		return fmt.Errorf("unable to get issue changelogs: %v", err)
+	}
+	comments, err := helpers.IssueComments(ctx, s.backend, issueIDs...)
+	if err != nil {
+		s.requeue(ids)
+		return fmt.Errorf("unable to get issue comments: %v", err)
+	}
+	issues = WithComments(issues, comments)
+	remoteLinks, err := helpers.IssueRemoteLinks(ctx, s.backend, issueIDs...)
+	if err != nil {
+		s.requeue(ids)
+		return fmt.Errorf("unable to get issue remote links: %v", err)
+	}
+	if s.converter.Catalog == nil {
+		if s.converter.Catalog, err = helpers.GetFieldCatalog(ctx, s.backend.Client()); err != nil {
+			klog.Warningf("Custom fields of feed %s will not be named: %v", s.feed.Name, err)
+		}
+	}
+	rows := s.converter.Convert(issues, changelogs, remoteLinks, timestamp)
+	if !s.catalogWritten {
+		rows.Fields = ConvertToFieldCatalogEntries(s.converter.Catalog, timestamp)
+	}
+
+	if s.dryRun {
+		klog.Infof("[Dry Run] Syncing %d issues and %d changelog entries of feed %s", len(rows.Tickets), len(rows.Changelog), s.feed.Name)
+		return nil
+	}
+	klog.V(5).Infof("Syncing %d issues and %d changelog entries of feed %s", len(rows.Tickets), len(rows.Changelog), s.feed.Name)
+	if err := rows.Write(ctx, s.sink, s.feed); err != nil {
+		s.requeue(ids)
+		return err
+	}
+	s.catalogWritten = len(rows.Fields) > 0 || s.catalogWritten
+	if err := rows.Record(s.converter.State); err != nil {
+		return fmt.Errorf("unable to save change state of feed %s: %v", s.feed.Name, err)
+	}
+	return nil
+}
+
+func (s *Syncer) requeue(ids []int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.pending.Insert(ids...)
+}
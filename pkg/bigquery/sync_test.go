@@ -0,0 +1,175 @@
+package bigquery
+
+import (
+	"context"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/bradmwilliams/jira-migration/pkg/jira/fakejira"
+	"github.com/openshift/ci-search/jira"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// recordingSink keeps the rows written to every table.
+type recordingSink struct {
+	rows map[string][]interface{}
+}
+
+func (s *recordingSink) WriteRows(_ context.Context, dataset, table string, rows interface{}) error {
+	value := reflect.ValueOf(rows)
+	for i := 0; i < value.Len(); i++ {
+		s.rows[dataset+"."+table] = append(s.rows[dataset+"."+table], value.Index(i).Interface())
+	}
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	return nil
+}
+
+// commentAccessor is a comment store of issues keyed by ID.
+type commentAccessor map[int]*jira.IssueComments
+
+func (a commentAccessor) Get(id int) (*jira.IssueComments, bool) {
+	issue, ok := a[id]
+	return issue, ok
+}
+
+func TestSyncerFlushDropsRestrictedComments(t *testing.T) {
+	dataset, err := fakejira.LoadDataset("../jira/fakejira/testdata/dataset.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, err := fakejira.New(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	options := &helpers.JiraOptions{Endpoint: server.URL}
+	if err := options.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	client, err := options.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := helpers.NewSearchBackend(context.Background(), client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue, err := client.GetIssue("OCPBUGS-35865")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := strconv.Atoi(issue.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the comment store of ci-search replaces the restricted comment with a placeholder without visibility
+	var comments []*jiraBaseClient.Comment
+	for _, comment := range issue.Fields.Comments.Comments {
+		if len(comment.Visibility.Value) > 0 {
+			comment = &jiraBaseClient.Comment{ID: comment.ID, Body: helpers.PrivateCommentPlaceholder, Author: jiraBaseClient.User{DisplayName: helpers.PrivateAuthorPlaceholder}, Created: comment.Created, Updated: comment.Updated}
+		}
+		comments = append(comments, comment)
+	}
+	stored := jira.NewIssueComments(issue.ID, &jiraBaseClient.Comments{Comments: comments})
+	stored.Info = *issue
+
+	state, err := NewChangeStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redaction := &helpers.RedactionPolicy{Rules: []helpers.VisibilityRule{{Type: "group", Value: "Red Hat Employee", Action: helpers.RedactionDrop}}}
+	redaction.Complete()
+	sink := &recordingSink{rows: make(map[string][]interface{})}
+	feed := Feed{Name: "ocpbugs", Dataset: "jira_data", Table: "tickets", ChangelogTable: "tickets_changelog", MetricsTable: "tickets_metrics", FieldCatalogTable: "tickets_fields"}
+	syncer := NewSyncer(backend, sink, &Converter{State: state, Redaction: redaction}, feed, false)
+	syncer.SetStore(commentAccessor{id: stored})
+	syncer.NotifyChanged(id)
+	if err := syncer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tickets := sink.rows["jira_data.tickets"]
+	if len(tickets) != 1 {
+		t.Fatalf("expected one ticket, got %d", len(tickets))
+	}
+	var ids []string
+	for _, comment := range tickets[0].(*Ticket).Comments {
+		if comment.Message == helpers.PrivateCommentPlaceholder {
+			t.Errorf("expected no placeholder comment, got %#v", comment)
+		}
+		ids = append(ids, comment.ID)
+	}
+	if expected := []string{"25000001", "25000003"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected the comments %v, got %v", expected, ids)
+	}
+}
+
+// TestSyncerFollowsInformer checks that an issue updated by a watch of the informer is marked as changed and flushed
+// with the fields of the update rather than those the comment store took when the issue was added.
+func TestSyncerFollowsInformer(t *testing.T) {
+	issue := func(resourceVersion, summary string) *jira.Issue {
+		return &jira.Issue{
+			ObjectMeta: metav1.ObjectMeta{Name: "1", ResourceVersion: resourceVersion},
+			Info:       jiraBaseClient.Issue{ID: "1", Key: "OCPBUGS-1", Fields: &jiraBaseClient.IssueFields{Summary: summary}},
+		}
+	}
+	watcher := watch.NewFake()
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(metav1.ListOptions) (runtime.Object, error) {
+			return &jira.IssueList{Items: []jira.Issue{*issue("1", "Console is slow")}}, nil
+		},
+		WatchFunc: func(metav1.ListOptions) (watch.Interface, error) {
+			return watcher, nil
+		},
+	}, &jira.Issue{}, 0, nil)
+
+	syncer := NewSyncer(nil, nil, &Converter{}, Feed{Name: "ocpbugs"}, false)
+	if err := syncer.SetInformer(informer); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go informer.Run(stop)
+	if !cache.WaitForCacheSync(stop, informer.HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+	if syncer.pending.Len() != 0 {
+		t.Errorf("expected a listed issue not to be marked as changed, got %v", sets.List(syncer.pending))
+	}
+
+	watcher.Modify(issue("2", "Console shows a stale route"))
+	if err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 10*time.Second, true, func(context.Context) (bool, error) {
+		syncer.lock.Lock()
+		defer syncer.lock.Unlock()
+		return syncer.pending.Has(1), nil
+	}); err != nil {
+		t.Fatalf("expected the updated issue to be marked as changed: %v", err)
+	}
+
+	stored := &jira.IssueComments{
+		ObjectMeta: metav1.ObjectMeta{Name: "1"},
+		Info:       issue("1", "Console is slow").Info,
+		Comments:   []*jiraBaseClient.Comment{{ID: "10", Body: "Still slow"}},
+	}
+	latest := syncer.latest(stored)
+	if latest.Info.Fields.Summary != "Console shows a stale route" {
+		t.Errorf("expected the fields of the update, got %q", latest.Info.Fields.Summary)
+	}
+	if len(latest.Comments) != 1 || stored.Info.Fields.Summary != "Console is slow" {
+		t.Errorf("expected the comments to be kept and the stored issue to be unchanged, got %#v and %#v", latest.Comments, stored.Info.Fields)
+	}
+}
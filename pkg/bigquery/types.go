@@ -10,15 +10,11 @@ import (
 	"k8s.io/klog/v2"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
-const (
-	BigqueryDatasetId = "jira_data"
-	BigqueryTableId   = "tickets_bradwi"
-)
-
 type Issue struct {
 	ID  string `bigquery:"id"`
 	Key string `bigquery:"key"`
@@ -44,11 +40,25 @@ type Version struct {
 	Name string `bigquery:"name"`
 }
 
+// CommentVisibility restricts a comment to a group or project role. Both fields are empty for public comments.
+type CommentVisibility struct {
+	Type  string `bigquery:"type"`
+	Value string `bigquery:"value"`
+}
+
+// Comment is a comment of an issue. Message holds the raw body, while MessageText and MessageMarkdown hold the body
+// rendered from Jira wiki markup.
 type Comment struct {
-	ID      string    `bigquery:"id"`
-	Author  string    `bigquery:"author"`
-	Created time.Time `bigquery:"created"`
-	Message string    `bigquery:"message"`
+	ID              string            `bigquery:"id"`
+	Author          string            `bigquery:"author"`
+	AuthorName      string            `bigquery:"author_name"`
+	AuthorAccountID string            `bigquery:"author_account_id"`
+	Created         time.Time         `bigquery:"created"`
+	Updated         time.Time         `bigquery:"updated"`
+	Message         string            `bigquery:"message"`
+	MessageText     string            `bigquery:"message_text"`
+	MessageMarkdown string            `bigquery:"message_markdown"`
+	Visibility      CommentVisibility `bigquery:"visibility"`
 }
 
 type Component struct {
@@ -56,85 +66,225 @@ type Component struct {
 	Name string `bigquery:"name"`
 }
 
+// IssueLink is a link to another issue. Direction is inward or outward and Relation is the description of the link
+// from this issue, such as "blocks" or "is cloned by".
+type IssueLink struct {
+	ID           string `bigquery:"id"`
+	Type         string `bigquery:"type"`
+	Direction    string `bigquery:"direction"`
+	Relation     string `bigquery:"relation"`
+	LinkedIssue  Issue  `bigquery:"linked_issue"`
+	LinkedStatus Status `bigquery:"linked_status"`
+}
+
+type Subtask struct {
+	Issue  Issue  `bigquery:"issue"`
+	Status Status `bigquery:"status"`
+}
+
+// CustomField is the value of a custom field of an issue. FieldName is the ID of the field, such as
+// customfield_12319940, while Name, SchemaType and SchemaCustomType are filled in from the field catalog. Fields with a
+// known schema are decoded into the typed NumericValue, TimestampValue and Values columns.
 type CustomField struct {
-	FieldName       string  `bigquery:"field_name" json:"field_name,omitempty"`
-	ID              string  `bigquery:"id" json:"id,omitempty"`
-	Name            string  `bigquery:"name" json:"name,omitempty"`
-	Key             string  `bigquery:"key" json:"key,omitempty"`
-	DisplayName     string  `bigquery:"display_name" json:"display_name,omitempty"`
-	Description     string  `bigquery:"description" json:"description,omitempty"`
-	Value           string  `bigquery:"value" json:"value,omitempty"`
-	Votes           float64 `bigquery:"votes" json:"votes,omitempty"`
-	StructuredValue string  `bigquery:"structured_value" json:"structured_value,omitempty"`
+	FieldName        string                 `bigquery:"field_name" json:"field_name,omitempty"`
+	ID               string                 `bigquery:"id" json:"id,omitempty"`
+	Name             string                 `bigquery:"name" json:"name,omitempty"`
+	Key              string                 `bigquery:"key" json:"key,omitempty"`
+	DisplayName      string                 `bigquery:"display_name" json:"display_name,omitempty"`
+	Description      string                 `bigquery:"description" json:"description,omitempty"`
+	Value            string                 `bigquery:"value" json:"value,omitempty"`
+	Votes            float64                `bigquery:"votes" json:"votes,omitempty"`
+	StructuredValue  string                 `bigquery:"structured_value" json:"structured_value,omitempty"`
+	SchemaType       string                 `bigquery:"schema_type" json:"schema_type,omitempty"`
+	SchemaCustomType string                 `bigquery:"schema_custom_type" json:"schema_custom_type,omitempty"`
+	NumericValue     bigquery.NullFloat64   `bigquery:"numeric_value" json:"-"`
+	TimestampValue   bigquery.NullTimestamp `bigquery:"timestamp_value" json:"-"`
+	Values           []string               `bigquery:"values" json:"-"`
 }
 
+// Ticket is a single version of an issue. Description holds the raw description on a single line, while
+// DescriptionText and DescriptionMarkdown hold the description rendered from Jira wiki markup. CreatorID and
+// AssigneeID identify users by name on Jira Server and by account ID on Jira Cloud. IssueKey repeats issue.key as a
+// top-level column that the table can be clustered on.
 type Ticket struct {
-	RecordCreated   time.Time     `bigquery:"record_created"`
-	Issue           Issue         `bigquery:"issue"`
-	Description     string        `bigquery:"description"`
-	Creator         string        `bigquery:"creator"`
-	Assignee        string        `bigquery:"assignee"`
-	Status          Status        `bigquery:"status"`
-	Priority        Priority      `bigquery:"priority"`
-	Labels          []string      `bigquery:"labels"`
-	TargetVersions  []Version     `bigquery:"target_versions"`
-	Resolution      Resolution    `bigquery:"resolution"`
-	Comments        []Comment     `bigquery:"comments"`
-	Summary         string        `bigquery:"summary"`
-	Components      []Component   `bigquery:"components"`
-	FixVersions     []Version     `bigquery:"fix_versions"`
-	AffectsVersions []Version     `bigquery:"affects_versions"`
-	LastChangedTime time.Time     `bigquery:"last_changed_time"`
-	CustomFields    []CustomField `bigquery:"custom_fields"`
+	RecordCreated       time.Time              `bigquery:"record_created"`
+	Issue               Issue                  `bigquery:"issue"`
+	IssueKey            string                 `bigquery:"issue_key"`
+	Description         string                 `bigquery:"description"`
+	DescriptionText     string                 `bigquery:"description_text"`
+	DescriptionMarkdown string                 `bigquery:"description_markdown"`
+	Creator             string                 `bigquery:"creator"`
+	CreatorID           string                 `bigquery:"creator_id"`
+	Assignee            string                 `bigquery:"assignee"`
+	AssigneeID          string                 `bigquery:"assignee_id"`
+	Status              Status                 `bigquery:"status"`
+	Priority            Priority               `bigquery:"priority"`
+	Labels              []string               `bigquery:"labels"`
+	TargetVersions      []Version              `bigquery:"target_versions"`
+	Resolution          Resolution             `bigquery:"resolution"`
+	Comments            []Comment              `bigquery:"comments"`
+	Summary             string                 `bigquery:"summary"`
+	Components          []Component            `bigquery:"components"`
+	FixVersions         []Version              `bigquery:"fix_versions"`
+	AffectsVersions     []Version              `bigquery:"affects_versions"`
+	LastChangedTime     time.Time              `bigquery:"last_changed_time"`
+	CustomFields        []CustomField          `bigquery:"custom_fields"`
+	IssueLinks          []IssueLink            `bigquery:"issue_links"`
+	Subtasks            []Subtask              `bigquery:"subtasks"`
+	Parent              Issue                  `bigquery:"parent"`
+	Epic                Issue                  `bigquery:"epic"`
+	RemoteLinks         []RemoteLink           `bigquery:"remote_links"`
+	PullRequests        []PullRequestReference `bigquery:"pull_requests"`
+	CIReferences        []CIReference          `bigquery:"ci_references"`
+	PanicSignature      PanicSignature         `bigquery:"panic_signature"`
 }
 
 func (t *Ticket) Save() (map[string]bigquery.Value, string, error) {
 	return map[string]bigquery.Value{
-		"record_created":    t.RecordCreated,
-		"issue":             t.Issue,
-		"description":       t.Description,
-		"creator":           t.Creator,
-		"assignee":          t.Assignee,
-		"status":            t.Status,
-		"priority":          t.Priority,
-		"labels":            t.Labels,
-		"target_versions":   t.TargetVersions,
-		"resolution":        t.Resolution,
-		"comments":          t.Comments,
-		"summary":           t.Summary,
-		"components":        t.Components,
-		"fix_versions":      t.FixVersions,
-		"affects_versions":  t.AffectsVersions,
-		"last_changed_time": t.LastChangedTime,
-		"custom_fields":     t.CustomFields,
-	}, bigquery.NoDedupeID, nil
-}
-
-func ConvertToTicket(issueComments *jira.IssueComments, timestamp time.Time) *Ticket {
+		"record_created":       t.RecordCreated,
+		"issue":                t.Issue,
+		"issue_key":            t.IssueKey,
+		"description":          t.Description,
+		"description_text":     t.DescriptionText,
+		"description_markdown": t.DescriptionMarkdown,
+		"creator":              t.Creator,
+		"creator_id":           t.CreatorID,
+		"assignee":             t.Assignee,
+		"assignee_id":          t.AssigneeID,
+		"status":               t.Status,
+		"priority":             t.Priority,
+		"labels":               t.Labels,
+		"target_versions":      t.TargetVersions,
+		"resolution":           t.Resolution,
+		"comments":             t.Comments,
+		"summary":              t.Summary,
+		"components":           t.Components,
+		"fix_versions":         t.FixVersions,
+		"affects_versions":     t.AffectsVersions,
+		"last_changed_time":    t.LastChangedTime,
+		"custom_fields":        t.CustomFields,
+		"issue_links":          t.IssueLinks,
+		"subtasks":             t.Subtasks,
+		"parent":               t.Parent,
+		"epic":                 t.Epic,
+		"remote_links":         t.RemoteLinks,
+		"pull_requests":        t.PullRequests,
+		"ci_references":        t.CIReferences,
+		"panic_signature":      t.PanicSignature,
+	}, t.InsertID(), nil
+}
+
+// InsertID identifies a version of the ticket so that BigQuery drops the row if the same version is streamed twice.
+func (t *Ticket) InsertID() string {
+	return insertID(t.Issue.ID, t.LastChangedTime)
+}
+
+func insertID(issueID string, changed time.Time) string {
+	return fmt.Sprintf("%s-%d", issueID, changed.UnixNano())
+}
+
+// ConvertToTicket converts an issue into a Ticket. The catalog is used to name the custom fields and may be nil. The
+// description and comments are rendered as wiki markup or ADF depending on the API version they were read with.
+func ConvertToTicket(issueComments *jira.IssueComments, catalog *helpers.FieldCatalog, version helpers.APIVersion, timestamp time.Time) *Ticket {
+	description := helpers.ParseDocument(version, issueComments.Info.Fields.Description)
 	return &Ticket{
 		RecordCreated: timestamp,
 		Issue: Issue{
 			ID:  issueComments.Info.ID,
 			Key: issueComments.Info.Key,
 		},
-		Description:     helpers.LineSafe(issueComments.Info.Fields.Description),
-		Creator:         helpers.UserFieldDisplayName(issueComments.Info.Fields.Creator),
-		Assignee:        helpers.UserFieldDisplayName(issueComments.Info.Fields.Assignee),
-		Status:          getStatus(issueComments.Info.Fields.Status),
-		Priority:        getPriority(issueComments.Info.Fields.Priority),
-		Labels:          helpers.ArrayLineSafe(issueComments.Info.Fields.Labels),
-		TargetVersions:  getTargetVersions(issueComments.Info),
-		Resolution:      getResolution(issueComments.Info.Fields.Resolution),
-		Comments:        getComments(issueComments.Comments),
-		Summary:         helpers.LineSafe(issueComments.Info.Fields.Summary),
-		Components:      getComponents(issueComments.Info.Fields.Components),
-		FixVersions:     getFixVersions(issueComments.Info.Fields.FixVersions),
-		AffectsVersions: getAffectsVersions(issueComments.Info.Fields.AffectsVersions),
-		LastChangedTime: getUpdatedTime(issueComments.Info.Fields.Updated),
-		CustomFields:    getCustomFields(issueComments.Info),
+		IssueKey:            issueComments.Info.Key,
+		Description:         helpers.LineSafe(issueComments.Info.Fields.Description),
+		DescriptionText:     description.PlainText(),
+		DescriptionMarkdown: description.Markdown(),
+		Creator:             helpers.UserFieldDisplayName(issueComments.Info.Fields.Creator),
+		CreatorID:           helpers.UserID(issueComments.Info.Fields.Creator),
+		Assignee:            helpers.UserFieldDisplayName(issueComments.Info.Fields.Assignee),
+		AssigneeID:          helpers.UserID(issueComments.Info.Fields.Assignee),
+		Status:              getStatus(issueComments.Info.Fields.Status),
+		Priority:            getPriority(issueComments.Info.Fields.Priority),
+		Labels:              helpers.ArrayLineSafe(issueComments.Info.Fields.Labels),
+		TargetVersions:      getTargetVersions(issueComments.Info),
+		Resolution:          getResolution(issueComments.Info.Fields.Resolution),
+		Comments:            getComments(issueComments.Comments, version),
+		Summary:             helpers.LineSafe(issueComments.Info.Fields.Summary),
+		Components:          getComponents(issueComments.Info.Fields.Components),
+		FixVersions:         getFixVersions(issueComments.Info.Fields.FixVersions),
+		AffectsVersions:     getAffectsVersions(issueComments.Info.Fields.AffectsVersions),
+		LastChangedTime:     getUpdatedTime(issueComments.Info.Fields.Updated),
+		CustomFields:        getCustomFields(issueComments.Info, catalog),
+		IssueLinks:          getIssueLinks(issueComments.Info.Fields.IssueLinks),
+		Subtasks:            getSubtasks(issueComments.Info.Fields.Subtasks),
+		Parent:              getParent(issueComments.Info.Fields.Parent),
+		Epic:                getEpic(issueComments.Info, catalog),
 	}
 }
 
+func getIssueLinks(links []*jiraBaseClient.IssueLink) []IssueLink {
+	issueLinks := make([]IssueLink, 0, len(links))
+	for _, link := range links {
+		if link == nil {
+			continue
+		}
+		issueLink := IssueLink{
+			ID:   link.ID,
+			Type: link.Type.Name,
+		}
+		linked := link.OutwardIssue
+		issueLink.Direction, issueLink.Relation = "outward", link.Type.Outward
+		if linked == nil {
+			linked = link.InwardIssue
+			issueLink.Direction, issueLink.Relation = "inward", link.Type.Inward
+		}
+		if linked == nil {
+			continue
+		}
+		issueLink.LinkedIssue = Issue{ID: linked.ID, Key: linked.Key}
+		if linked.Fields != nil {
+			issueLink.LinkedStatus = getStatus(linked.Fields.Status)
+		}
+		issueLinks = append(issueLinks, issueLink)
+	}
+	return issueLinks
+}
+
+func getSubtasks(s []*jiraBaseClient.Subtasks) []Subtask {
+	subtasks := make([]Subtask, 0, len(s))
+	for _, subtask := range s {
+		if subtask == nil {
+			continue
+		}
+		subtasks = append(subtasks, Subtask{
+			Issue:  Issue{ID: subtask.ID, Key: subtask.Key},
+			Status: getStatus(subtask.Fields.Status),
+		})
+	}
+	return subtasks
+}
+
+func getParent(p *jiraBaseClient.Parent) Issue {
+	if p == nil {
+		return Issue{}
+	}
+	return Issue{ID: p.ID, Key: p.Key}
+}
+
+const epicLinkCustomType = "com.pyxis.greenhopper.jira:gh-epic-link"
+
+// getEpic returns the epic of the issue. Jira Server stores the key of the epic in the Epic Link custom field, which is
+// found through the catalog, while Jira Cloud returns the epic as a field of its own.
+func getEpic(i jiraBaseClient.Issue, catalog *helpers.FieldCatalog) Issue {
+	if field, ok := catalog.FieldByCustomType(epicLinkCustomType); ok {
+		if key, ok := i.Fields.Unknowns[field.ID].(string); ok && len(key) > 0 {
+			return Issue{Key: key}
+		}
+	}
+	if i.Fields.Epic != nil {
+		return Issue{ID: strconv.Itoa(i.Fields.Epic.ID), Key: i.Fields.Epic.Key}
+	}
+	return Issue{}
+}
+
 func getStatus(s *jiraBaseClient.Status) Status {
 	status := Status{}
 	if s != nil {
@@ -183,15 +333,25 @@ func getTargetVersions(i jiraBaseClient.Issue) []Version {
 	return versions
 }
 
-func getComments(issueComments []*jiraBaseClient.Comment) []Comment {
+func getComments(issueComments []*jiraBaseClient.Comment, version helpers.APIVersion) []Comment {
 	comments := make([]Comment, 0, len(issueComments))
 	for _, comment := range issueComments {
 		escapedText := strings.ReplaceAll(strings.ReplaceAll(comment.Body, "\x00", " "), "\x1e", " ")
+		message := helpers.ParseDocument(version, escapedText)
 		comments = append(comments, Comment{
-			ID:      comment.ID,
-			Author:  helpers.CommentAuthor(comment.Author.DisplayName),
-			Created: getCreatedTime(comment.Created),
-			Message: escapedText,
+			ID:              comment.ID,
+			Author:          helpers.CommentAuthor(comment.Author.DisplayName),
+			AuthorName:      comment.Author.Name,
+			AuthorAccountID: comment.Author.AccountID,
+			Created:         getCreatedTime(comment.Created),
+			Updated:         getCreatedTime(comment.Updated),
+			Message:         escapedText,
+			MessageText:     message.PlainText(),
+			MessageMarkdown: message.Markdown(),
+			Visibility: CommentVisibility{
+				Type:  comment.Visibility.Type,
+				Value: comment.Visibility.Value,
+			},
 		})
 	}
 	return comments
@@ -251,16 +411,23 @@ func getUpdatedTime(updated jiraBaseClient.Time) time.Time {
 	return t
 }
 
-func getCustomFields(i jiraBaseClient.Issue) []CustomField {
+func getCustomFields(i jiraBaseClient.Issue, catalog *helpers.FieldCatalog) []CustomField {
 	var customFields []CustomField
 	for k, v := range i.Fields.Unknowns {
 		if v == nil {
 			continue
 		}
-		field := processCustomFieldValue(k, v)
-		if field != nil {
-			customFields = append(customFields, *field)
+		definition, ok := catalog.Field(k)
+		field := decodeCustomField(k, v, definition.Schema)
+		if field == nil {
+			continue
 		}
+		if ok {
+			field.Name = definition.Name
+			field.SchemaType = definition.Schema.Type
+			field.SchemaCustomType = definition.Schema.Custom
+		}
+		customFields = append(customFields, *field)
 	}
 	return customFields
 }
@@ -274,7 +441,7 @@ func processCustomFieldValue(name string, value interface{}) *CustomField {
 	case int:
 		valueStr = fmt.Sprintf("%d", value)
 	case float64:
-		valueStr = fmt.Sprintf("%f", value)
+		valueStr = strconv.FormatFloat(t, 'f', -1, 64)
 	case string:
 		valueStr = fmt.Sprintf("%s", value)
 	case bool:
@@ -289,9 +456,11 @@ func processCustomFieldValue(name string, value interface{}) *CustomField {
 	case map[string]interface{}:
 		field = getCustomField(name, value)
 	default:
-		var r = reflect.TypeOf(t)
-		klog.Warningf("Unknown CustomField type: %v", r)
-		return nil
+		klog.V(4).Infof("Unknown CustomField type %v of %s, storing it as JSON", reflect.TypeOf(t), name)
+		return &CustomField{
+			FieldName:       name,
+			StructuredValue: generateBigQueryJson(value),
+		}
 	}
 
 	switch {
@@ -0,0 +1,99 @@
+package bigquery
+
+import (
+	"context"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"github.com/openshift/ci-search/jira"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertToTicketHierarchy(t *testing.T) {
+	blocks := jiraBaseClient.IssueLinkType{Name: "Blocks", Inward: "is blocked by", Outward: "blocks"}
+	issue := &jira.IssueComments{Info: jiraBaseClient.Issue{
+		ID:  "1",
+		Key: "OCPBUGS-1",
+		Fields: &jiraBaseClient.IssueFields{
+			IssueLinks: []*jiraBaseClient.IssueLink{
+				{ID: "10", Type: blocks, OutwardIssue: &jiraBaseClient.Issue{ID: "2", Key: "OCPBUGS-2", Fields: &jiraBaseClient.IssueFields{Status: &jiraBaseClient.Status{ID: "1", Name: "New"}}}},
+				{ID: "11", Type: blocks, InwardIssue: &jiraBaseClient.Issue{ID: "3", Key: "OCPBUGS-3"}},
+			},
+			Subtasks: []*jiraBaseClient.Subtasks{
+				{ID: "4", Key: "OCPBUGS-4", Fields: jiraBaseClient.IssueFields{Status: &jiraBaseClient.Status{ID: "6", Name: "Closed"}}},
+			},
+			Parent:   &jiraBaseClient.Parent{ID: "5", Key: "OCPBUGS-5"},
+			Unknowns: map[string]interface{}{"customfield_12311140": "OCPBUGS-6"},
+		},
+	}}
+	catalog := helpers.NewFieldCatalog([]jiraBaseClient.Field{{
+		ID:     "customfield_12311140",
+		Name:   "Epic Link",
+		Schema: jiraBaseClient.FieldSchema{Type: "any", Custom: epicLinkCustomType},
+	}})
+
+	ticket := ConvertToTicket(issue, catalog, helpers.APIVersion2, time.Now())
+	expectedLinks := []IssueLink{
+		{ID: "10", Type: "Blocks", Direction: "outward", Relation: "blocks", LinkedIssue: Issue{ID: "2", Key: "OCPBUGS-2"}, LinkedStatus: Status{ID: "1", Name: "New"}},
+		{ID: "11", Type: "Blocks", Direction: "inward", Relation: "is blocked by", LinkedIssue: Issue{ID: "3", Key: "OCPBUGS-3"}},
+	}
+	if !reflect.DeepEqual(ticket.IssueLinks, expectedLinks) {
+		t.Errorf("expected links %#v, got %#v", expectedLinks, ticket.IssueLinks)
+	}
+	expectedSubtasks := []Subtask{{Issue: Issue{ID: "4", Key: "OCPBUGS-4"}, Status: Status{ID: "6", Name: "Closed"}}}
+	if !reflect.DeepEqual(ticket.Subtasks, expectedSubtasks) {
+		t.Errorf("expected subtasks %#v, got %#v", expectedSubtasks, ticket.Subtasks)
+	}
+	if ticket.Parent != (Issue{ID: "5", Key: "OCPBUGS-5"}) {
+		t.Errorf("unexpected parent %#v", ticket.Parent)
+	}
+	if ticket.Epic != (Issue{Key: "OCPBUGS-6"}) {
+		t.Errorf("unexpected epic %#v", ticket.Epic)
+	}
+}
+
+// TestConvertToTicketCassette converts an issue replayed from a cassette, as it was read from Jira.
+func TestConvertToTicketCassette(t *testing.T) {
+	options := &helpers.JiraOptions{
+		Endpoint:     "https://issues.example.com",
+		CassettePath: "../jira/testdata/cassettes/OCPBUGS-35865.jsonl",
+		CassetteMode: string(helpers.CassetteReplay),
+	}
+	client, err := options.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := helpers.GetFieldCatalog(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue, err := client.GetIssue("OCPBUGS-35865")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticket := ConvertToTicket(&jira.IssueComments{Info: *issue}, catalog, helpers.APIVersion2, time.Now())
+	if expected := (Status{ID: "10020", Name: "ASSIGNED"}); ticket.Status != expected {
+		t.Errorf("expected status %v, got %v", expected, ticket.Status)
+	}
+	if expected := []Version{{ID: "12400001", Name: "4.17.0"}}; !reflect.DeepEqual(ticket.TargetVersions, expected) {
+		t.Errorf("expected target versions %v, got %v", expected, ticket.TargetVersions)
+	}
+	if expected := []Version{{ID: "12400010", Name: "4.14"}}; !reflect.DeepEqual(ticket.AffectsVersions, expected) {
+		t.Errorf("expected affected versions %v, got %v", expected, ticket.AffectsVersions)
+	}
+	customFields := make(map[string]CustomField)
+	for _, field := range ticket.CustomFields {
+		customFields[field.FieldName] = field
+	}
+	for _, expected := range []CustomField{
+		{FieldName: "customfield_12313441", ID: "27227", Name: "Release Blocker", Value: "Approved", SchemaType: "option", SchemaCustomType: "com.atlassian.jira.plugin.system.customfieldtypes:select"},
+		{FieldName: "customfield_12316243", Name: "QA Contact", Key: "qa", DisplayName: "QA Contact", Value: "qa", SchemaType: "user", SchemaCustomType: "com.atlassian.jira.plugin.system.customfieldtypes:userpicker"},
+		{FieldName: "customfield_12319940", Name: "Target Version", Value: "4.17.0", Values: []string{"4.17.0"}, SchemaType: "array", SchemaCustomType: "com.atlassian.jira.plugin.system.customfieldtypes:multiversion"},
+	} {
+		if actual := customFields[expected.FieldName]; !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected custom field %#v, got %#v", expected, actual)
+		}
+	}
+}
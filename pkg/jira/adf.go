@@ -0,0 +1,446 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIVersion is the version of the Jira REST API in use. Version 2 returns descriptions and comment bodies as wiki
+// markup, while version 3, which is only available on Jira Cloud, returns them as Atlassian Document Format.
+type APIVersion string
+
+const (
+	APIVersion2 APIVersion = "2"
+	APIVersion3 APIVersion = "3"
+)
+
+func (v APIVersion) Validate() error {
+	switch v {
+	case APIVersion2, APIVersion3:
+		return nil
+	default:
+		return fmt.Errorf("API version %q must be %s or %s", v, APIVersion2, APIVersion3)
+	}
+}
+
+// Document is a rich text body, such as the description or a comment of an issue, that can be rendered as plain text
+// or Markdown.
+type Document interface {
+	PlainText() string
+	Markdown() string
+}
+
+// ParseDocument parses a description or comment body returned by the given API version. Bodies returned by version 3
+// hold the ADF document as JSON; any body that is not a valid ADF document, such as a redaction placeholder, is parsed
+// as wiki markup.
+func ParseDocument(version APIVersion, body string) Document {
+	if version == APIVersion3 {
+		if doc, err := ParseADF([]byte(body)); err == nil {
+			return doc
+		}
+	}
+	return ParseWiki(body)
+}
+
+// EncodeDocument returns the body to send when writing text to a description or comment with the given API version:
+// the text itself for version 2 and an ADF document for version 3.
+func EncodeDocument(version APIVersion, text string) interface{} {
+	if version == APIVersion3 {
+		return NewADFDocument(text)
+	}
+	return text
+}
+
+// ADFNode is a node of an Atlassian Document Format document. The root of a document is a node of type doc.
+type ADFNode struct {
+	Type    string                 `json:"type"`
+	Version int                    `json:"version,omitempty"`
+	Text    string                 `json:"text,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Marks   []ADFMark              `json:"marks,omitempty"`
+	Content []*ADFNode             `json:"content,omitempty"`
+}
+
+// ADFMark is a text effect, such as strong or link, applied to a text node.
+type ADFMark struct {
+	Type  string                 `json:"type"`
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// ParseADF parses an ADF document.
+func ParseADF(data []byte) (*ADFNode, error) {
+	doc := &ADFNode{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("invalid ADF document: %w", err)
+	}
+	if doc.Type != "doc" {
+		return nil, fmt.Errorf("invalid ADF document: expected a node of type doc, got %q", doc.Type)
+	}
+	return doc, nil
+}
+
+// NewADFDocument encodes text as an ADF document. Paragraphs are separated by blank lines, the lines of a paragraph
+// are joined with hard breaks and blocks fenced with ``` become code blocks.
+func NewADFDocument(text string) *ADFNode {
+	doc := &ADFNode{Type: "doc", Version: 1, Content: []*ADFNode{}}
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		node := &ADFNode{Type: "paragraph"}
+		for i, line := range paragraph {
+			if i > 0 {
+				node.Content = append(node.Content, &ADFNode{Type: "hardBreak"})
+			}
+			if len(line) > 0 {
+				node.Content = append(node.Content, &ADFNode{Type: "text", Text: line})
+			}
+		}
+		doc.Content = append(doc.Content, node)
+		paragraph = nil
+	}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "```"):
+			flush()
+			node := &ADFNode{Type: "codeBlock"}
+			if language := strings.TrimSpace(strings.TrimPrefix(line, "```")); len(language) > 0 {
+				node.Attrs = map[string]interface{}{"language": language}
+			}
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(lines[i], "```"); i++ {
+				code = append(code, lines[i])
+			}
+			if len(code) > 0 {
+				node.Content = []*ADFNode{{Type: "text", Text: strings.Join(code, "\n")}}
+			}
+			doc.Content = append(doc.Content, node)
+		case len(strings.TrimSpace(line)) == 0:
+			flush()
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+	return doc
+}
+
+// PlainText renders the document as text without markup, for full-text analysis.
+func (n *ADFNode) PlainText() string {
+	return renderADFBlocks(n.Content, false)
+}
+
+// Markdown renders the document as CommonMark with GitHub flavored tables and strikethrough.
+func (n *ADFNode) Markdown() string {
+	return renderADFBlocks(n.Content, true)
+}
+
+// adfInlineNodes are the node types that render as part of a line of text.
+var adfInlineNodes = map[string]bool{
+	"text":        true,
+	"hardBreak":   true,
+	"mention":     true,
+	"emoji":       true,
+	"inlineCard":  true,
+	"date":        true,
+	"status":      true,
+	"placeholder": true,
+}
+
+func renderADFBlocks(nodes []*ADFNode, markdown bool) string {
+	var rendered []string
+	for i := 0; i < len(nodes); i++ {
+		// stray inline nodes are rendered together as a paragraph
+		if adfInlineNodes[nodes[i].Type] {
+			j := i
+			for j < len(nodes) && adfInlineNodes[nodes[j].Type] {
+				j++
+			}
+			if s := renderADFInline(nodes[i:j], markdown); len(s) > 0 {
+				rendered = append(rendered, s)
+			}
+			i = j - 1
+			continue
+		}
+		if s := renderADFBlock(nodes[i], markdown); len(s) > 0 {
+			rendered = append(rendered, s)
+		}
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+func renderADFBlock(node *ADFNode, markdown bool) string {
+	switch node.Type {
+	case "paragraph":
+		return renderADFInline(node.Content, markdown)
+	case "heading":
+		text := renderADFInline(node.Content, markdown)
+		if markdown {
+			level := int(adfNumber(node.Attrs, "level"))
+			if level < 1 || level > 6 {
+				level = 1
+			}
+			return strings.Repeat("#", level) + " " + text
+		}
+		return text
+	case "codeBlock":
+		var code strings.Builder
+		for _, child := range node.Content {
+			code.WriteString(child.Text)
+		}
+		if markdown {
+			return "```" + adfString(node.Attrs, "language") + "\n" + code.String() + "\n```"
+		}
+		return code.String()
+	case "blockquote":
+		inner := renderADFBlocks(node.Content, markdown)
+		if !markdown {
+			return inner
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return strings.Join(lines, "\n")
+	case "bulletList", "orderedList":
+		return strings.Join(renderADFList(node, "", markdown), "\n")
+	case "table":
+		return renderADFTable(node, markdown)
+	case "rule":
+		if markdown {
+			return "---"
+		}
+		return ""
+	case "expand", "nestedExpand":
+		blocks := renderADFBlocks(node.Content, markdown)
+		if title := adfString(node.Attrs, "title"); len(title) > 0 {
+			return strings.TrimSpace(title + "\n\n" + blocks)
+		}
+		return blocks
+	case "media", "mediaGroup", "mediaSingle", "mediaInline", "blockCard", "embedCard":
+		if url := adfString(node.Attrs, "url"); len(url) > 0 {
+			if markdown {
+				return "<" + url + ">"
+			}
+			return url
+		}
+		return renderADFBlocks(node.Content, markdown)
+	default:
+		// panels, layouts and any node added to ADF later only add decoration, so their content is rendered in place
+		return renderADFBlocks(node.Content, markdown)
+	}
+}
+
+// renderADFList renders a list with every item prefixed by indent. Nested lists are indented to the text of their
+// parent item, as CommonMark requires.
+func renderADFList(list *ADFNode, indent string, markdown bool) []string {
+	var lines []string
+	ordered := list.Type == "orderedList"
+	number := 1
+	if order := int(adfNumber(list.Attrs, "order")); order > 0 {
+		number = order
+	}
+	for _, item := range list.Content {
+		marker := "-"
+		if ordered {
+			marker = fmt.Sprintf("%d.", number)
+			number++
+		}
+		var text []string
+		var nested []string
+		for _, child := range item.Content {
+			switch child.Type {
+			case "bulletList", "orderedList":
+				nested = append(nested, renderADFList(child, indent+strings.Repeat(" ", len(marker)+1), markdown)...)
+			default:
+				if s := renderADFBlock(child, markdown); len(s) > 0 {
+					text = append(text, s)
+				}
+			}
+		}
+		lines = append(lines, indent+marker+" "+strings.Join(text, " "))
+		lines = append(lines, nested...)
+	}
+	return lines
+}
+
+func renderADFTable(table *ADFNode, markdown bool) string {
+	var lines []string
+	for i, row := range table.Content {
+		cells := make([]string, 0, len(row.Content))
+		for _, cell := range row.Content {
+			text := strings.Join(strings.Fields(renderADFBlocks(cell.Content, markdown)), " ")
+			if markdown {
+				text = strings.ReplaceAll(text, "|", `\|`)
+			}
+			cells = append(cells, text)
+		}
+		if !markdown {
+			lines = append(lines, strings.Join(cells, " | "))
+			continue
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		// Markdown tables always start with a header row
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", len(cells)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderADFInline(nodes []*ADFNode, markdown bool) string {
+	var text strings.Builder
+	for _, node := range nodes {
+		switch node.Type {
+		case "text":
+			text.WriteString(renderADFText(node, markdown))
+		case "hardBreak":
+			if markdown {
+				// a single newline is a soft break in Markdown
+				text.WriteString("  ")
+			}
+			text.WriteString("\n")
+		case "mention":
+			name := adfString(node.Attrs, "text")
+			if len(name) == 0 {
+				name = adfString(node.Attrs, "id")
+			}
+			if !strings.HasPrefix(name, "@") {
+				name = "@" + name
+			}
+			text.WriteString(name)
+		case "emoji":
+			if s := adfString(node.Attrs, "text"); len(s) > 0 {
+				text.WriteString(s)
+			} else {
+				text.WriteString(adfString(node.Attrs, "shortName"))
+			}
+		case "inlineCard":
+			url := adfString(node.Attrs, "url")
+			if markdown && len(url) > 0 {
+				url = "<" + url + ">"
+			}
+			text.WriteString(url)
+		case "date":
+			// the timestamp of a date is in milliseconds since the epoch
+			ms, err := strconv.ParseInt(adfString(node.Attrs, "timestamp"), 10, 64)
+			if err == nil {
+				text.WriteString(time.UnixMilli(ms).UTC().Format("2006-01-02"))
+			}
+		case "status":
+			text.WriteString(adfString(node.Attrs, "text"))
+		default:
+			text.WriteString(renderADFInline(node.Content, markdown))
+		}
+	}
+	return text.String()
+}
+
+// renderADFText applies the marks of a text node. Code is applied innermost and links outermost, matching the order
+// in which the Jira editor nests them.
+func renderADFText(node *ADFNode, markdown bool) string {
+	text := node.Text
+	marks := make(map[string]ADFMark, len(node.Marks))
+	for _, mark := range node.Marks {
+		marks[mark.Type] = mark
+	}
+	if markdown {
+		if _, ok := marks["code"]; ok {
+			text = "`" + text + "`"
+		}
+		for _, effect := range []struct{ mark, marker string }{{"strike", "~~"}, {"em", "_"}, {"strong", "**"}} {
+			if _, ok := marks[effect.mark]; ok {
+				text = effect.marker + text + effect.marker
+			}
+		}
+	}
+	if link, ok := marks["link"]; ok {
+		href := adfString(link.Attrs, "href")
+		switch {
+		case len(href) == 0:
+		case markdown:
+			text = "[" + text + "](" + href + ")"
+		case href != node.Text:
+			text = text + " (" + href + ")"
+		}
+	}
+	return text
+}
+
+func adfString(attrs map[string]interface{}, key string) string {
+	switch v := attrs[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	default:
+		return ""
+	}
+}
+
+func adfNumber(attrs map[string]interface{}, key string) float64 {
+	n, _ := strconv.ParseFloat(adfString(attrs, key), 64)
+	return n
+}
+
+// adfDocumentMarker is a cheap check for responses that may hold ADF documents, so that the responses of version 2 are
+// decoded without being rewritten.
+var adfDocumentMarker = regexp.MustCompile(`"type"\s*:\s*"doc"`)
+
+// flattenADF replaces every ADF document in a JSON response with a string holding the document as JSON, so that
+// responses of version 3 decode into the types of go-jira, which expect descriptions and comment bodies to be strings.
+// ParseDocument parses the string back into the document.
+func flattenADF(data []byte) ([]byte, error) {
+	if !adfDocumentMarker.Match(data) {
+		return data, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	v, err := flattenADFValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func flattenADFValue(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if value["type"] == "doc" {
+			if _, ok := value["content"].([]interface{}); ok {
+				doc, err := json.Marshal(value)
+				return string(doc), err
+			}
+		}
+		for key, child := range value {
+			flattened, err := flattenADFValue(child)
+			if err != nil {
+				return nil, err
+			}
+			value[key] = flattened
+		}
+	case []interface{}:
+		for i, child := range value {
+			flattened, err := flattenADFValue(child)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = flattened
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testADFDocument = `{
+  "type": "doc",
+  "version": 1,
+  "content": [
+    {"type": "heading", "attrs": {"level": 2}, "content": [{"type": "text", "text": "Summary"}]},
+    {"type": "paragraph", "content": [
+      {"type": "text", "text": "The "},
+      {"type": "text", "text": "install", "marks": [{"type": "strong"}]},
+      {"type": "text", "text": " failed, see "},
+      {"type": "text", "text": "the job", "marks": [{"type": "link", "attrs": {"href": "https://prow.ci.openshift.org/job"}}]},
+      {"type": "hardBreak"},
+      {"type": "mention", "attrs": {"id": "5b10a2844c20165700ede21g", "text": "@Jane Doe"}},
+      {"type": "text", "text": " run "},
+      {"type": "text", "text": "oc get pods", "marks": [{"type": "code"}]}
+    ]},
+    {"type": "codeBlock", "attrs": {"language": "yaml"}, "content": [{"type": "text", "text": "kind: Pod\nname: *not-bold*"}]},
+    {"type": "bulletList", "content": [
+      {"type": "listItem", "content": [
+        {"type": "paragraph", "content": [{"type": "text", "text": "one"}]},
+        {"type": "orderedList", "content": [
+          {"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "nested"}]}]}
+        ]}
+      ]},
+      {"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "two", "marks": [{"type": "strike"}]}]}]}
+    ]},
+    {"type": "table", "content": [
+      {"type": "tableRow", "content": [
+        {"type": "tableHeader", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "Job"}]}]},
+        {"type": "tableHeader", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "Result"}]}]}
+      ]},
+      {"type": "tableRow", "content": [
+        {"type": "tableCell", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "e2e-aws"}]}]},
+        {"type": "tableCell", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "failed", "marks": [{"type": "em"}]}]}]}
+      ]}
+    ]},
+    {"type": "panel", "attrs": {"panelType": "info"}, "content": [
+      {"type": "blockquote", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "quoted"}]}]}
+    ]}
+  ]
+}`
+
+func TestADF(t *testing.T) {
+	doc, err := ParseADF([]byte(testADFDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainText := "Summary\n\n" +
+		"The install failed, see the job (https://prow.ci.openshift.org/job)\n@Jane Doe run oc get pods\n\n" +
+		"kind: Pod\nname: *not-bold*\n\n" +
+		"- one\n  1. nested\n- two\n\n" +
+		"Job | Result\ne2e-aws | failed\n\n" +
+		"quoted"
+	if actual := doc.PlainText(); actual != plainText {
+		t.Errorf("expected plain text\n%q\ngot\n%q", plainText, actual)
+	}
+	markdown := "## Summary\n\n" +
+		"The **install** failed, see [the job](https://prow.ci.openshift.org/job)  \n@Jane Doe run `oc get pods`\n\n" +
+		"```yaml\nkind: Pod\nname: *not-bold*\n```\n\n" +
+		"- one\n  1. nested\n- ~~two~~\n\n" +
+		"| Job | Result |\n| --- | --- |\n| e2e-aws | _failed_ |\n\n" +
+		"> quoted"
+	if actual := doc.Markdown(); actual != markdown {
+		t.Errorf("expected markdown\n%q\ngot\n%q", markdown, actual)
+	}
+}
+
+func TestNewADFDocument(t *testing.T) {
+	doc := NewADFDocument("First line\nsecond line\n\n```bash\noc get pods\n```\nlast")
+	expected := &ADFNode{Type: "doc", Version: 1, Content: []*ADFNode{
+		{Type: "paragraph", Content: []*ADFNode{
+			{Type: "text", Text: "First line"},
+			{Type: "hardBreak"},
+			{Type: "text", Text: "second line"},
+		}},
+		{Type: "codeBlock", Attrs: map[string]interface{}{"language": "bash"}, Content: []*ADFNode{{Type: "text", Text: "oc get pods"}}},
+		{Type: "paragraph", Content: []*ADFNode{{Type: "text", Text: "last"}}},
+	}}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Fatalf("unexpected document: %#v", doc)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseADF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := parsed.PlainText(), "First line\nsecond line\n\noc get pods\n\nlast"; actual != expected {
+		t.Errorf("expected a round trip to render %q, got %q", expected, actual)
+	}
+}
+
+func TestParseDocument(t *testing.T) {
+	adf := `{"type":"doc","version":1,"content":[{"type":"paragraph","content":[{"type":"text","text":"*plain*"}]}]}`
+	tests := []struct {
+		name     string
+		version  APIVersion
+		body     string
+		expected string
+	}{
+		{name: "wiki markup on version 2", version: APIVersion2, body: "*bold*", expected: "bold"},
+		{name: "ADF on version 3", version: APIVersion3, body: adf, expected: "*plain*"},
+		{name: "placeholder on version 3", version: APIVersion3, body: PrivateIssuePlaceholder, expected: PrivateIssuePlaceholder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := ParseDocument(tt.version, tt.body).PlainText(); actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFlattenADF(t *testing.T) {
+	response := `{"comments":[{"id":"1","body":{"type":"doc","version":1,"content":[{"type":"paragraph","content":[{"type":"text","text":"hello"}]}]}}]}`
+	data, err := flattenADF([]byte(response))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page commentPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatalf("expected the flattened response to decode into go-jira comments: %v", err)
+	}
+	if len(page.Comments) != 1 {
+		t.Fatalf("expected one comment, got %d", len(page.Comments))
+	}
+	if actual := ParseDocument(APIVersion3, page.Comments[0].Body).PlainText(); actual != "hello" {
+		t.Errorf("expected the comment body to round trip, got %q", actual)
+	}
+
+	unchanged := []byte(`{"comments":[{"id":"1","body":"*wiki*"}]}`)
+	if data, err := flattenADF(unchanged); err != nil || string(data) != string(unchanged) {
+		t.Errorf("expected a version 2 response to be unchanged, got %s: %v", data, err)
+	}
+}
@@ -0,0 +1,282 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"k8s.io/klog/v2"
+	"net/url"
+	jiraClient "sigs.k8s.io/prow/prow/jira"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deployment is the type of a Jira deployment as reported by /serverInfo.
+type Deployment string
+
+const (
+	DeploymentServer Deployment = "Server"
+	DeploymentCloud  Deployment = "Cloud"
+)
+
+// ServerInfo is the response of the /serverInfo endpoint.
+type ServerInfo struct {
+	BaseURL        string     `json:"baseUrl"`
+	Version        string     `json:"version"`
+	DeploymentType Deployment `json:"deploymentType"`
+	ServerTitle    string     `json:"serverTitle"`
+	// ServerTime is the current time of the server, in its time zone.
+	ServerTime string `json:"serverTime,omitempty"`
+	// ServerTimeZone is the name of the time zone of the server, which only some versions report.
+	ServerTimeZone string `json:"serverTimeZone,omitempty"`
+}
+
+// serverTimeFormat is the format of ServerTime.
+const serverTimeFormat = "2006-01-02T15:04:05.000-0700"
+
+// Location returns the time zone that the server interprets the dates of JQL queries in, by default, from its name or
+// else the offset of its current time. It returns UTC when the server reports neither.
+func (i *ServerInfo) Location() *time.Location {
+	if len(i.ServerTimeZone) > 0 {
+		if location, err := time.LoadLocation(i.ServerTimeZone); err == nil {
+			return location
+		}
+	}
+	if now, err := time.Parse(serverTimeFormat, i.ServerTime); err == nil {
+		_, offset := now.Zone()
+		return time.FixedZone(now.Format("-0700"), offset)
+	}
+	return time.UTC
+}
+
+// GetServerInfo returns the version and deployment type of the Jira instance. Jira Data Center reports itself as
+// Server.
+func GetServerInfo(ctx context.Context, client jiraClient.Client) (*ServerInfo, error) {
+	info := &ServerInfo{}
+	if err := getJSON(ctx, client, "rest/api/2/serverInfo", info); err != nil {
+		return nil, fmt.Errorf("unable to get server info: %w", err)
+	}
+	return info, nil
+}
+
+// DefaultSearchFields are the fields requested by a search that names none. Without fields, the enhanced search of
+// Jira Cloud returns only the ID of every issue, while Jira Server returns the navigable fields without the comments.
+var DefaultSearchFields = []string{"*navigable", "comment"}
+
+// SearchRequest is a request for a single page of issues matching a JQL query.
+type SearchRequest struct {
+	JQL string
+	// Fields are the fields of the issues to return, DefaultSearchFields when empty.
+	Fields     []string
+	Expand     string
+	MaxResults int
+	// Position is where the page starts. It is empty for the first page and the Next of the previous page otherwise.
+	Position string
+}
+
+// SearchResponse is a single page of issues. The issues are left encoded so that callers can decode only the fields
+// they requested.
+type SearchResponse struct {
+	Issues []json.RawMessage
+	// Total is the number of issues matching the query, or -1 if the backend does not report it.
+	Total int
+	// Next is the position of the next page. It is empty once the last page has been read.
+	Next string
+}
+
+// SearchBackend runs JQL searches with the pagination and API version of a Jira deployment. Jira Server pages with
+// startAt and reports the total, while the enhanced search of Jira Cloud pages with an opaque nextPageToken and does
+// not.
+type SearchBackend interface {
+	Deployment() Deployment
+	APIVersion() APIVersion
+	Client() jiraClient.Client
+	Search(ctx context.Context, request SearchRequest) (*SearchResponse, error)
+	// Offset returns the position at which a search that skips the first startAt issues begins and the number of
+	// issues that must still be skipped from there, for backends that cannot seek.
+	Offset(startAt int) (position string, skip int)
+}
+
+// NewSearchBackend detects the deployment from /serverInfo and returns its search backend. An empty version selects
+// version 2 on Jira Server and version 3 on Jira Cloud.
+func NewSearchBackend(ctx context.Context, client jiraClient.Client, version APIVersion) (SearchBackend, error) {
+	info, err := GetServerInfo(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	klog.V(2).Infof("Detected Jira %s %s at %s", info.DeploymentType, info.Version, info.BaseURL)
+	return NewSearchBackendFor(client, info.DeploymentType, version)
+}
+
+// NewSearchBackendFor returns the search backend of the given deployment without detecting it.
+func NewSearchBackendFor(client jiraClient.Client, deployment Deployment, version APIVersion) (SearchBackend, error) {
+	if len(version) > 0 {
+		if err := version.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	switch deployment {
+	case DeploymentCloud:
+		if len(version) == 0 {
+			version = APIVersion3
+		}
+		return &cloudSearch{client: client, version: version}, nil
+	case DeploymentServer:
+		if version == APIVersion3 {
+			return nil, fmt.Errorf("API version %s is only available on Jira Cloud", version)
+		}
+		return &serverSearch{client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported Jira deployment %q", deployment)
+	}
+}
+
+type serverSearch struct {
+	client jiraClient.Client
+}
+
+func (s *serverSearch) Deployment() Deployment    { return DeploymentServer }
+func (s *serverSearch) APIVersion() APIVersion    { return APIVersion2 }
+func (s *serverSearch) Client() jiraClient.Client { return s.client }
+
+func (s *serverSearch) Offset(startAt int) (string, int) {
+	if startAt <= 0 {
+		return "", 0
+	}
+	return strconv.Itoa(startAt), 0
+}
+
+func (s *serverSearch) Search(ctx context.Context, request SearchRequest) (*SearchResponse, error) {
+	startAt := 0
+	if len(request.Position) > 0 {
+		var err error
+		if startAt, err = strconv.Atoi(request.Position); err != nil {
+			return nil, fmt.Errorf("invalid search position %q: %w", request.Position, err)
+		}
+	}
+	values := searchValues(request)
+	values.Set("startAt", strconv.Itoa(startAt))
+
+	var result struct {
+		StartAt int               `json:"startAt"`
+		Total   int               `json:"total"`
+		Issues  []json.RawMessage `json:"issues"`
+	}
+	if err := getJSON(ctx, s.client, "rest/api/2/search?"+values.Encode(), &result); err != nil {
+		return nil, err
+	}
+	response := &SearchResponse{Issues: result.Issues, Total: result.Total}
+	if next := startAt + len(result.Issues); len(result.Issues) > 0 && next < result.Total {
+		response.Next = strconv.Itoa(next)
+	}
+	return response, nil
+}
+
+type cloudSearch struct {
+	client  jiraClient.Client
+	version APIVersion
+}
+
+func (s *cloudSearch) Deployment() Deployment    { return DeploymentCloud }
+func (s *cloudSearch) APIVersion() APIVersion    { return s.version }
+func (s *cloudSearch) Client() jiraClient.Client { return s.client }
+
+// Offset always starts at the first page, since page tokens cannot be derived from an offset.
+func (s *cloudSearch) Offset(startAt int) (string, int) {
+	if startAt < 0 {
+		startAt = 0
+	}
+	return "", startAt
+}
+
+func (s *cloudSearch) Search(ctx context.Context, request SearchRequest) (*SearchResponse, error) {
+	values := searchValues(request)
+	if len(request.Position) > 0 {
+		values.Set("nextPageToken", request.Position)
+	}
+
+	var result struct {
+		Issues        []json.RawMessage `json:"issues"`
+		NextPageToken string            `json:"nextPageToken"`
+		IsLast        bool              `json:"isLast"`
+	}
+	if err := getJSON(ctx, s.client, fmt.Sprintf("rest/api/%s/search/jql?%s", s.version, values.Encode()), &result); err != nil {
+		return nil, err
+	}
+	response := &SearchResponse{Issues: result.Issues, Total: -1}
+	if !result.IsLast && len(result.Issues) > 0 {
+		response.Next = result.NextPageToken
+	}
+	return response, nil
+}
+
+func searchValues(request SearchRequest) url.Values {
+	values := url.Values{}
+	values.Set("jql", request.JQL)
+	fields := request.Fields
+	if len(fields) == 0 {
+		fields = DefaultSearchFields
+	}
+	values.Set("fields", strings.Join(fields, ","))
+	if len(request.Expand) > 0 {
+		values.Set("expand", request.Expand)
+	}
+	if request.MaxResults > 0 {
+		values.Set("maxResults", strconv.Itoa(request.MaxResults))
+	}
+	return values
+}
+
+// UserID returns the identifier of a user in the identity model of the deployment: the account ID on Jira Cloud,
+// where user names are no longer returned, and the name on Jira Server.
+func UserID(user *jiraBaseClient.User) string {
+	if user == nil {
+		return ""
+	}
+	if len(user.AccountID) > 0 {
+		return user.AccountID
+	}
+	return user.Name
+}
+
+// searchClient routes every search of a jiraClient.Client through a SearchBackend, so that code written against the
+// startAt pagination of prow's client, such as the comment store of ci-search, works against either deployment. A
+// search from an offset reads the pages before it again on Jira Cloud, so loops over pages use a SearchIterator.
+type searchClient struct {
+	jiraClient.Client
+	backend SearchBackend
+}
+
+// NewSearchClient returns a client whose SearchWithContext uses the backend. Every other call goes to the client of
+// the backend.
+func NewSearchClient(backend SearchBackend) jiraClient.Client {
+	return &searchClient{Client: backend.Client(), backend: backend}
+}
+
+func (c *searchClient) SearchWithContext(ctx context.Context, jql string, options *jiraBaseClient.SearchOptions) ([]jiraBaseClient.Issue, *jiraBaseClient.Response, error) {
+	if options == nil {
+		options = &jiraBaseClient.SearchOptions{}
+	}
+	it := NewSearchIterator(c.backend, jql, options, func(int, int) {})
+	var issues []jiraBaseClient.Issue
+	for it.Next(ctx) {
+		issues = append(issues, it.Page().Issues...)
+		// like prow's client, a search without a limit returns a single page
+		if options.MaxResults <= 0 || len(issues) >= options.MaxResults {
+			break
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, nil, err
+	}
+	if options.MaxResults > 0 && len(issues) > options.MaxResults {
+		issues = issues[:options.MaxResults]
+	}
+	total := it.Page().Total
+	if total < 0 {
+		total = options.StartAt + len(issues)
+	}
+	return issues, &jiraBaseClient.Response{StartAt: options.StartAt, MaxResults: options.MaxResults, Total: total}, nil
+}
@@ -0,0 +1,161 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	jiraClient "sigs.k8s.io/prow/prow/jira"
+	"strconv"
+	"testing"
+)
+
+// newTestSearchServer serves seven issues from the search endpoints of both deployments, three per page. Like Jira
+// Cloud, the enhanced search returns only the IDs of the issues when no fields are requested.
+func newTestSearchServer(t *testing.T, deployment Deployment) *httptest.Server {
+	const total, pageSize = 7, 3
+	issue := func(i int) map[string]interface{} {
+		return map[string]interface{}{
+			"id":  strconv.Itoa(100 + i),
+			"key": fmt.Sprintf("OCPBUGS-%d", i),
+			"fields": map[string]interface{}{
+				"summary": fmt.Sprintf("issue %d", i),
+				"description": map[string]interface{}{
+					"type": "doc", "version": 1, "content": []interface{}{
+						map[string]interface{}{"type": "paragraph", "content": []interface{}{
+							map[string]interface{}{"type": "text", "text": fmt.Sprintf("description %d", i)},
+						}},
+					},
+				},
+			},
+		}
+	}
+	page := func(start int) []interface{} {
+		var issues []interface{}
+		for i := start; i < total && i < start+pageSize; i++ {
+			issues = append(issues, issue(i))
+		}
+		return issues
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/2/serverInfo", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ServerInfo{BaseURL: "https://issues.example.com", Version: "9.12.0", DeploymentType: deployment})
+	})
+	mux.HandleFunc("/rest/api/2/search", func(w http.ResponseWriter, r *http.Request) {
+		if deployment != DeploymentServer {
+			http.Error(w, "gone", http.StatusGone)
+			return
+		}
+		startAt, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"startAt": startAt, "total": total, "issues": page(startAt)})
+	})
+	mux.HandleFunc("/rest/api/3/search/jql", func(w http.ResponseWriter, r *http.Request) {
+		if deployment != DeploymentCloud {
+			http.NotFound(w, r)
+			return
+		}
+		if len(r.URL.Query().Get("startAt")) > 0 {
+			t.Errorf("the enhanced search does not accept startAt")
+		}
+		start := 0
+		if token := r.URL.Query().Get("nextPageToken"); len(token) > 0 {
+			start, _ = strconv.Atoi(token[len("token-"):])
+		}
+		issues := page(start)
+		if len(r.URL.Query().Get("fields")) == 0 {
+			for i, issue := range issues {
+				issues[i] = map[string]interface{}{"id": issue.(map[string]interface{})["id"]}
+			}
+		}
+		response := map[string]interface{}{"issues": issues, "isLast": start+pageSize >= total}
+		if start+pageSize < total {
+			response["nextPageToken"] = fmt.Sprintf("token-%d", start+pageSize)
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestSearchBackend(t *testing.T) {
+	for _, tt := range []struct {
+		deployment  Deployment
+		version     APIVersion
+		description string
+	}{
+		{deployment: DeploymentServer, version: APIVersion2},
+		{deployment: DeploymentCloud, version: APIVersion3, description: "description 4"},
+	} {
+		t.Run(string(tt.deployment), func(t *testing.T) {
+			server := newTestSearchServer(t, tt.deployment)
+			defer server.Close()
+			client, err := jiraClient.NewClient(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			backend, err := NewSearchBackend(ctx, client, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if backend.Deployment() != tt.deployment || backend.APIVersion() != tt.version {
+				t.Fatalf("expected %s with version %s, got %s with version %s", tt.deployment, tt.version, backend.Deployment(), backend.APIVersion())
+			}
+
+			issues, err := SearchAllIssues(ctx, backend, "project=OCPBUGS", &jiraBaseClient.SearchOptions{MaxResults: 3}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, issue := range issues {
+				// a search without fields still returns the fields that the exports read
+				if issue.Fields == nil {
+					t.Fatalf("expected the fields of %s to be returned", issue.ID)
+				}
+				keys = append(keys, issue.Key)
+			}
+			expected := []string{"OCPBUGS-0", "OCPBUGS-1", "OCPBUGS-2", "OCPBUGS-3", "OCPBUGS-4", "OCPBUGS-5", "OCPBUGS-6"}
+			if !reflect.DeepEqual(keys, expected) {
+				t.Errorf("expected every issue once, got %v", keys)
+			}
+
+			// prow's client pages with startAt, which the Cloud backend cannot seek to
+			issues, _, err = NewSearchClient(backend).SearchWithContext(ctx, "project=OCPBUGS", &jiraBaseClient.SearchOptions{StartAt: 4, MaxResults: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(issues) != 2 || issues[0].Key != "OCPBUGS-4" || issues[1].Key != "OCPBUGS-5" {
+				t.Fatalf("expected OCPBUGS-4 and OCPBUGS-5, got %v", issues)
+			}
+			if tt.deployment == DeploymentCloud {
+				if actual := ParseDocument(backend.APIVersion(), issues[0].Fields.Description).PlainText(); actual != tt.description {
+					t.Errorf("expected the ADF description to render as %q, got %q", tt.description, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestNewSearchBackendFor(t *testing.T) {
+	if _, err := NewSearchBackendFor(nil, DeploymentServer, APIVersion3); err == nil {
+		t.Errorf("expected version 3 to be rejected on Jira Server")
+	}
+	backend, err := NewSearchBackendFor(nil, DeploymentCloud, APIVersion2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.APIVersion() != APIVersion2 {
+		t.Errorf("expected the configured version to override the default, got %s", backend.APIVersion())
+	}
+}
+
+func TestUserID(t *testing.T) {
+	if actual := UserID(&jiraBaseClient.User{Name: "jdoe"}); actual != "jdoe" {
+		t.Errorf("expected the name on Jira Server, got %q", actual)
+	}
+	if actual := UserID(&jiraBaseClient.User{AccountID: "5b10a2844c20165700ede21g"}); actual != "5b10a2844c20165700ede21g" {
+		t.Errorf("expected the account ID on Jira Cloud, got %q", actual)
+	}
+}
@@ -0,0 +1,334 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CassetteMode is what a cassette transport does with the requests sent to Jira.
+type CassetteMode string
+
+const (
+	// CassetteRecord sends every request to Jira and appends the request and its response to the cassette.
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplay answers every request from the cassette and never sends it to Jira. A request that was not
+	// recorded fails.
+	CassetteReplay CassetteMode = "replay"
+
+	// redactedValue replaces the redacted fields and the authorization headers in a cassette.
+	redactedValue = "<redacted>"
+)
+
+// sensitiveHeaders are never written to a cassette, whatever fields are redacted.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Atlassian-Token"}
+
+// Cassette is a recording of the HTTP interactions with Jira, in the order they happened. It is written as JSON lines,
+// one interaction per line, so that recording a request appends a line rather than rewriting the file.
+type Cassette struct {
+	Interactions []Interaction
+}
+
+// Interaction is a request sent to Jira and the response it received.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request without the host of the endpoint, so that a cassette can be replayed against any
+// endpoint.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response to a RecordedRequest.
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// LoadCassette reads a cassette written by a RecordingTransport.
+func LoadCassette(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cassette := &Cassette{}
+	decoder := json.NewDecoder(f)
+	for {
+		var interaction Interaction
+		if err := decoder.Decode(&interaction); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+		}
+		cassette.Interactions = append(cassette.Interactions, interaction)
+	}
+	return cassette, nil
+}
+
+// CassetteRedactor removes what must not be written to a cassette. The authorization headers and cookies are always
+// removed, and the value of every JSON field named in Fields, such as emailAddress or customfield_12316243, is
+// replaced in the request and response bodies. The strings nested in a redacted object or array are replaced, so that
+// the body still decodes into the same types.
+type CassetteRedactor struct {
+	Fields []string
+}
+
+// Header returns a copy of the header with the sensitive headers redacted.
+func (r *CassetteRedactor) Header(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	header = header.Clone()
+	for _, name := range sensitiveHeaders {
+		if _, ok := header[name]; ok {
+			header[name] = []string{redactedValue}
+		}
+	}
+	return header
+}
+
+// Body returns the body with the redacted fields replaced. A body that is not JSON is returned unchanged.
+func (r *CassetteRedactor) Body(body []byte) []byte {
+	if len(r.Fields) == 0 || len(body) == 0 {
+		return body
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	fields := make(map[string]bool, len(r.Fields))
+	for _, field := range r.Fields {
+		fields[field] = true
+	}
+	redacted, err := marshalJSON(redactFields(value, fields, false), "")
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// marshalJSON marshals v without escaping HTML, so that the bodies and the redacted values stay readable.
+func marshalJSON(v interface{}, indent string) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// redactFields replaces the strings of the redacted fields in value, or every string of value if redact is set.
+func redactFields(value interface{}, fields map[string]bool, redact bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = redactFields(child, fields, redact || fields[key])
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactFields(child, fields, redact)
+		}
+	case string:
+		if redact {
+			return redactedValue
+		}
+	}
+	return value
+}
+
+// requestURL is the path and the sorted query of the request, which identify it in a cassette.
+func requestURL(u *url.URL) string {
+	if len(u.RawQuery) == 0 {
+		return u.EscapedPath()
+	}
+	return u.EscapedPath() + "?" + u.Query().Encode()
+}
+
+// readBody reads and restores the body of the request, so that it can still be sent.
+func readBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// RecordingTransport sends requests to Upstream, http.DefaultTransport when it is nil, and appends every request and its
+// response, redacted, to the cassette at Path as soon as the response is received. An existing cassette is replaced by
+// the first interaction.
+type RecordingTransport struct {
+	Path     string
+	Upstream http.RoundTripper
+	Redactor CassetteRedactor
+
+	lock sync.Mutex
+	// started is set once the cassette has been replaced
+	started bool
+}
+
+func (t *RecordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := readBody(request)
+	if err != nil {
+		return nil, err
+	}
+	upstream := t.Upstream
+	if upstream == nil {
+		upstream = http.DefaultTransport
+	}
+	response, err := upstream.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	// the redacted body may differ in length from the body that was received
+	responseHeader := t.Redactor.Header(response.Header)
+	delete(responseHeader, "Content-Length")
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: request.Method,
+			URL:    requestURL(request.URL),
+			Header: t.Redactor.Header(request.Header),
+			Body:   string(t.Redactor.Body(body)),
+		},
+		Response: RecordedResponse{
+			StatusCode: response.StatusCode,
+			Header:     responseHeader,
+			Body:       string(t.Redactor.Body(responseBody)),
+		},
+	}
+	if err := t.append(interaction); err != nil {
+		return nil, fmt.Errorf("failed to save cassette %s: %w", t.Path, err)
+	}
+	return response, nil
+}
+
+// append writes the interaction as a line at the end of the cassette.
+func (t *RecordingTransport) append(interaction Interaction) error {
+	line, err := marshalJSON(interaction, "")
+	if err != nil {
+		return err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if !t.started {
+		if err := os.MkdirAll(filepath.Dir(t.Path), 0750); err != nil {
+			return err
+		}
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(t.Path, flags, 0640)
+	if err != nil {
+		return err
+	}
+	t.started = true
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReplayTransport answers requests from a cassette. A request is answered by the first interaction not yet replayed
+// with the same method, path, query and redacted body, so that a request sent repeatedly receives the responses in
+// the order they were recorded. A request that matches no interaction fails without being sent anywhere.
+type ReplayTransport struct {
+	Redactor CassetteRedactor
+
+	path     string
+	cassette *Cassette
+
+	lock     sync.Mutex
+	replayed []bool
+}
+
+// NewReplayTransport loads the cassette at path to replay it.
+func NewReplayTransport(path string, redactor CassetteRedactor) (*ReplayTransport, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayTransport{
+		Redactor: redactor,
+		path:     path,
+		cassette: cassette,
+		replayed: make([]bool, len(cassette.Interactions)),
+	}, nil
+}
+
+func (t *ReplayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := readBody(request)
+	if err != nil {
+		return nil, err
+	}
+	target := requestURL(request.URL)
+	redactedBody := string(t.Redactor.Body(body))
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for i, interaction := range t.cassette.Interactions {
+		if t.replayed[i] || interaction.Request.Method != request.Method || interaction.Request.URL != target || interaction.Request.Body != redactedBody {
+			continue
+		}
+		t.replayed[i] = true
+		recorded := interaction.Response
+		header := recorded.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       request,
+		}, nil
+	}
+	return nil, fmt.Errorf("no unreplayed interaction in cassette %s matches %s %s", t.path, request.Method, target)
+}
+
+// Unreplayed returns the interactions of the cassette that no request has matched yet.
+func (t *ReplayTransport) Unreplayed() []Interaction {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var interactions []Interaction
+	for i, interaction := range t.cassette.Interactions {
+		if !t.replayed[i] {
+			interactions = append(interactions, interaction)
+		}
+	}
+	return interactions
+}
@@ -0,0 +1,201 @@
+package helpers
+
+import (
+	"github.com/bradmwilliams/jira-migration/pkg/jira/fakejira"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRedactor(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		fields   []string
+		body     string
+		expected string
+	}{
+		{
+			name:     "no fields",
+			body:     `{"emailAddress":"jdoe@example.com"}`,
+			expected: `{"emailAddress":"jdoe@example.com"}`,
+		},
+		{
+			name:     "string field",
+			fields:   []string{"emailAddress"},
+			body:     `{"fields":{"reporter":{"name":"jdoe","emailAddress":"jdoe@example.com"}}}`,
+			expected: `{"fields":{"reporter":{"emailAddress":"<redacted>","name":"jdoe"}}}`,
+		},
+		{
+			name:     "object field keeps its numbers and shape",
+			fields:   []string{"customfield_12316243"},
+			body:     `{"customfield_12316243":{"name":"qa","active":true,"id":12345678901234567890},"summary":"Crash"}`,
+			expected: `{"customfield_12316243":{"active":true,"id":12345678901234567890,"name":"<redacted>"},"summary":"Crash"}`,
+		},
+		{
+			name:     "arrays",
+			fields:   []string{"labels"},
+			body:     `[{"labels":["a","b"]},{"labels":[]}]`,
+			expected: `[{"labels":["<redacted>","<redacted>"]},{"labels":[]}]`,
+		},
+		{
+			name:     "not json",
+			fields:   []string{"emailAddress"},
+			body:     `emailAddress=jdoe@example.com`,
+			expected: `emailAddress=jdoe@example.com`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			redactor := &CassetteRedactor{Fields: tt.fields}
+			if actual := string(redactor.Body([]byte(tt.body))); actual != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, actual)
+			}
+		})
+	}
+
+	redactor := &CassetteRedactor{}
+	header := redactor.Header(http.Header{"Authorization": {"Bearer secret"}, "Set-Cookie": {"JSESSIONID=1"}, "Accept": {"application/json"}})
+	if expected := (http.Header{"Authorization": {"<redacted>"}, "Set-Cookie": {"<redacted>"}, "Accept": {"application/json"}}); !reflect.DeepEqual(header, expected) {
+		t.Errorf("expected headers %v, got %v", expected, header)
+	}
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	dataset, err := fakejira.LoadDataset("fakejira/testdata/dataset.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, err := fakejira.New(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cassettes", "issue.json")
+	token := filepath.Join(dir, "token")
+	if err := os.WriteFile(token, []byte("s3cr3t-t0ken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	record := &JiraOptions{
+		Endpoint:             server.URL,
+		BearerTokenFile:      token,
+		CassettePath:         path,
+		CassetteMode:         string(CassetteRecord),
+		CassetteRedactFields: []string{"emailAddress"},
+	}
+	if err := record.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	client, err := record.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := client.GetIssue("OCPBUGS-35865")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetRemoteLinks(recorded.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t-t0ken") {
+		t.Errorf("expected the bearer token to be scrubbed from the cassette")
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != 2 {
+		t.Fatalf("expected two interactions, got %d", len(cassette.Interactions))
+	}
+
+	// the replay never reaches the server
+	server.Close()
+	requests := len(fake.Requests())
+	replay := &JiraOptions{
+		Endpoint:             "https://issues.example.com",
+		CassettePath:         path,
+		CassetteMode:         string(CassetteReplay),
+		CassetteRedactFields: []string{"emailAddress"},
+	}
+	if client, err = replay.Client(); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := client.GetIssue("OCPBUGS-35865")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Fields.Summary != recorded.Fields.Summary || replayed.Fields.Description != recorded.Fields.Description {
+		t.Errorf("expected the recorded issue, got %s", replayed.Fields.Summary)
+	}
+	if replayed.Fields.Reporter.EmailAddress != "<redacted>" {
+		t.Errorf("expected the redacted email address, got %s", replayed.Fields.Reporter.EmailAddress)
+	}
+	links, err := client.GetRemoteLinks(recorded.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 {
+		t.Errorf("expected the recorded remote link, got %v", links)
+	}
+
+	// every interaction is replayed once, so a request that was not recorded as often as it is sent fails
+	if _, err := client.GetIssue("OCPBUGS-35865"); err == nil || !strings.Contains(err.Error(), "GET /rest/api/2/issue/OCPBUGS-35865") {
+		t.Errorf("expected the unmatched request to fail, got %v", err)
+	}
+	if _, err := client.GetIssue("OCPBUGS-35866"); err == nil {
+		t.Errorf("expected the unmatched request to fail")
+	}
+	if len(fake.Requests()) != requests {
+		t.Errorf("expected the replay not to send requests to Jira")
+	}
+}
+
+func TestRecordingTransportAppends(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	if err := os.WriteFile(path, []byte("left over from an earlier recording\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &RecordingTransport{Path: path}
+	client := &http.Client{Transport: transport}
+	for _, p := range []string{"/first", "/second", "/third"} {
+		response, err := client.Get(server.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"); len(lines) != 3 {
+		t.Fatalf("expected the earlier cassette to be replaced by one line per interaction, got\n%s", data)
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bodies []string
+	for _, interaction := range cassette.Interactions {
+		bodies = append(bodies, interaction.Response.Body)
+	}
+	if expected := []string{`{"path":"/first"}`, `{"path":"/second"}`, `{"path":"/third"}`}; !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("expected the responses in the order they were received %v, got %v", expected, bodies)
+	}
+}
@@ -0,0 +1,156 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"k8s.io/klog/v2"
+	"net/url"
+	jiraClient "sigs.k8s.io/prow/prow/jira"
+	"strconv"
+	"strings"
+)
+
+const (
+	changelogSearchBatchSize = 100
+	changelogPageSize        = 100
+)
+
+// changelogPage is the paginated changelog that Jira embeds in an issue when expand=changelog is requested.
+type changelogPage struct {
+	StartAt    int                               `json:"startAt"`
+	MaxResults int                               `json:"maxResults"`
+	Total      int                               `json:"total"`
+	Histories  []jiraBaseClient.ChangelogHistory `json:"histories"`
+}
+
+type changelogIssue struct {
+	ID        string         `json:"id"`
+	Key       string         `json:"key"`
+	Changelog *changelogPage `json:"changelog"`
+}
+
+// changelogValues is a single page returned by the /issue/{id}/changelog endpoint.
+type changelogValues struct {
+	StartAt    int                               `json:"startAt"`
+	MaxResults int                               `json:"maxResults"`
+	Total      int                               `json:"total"`
+	IsLast     bool                              `json:"isLast"`
+	Values     []jiraBaseClient.ChangelogHistory `json:"values"`
+}
+
+// IssueChangelogs returns the complete changelog of every requested issue, keyed by issue ID. Changelogs are
+// requested in batches with expand=changelog and any changelog that Jira truncated is paged through individually.
+func IssueChangelogs(ctx context.Context, backend SearchBackend, ids ...string) (map[string][]jiraBaseClient.ChangelogHistory, error) {
+	changelogs := make(map[string][]jiraBaseClient.ChangelogHistory, len(ids))
+	for start := 0; start < len(ids); start += changelogSearchBatchSize {
+		end := start + changelogSearchBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		issues, err := searchChangelogs(ctx, backend, ids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			if issue.Changelog == nil {
+				changelogs[issue.ID] = nil
+				continue
+			}
+			histories := issue.Changelog.Histories
+			if len(histories) < issue.Changelog.Total {
+				klog.V(5).Infof("Changelog of %s was truncated at %d/%d entries, paging", issue.Key, len(histories), issue.Changelog.Total)
+				histories, err = IssueChangelog(ctx, backend, issue.ID)
+				if err != nil {
+					return nil, err
+				}
+			}
+			changelogs[issue.ID] = histories
+		}
+	}
+	return changelogs, nil
+}
+
+func searchChangelogs(ctx context.Context, backend SearchBackend, ids []string) ([]changelogIssue, error) {
+	var issues []changelogIssue
+	request := SearchRequest{
+		JQL:        fmt.Sprintf("id IN (%s)", strings.Join(ids, ",")),
+		Fields:     []string{"updated"},
+		Expand:     "changelog",
+		MaxResults: len(ids),
+	}
+	for {
+		response, err := backend.Search(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("unable to search issue changelogs: %w", err)
+		}
+		for _, raw := range response.Issues {
+			var issue changelogIssue
+			if err := json.Unmarshal(raw, &issue); err != nil {
+				return nil, fmt.Errorf("unable to decode issue changelog: %w", err)
+			}
+			issues = append(issues, issue)
+		}
+		if len(response.Next) == 0 {
+			return issues, nil
+		}
+		request.Position = response.Next
+	}
+}
+
+// IssueChangelog pages through the full changelog of a single issue. Only Jira Cloud serves the /issue/{id}/changelog
+// endpoint, so on Jira Server the changelog is read from the issue instead, which embeds all of it when expanded.
+func IssueChangelog(ctx context.Context, backend SearchBackend, id string) ([]jiraBaseClient.ChangelogHistory, error) {
+	if backend.Deployment() == DeploymentServer {
+		return expandedChangelog(ctx, backend, id)
+	}
+	var histories []jiraBaseClient.ChangelogHistory
+	for startAt := 0; ; {
+		values := url.Values{}
+		values.Set("startAt", strconv.Itoa(startAt))
+		values.Set("maxResults", strconv.Itoa(changelogPageSize))
+
+		var page changelogValues
+		if err := getJSON(ctx, backend.Client(), fmt.Sprintf("rest/api/%s/issue/%s/changelog?%s", backend.APIVersion(), id, values.Encode()), &page); err != nil {
+			return nil, fmt.Errorf("unable to get changelog of issue %s at offset %d: %w", id, startAt, err)
+		}
+		histories = append(histories, page.Values...)
+		startAt += len(page.Values)
+		if len(page.Values) == 0 || page.IsLast || startAt >= page.Total {
+			return histories, nil
+		}
+	}
+}
+
+// expandedChangelog returns the changelog embedded in the issue with expand=changelog.
+func expandedChangelog(ctx context.Context, backend SearchBackend, id string) ([]jiraBaseClient.ChangelogHistory, error) {
+	values := url.Values{}
+	values.Set("fields", "updated")
+	values.Set("expand", "changelog")
+	var issue changelogIssue
+	if err := getJSON(ctx, backend.Client(), fmt.Sprintf("rest/api/%s/issue/%s?%s", backend.APIVersion(), id, values.Encode()), &issue); err != nil {
+		return nil, fmt.Errorf("unable to get changelog of issue %s: %w", id, err)
+	}
+	if issue.Changelog == nil {
+		return nil, nil
+	}
+	return issue.Changelog.Histories, nil
+}
+
+func getJSON(ctx context.Context, client jiraClient.Client, path string, v interface{}) error {
+	req, err := client.JiraClient().NewRequestWithContext(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	var raw json.RawMessage
+	response, err := client.JiraClient().Do(req, &raw)
+	if err != nil {
+		return jiraClient.HandleJiraError(response, err)
+	}
+	data, err := flattenADF(raw)
+	if err != nil {
+		return fmt.Errorf("unable to decode ADF documents in %s: %w", path, err)
+	}
+	return json.Unmarshal(data, v)
+}
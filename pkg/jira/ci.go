@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CIReferenceKind is the kind of CI artifact referenced from the text of an issue.
+type CIReferenceKind string
+
+const (
+	// CIReferenceJob is a run of a Prow job. Value is the name of the job and BuildID the ID of the run.
+	CIReferenceJob CIReferenceKind = "job"
+	// CIReferenceTest is the name of a test, such as "[sig-network] Services should serve endpoints".
+	CIReferenceTest CIReferenceKind = "test"
+	// CIReferencePayload is a release payload, such as 4.16.0-0.nightly-2024-03-01-123456.
+	CIReferencePayload CIReferenceKind = "payload"
+	// CIReferenceMustGather is a link to a must-gather archive.
+	CIReferenceMustGather CIReferenceKind = "must_gather"
+)
+
+// CIReference is a CI artifact referenced from the text of an issue. URL is set for references found in a link.
+type CIReference struct {
+	Kind    CIReferenceKind
+	Value   string
+	URL     string
+	BuildID string
+}
+
+var (
+	// ciJobPattern matches the URL of a job run on the Prow UI, on gcsweb or on GCS, for periodic and presubmit jobs:
+	// https://prow.ci.openshift.org/view/gs/test-platform-results/logs/<job>/<build>
+	// https://prow.ci.openshift.org/view/gs/test-platform-results/pr-logs/pull/<org_repo>/<pr>/<job>/<build>
+	ciJobPattern = regexp.MustCompile(`https?://(?:prow\.ci\.openshift\.org/view/gs|gcsweb-ci[^/\s]*/gcs|storage\.googleapis\.com)/[^/\s|\]]+/(?:logs|pr-logs/pull/[^/\s|\]]+/[0-9]+)/([^/\s|\]]+)/([0-9]+)[^\s|\]]*`)
+	// ciTestPattern matches a test name up to the end of the line. Test names run up to their last bracketed tag, such
+	// as [Suite:openshift/conformance/parallel], when they have one.
+	ciTestPattern    = regexp.MustCompile(`\[sig-[a-z0-9-]+\][^\n"'|{}]*`)
+	ciTestTagPattern = regexp.MustCompile(`^(.*\[[A-Za-z]+:[^\]]*\])`)
+	// ciPayloadPattern matches the names of nightly, CI and OKD release payloads.
+	ciPayloadPattern = regexp.MustCompile(`\b[0-9]+\.[0-9]+\.[0-9]+-0\.(?:nightly|ci|okd|konflux-nightly)(?:-[a-z0-9]+)*-[0-9]{4}-[0-9]{2}-[0-9]{2}-[0-9]{6}\b`)
+	// ciMustGatherPattern matches any link to a must-gather, such as the gather-must-gather artifacts of a job.
+	ciMustGatherPattern = regexp.MustCompile(`https?://[^\s|\]]*must-gather[^\s|\]]*`)
+)
+
+// FindCIReferences returns the job runs, test names, release payloads and must-gather links mentioned in the text,
+// in order of first mention and each once. The URL of a must-gather of a job run is reported as both.
+func FindCIReferences(text string) []CIReference {
+	type match struct {
+		at        int
+		reference CIReference
+	}
+	var matches []match
+	for _, m := range ciJobPattern.FindAllStringSubmatchIndex(text, -1) {
+		matches = append(matches, match{at: m[0], reference: CIReference{
+			Kind:    CIReferenceJob,
+			Value:   text[m[2]:m[3]],
+			URL:     trimCIURL(text[m[0]:m[1]]),
+			BuildID: text[m[4]:m[5]],
+		}})
+	}
+	for _, m := range ciTestPattern.FindAllStringIndex(text, -1) {
+		name := text[m[0]:m[1]]
+		if tagged := ciTestTagPattern.FindStringSubmatch(name); tagged != nil {
+			name = tagged[1]
+		}
+		name = strings.TrimRight(name, " \t.,;:")
+		matches = append(matches, match{at: m[0], reference: CIReference{Kind: CIReferenceTest, Value: name}})
+	}
+	for _, m := range ciPayloadPattern.FindAllStringIndex(text, -1) {
+		matches = append(matches, match{at: m[0], reference: CIReference{Kind: CIReferencePayload, Value: text[m[0]:m[1]]}})
+	}
+	for _, m := range ciMustGatherPattern.FindAllStringIndex(text, -1) {
+		url := trimCIURL(text[m[0]:m[1]])
+		matches = append(matches, match{at: m[0], reference: CIReference{Kind: CIReferenceMustGather, Value: url, URL: url}})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].at < matches[j].at
+	})
+
+	var references []CIReference
+	seen := make(map[CIReference]bool)
+	for _, m := range matches {
+		key := m.reference
+		if key.Kind == CIReferenceJob {
+			// the same run is often linked both to the Prow UI and to its artifacts
+			key.URL = ""
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		references = append(references, m.reference)
+	}
+	return references
+}
+
+// trimCIURL removes the punctuation that ends a sentence or wraps a link from the end of a URL.
+func trimCIURL(url string) string {
+	return strings.TrimRight(url, ".,;:)'\"")
+}
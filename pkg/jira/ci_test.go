@@ -0,0 +1,29 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindCIReferences(t *testing.T) {
+	text := `Failing in https://prow.ci.openshift.org/view/gs/test-platform-results/logs/periodic-ci-openshift-release-master-nightly-4.16-e2e-aws-ovn/1763212345678901248 on 4.16.0-0.nightly-2024-03-01-123456.
+Test: [sig-network] Services should serve endpoints on same port and different protocols [Suite:openshift/conformance/parallel] [Suite:k8s] fails often.
+Also "[sig-arch] Only known images used by tests" and the pull job [results|https://prow.ci.openshift.org/view/gs/test-platform-results/pr-logs/pull/openshift_installer/7890/pull-ci-openshift-installer-master-e2e-aws/1763000000000000000].
+must-gather: https://gcsweb-ci.apps.ci.l2s4.p1.openshiftapps.com/gcs/test-platform-results/logs/periodic-ci-openshift-release-master-nightly-4.16-e2e-aws-ovn/1763212345678901248/artifacts/e2e-aws-ovn/gather-must-gather/artifacts/must-gather.tar
+Upgrade from 4.15.0-0.ci-2024-02-28-010203 to 4.16.0-0.nightly-arm64-2024-03-01-123456 and again 4.16.0-0.nightly-2024-03-01-123456.`
+	mustGather := "https://gcsweb-ci.apps.ci.l2s4.p1.openshiftapps.com/gcs/test-platform-results/logs/periodic-ci-openshift-release-master-nightly-4.16-e2e-aws-ovn/1763212345678901248/artifacts/e2e-aws-ovn/gather-must-gather/artifacts/must-gather.tar"
+	expected := []CIReference{
+		{Kind: CIReferenceJob, Value: "periodic-ci-openshift-release-master-nightly-4.16-e2e-aws-ovn", BuildID: "1763212345678901248", URL: "https://prow.ci.openshift.org/view/gs/test-platform-results/logs/periodic-ci-openshift-release-master-nightly-4.16-e2e-aws-ovn/1763212345678901248"},
+		{Kind: CIReferencePayload, Value: "4.16.0-0.nightly-2024-03-01-123456"},
+		{Kind: CIReferenceTest, Value: "[sig-network] Services should serve endpoints on same port and different protocols [Suite:openshift/conformance/parallel] [Suite:k8s]"},
+		{Kind: CIReferenceTest, Value: "[sig-arch] Only known images used by tests"},
+		{Kind: CIReferenceJob, Value: "pull-ci-openshift-installer-master-e2e-aws", BuildID: "1763000000000000000", URL: "https://prow.ci.openshift.org/view/gs/test-platform-results/pr-logs/pull/openshift_installer/7890/pull-ci-openshift-installer-master-e2e-aws/1763000000000000000"},
+		{Kind: CIReferenceMustGather, Value: mustGather, URL: mustGather},
+		{Kind: CIReferencePayload, Value: "4.15.0-0.ci-2024-02-28-010203"},
+		{Kind: CIReferencePayload, Value: "4.16.0-0.nightly-arm64-2024-03-01-123456"},
+	}
+	actual := FindCIReferences(text)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected\n%#v\ngot\n%#v", expected, actual)
+	}
+}
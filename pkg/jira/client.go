@@ -0,0 +1,242 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"sigs.k8s.io/prow/prow/config/secret"
+	jiraClient "sigs.k8s.io/prow/prow/jira"
+	"sync"
+)
+
+// JiraOptions are the flags of prow's flagutil.JiraOptions, plus the rate limit that every client created from them
+// shares. Clients read from Jira through RateLimitTransport, so that concurrent workers draw from the same budget and
+// back off together when Jira throttles them. With a cassette, the requests that clients read with are recorded to, or
+// replayed from, a file.
+type JiraOptions struct {
+	Endpoint        string
+	Username        string
+	PasswordFile    string
+	BearerTokenFile string
+
+	QPS        float64
+	Burst      int
+	MaxRetries int
+
+	// CassettePath is the cassette that requests are recorded to or replayed from, as CassetteMode says. Requests are
+	// sent through Transport when it is empty.
+	CassettePath         string
+	CassetteMode         string
+	CassetteRedactFields []string
+
+	// Transport sends the requests of the clients, http.DefaultTransport when it is nil.
+	Transport http.RoundTripper
+
+	once    sync.Once
+	limiter *RateLimiter
+
+	cassetteOnce sync.Once
+	cassette     http.RoundTripper
+	cassetteErr  error
+}
+
+func (o *JiraOptions) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Endpoint, "jira-endpoint", "", "The Jira endpoint to use")
+	fs.StringVar(&o.Username, "jira-username", "", "The username to use for Jira basic auth")
+	fs.StringVar(&o.PasswordFile, "jira-password-file", "", "Location to a file containing the Jira basic auth password")
+	fs.StringVar(&o.BearerTokenFile, "jira-bearer-token-file", "", "Location to a file containing the Jira bearer authorization token")
+	fs.Float64Var(&o.QPS, "jira-qps", 10, "The maximum number of requests per second sent to Jira by all workers together, lowered to the rate that Jira advertises. 0 does not limit the rate.")
+	fs.IntVar(&o.Burst, "jira-burst", 10, "The number of requests that may be sent to Jira at once before --jira-qps applies.")
+	fs.IntVar(&o.MaxRetries, "jira-max-retries", DefaultMaxRetries, "The number of times a request that Jira throttles with a 429 or 503 is retried.")
+	fs.StringVar(&o.CassettePath, "jira-cassette", "", "A file to record every request sent to Jira and its response to, or to replay them from, as --jira-cassette-mode says. Authorization headers are never recorded.")
+	fs.StringVar(&o.CassetteMode, "jira-cassette-mode", string(CassetteRecord), "Whether to 'record' the requests to --jira-cassette, or to 'replay' them from it without contacting Jira, failing any request that was not recorded.")
+	fs.Func("jira-cassette-redact-field", "A JSON field whose value is redacted from the bodies recorded to --jira-cassette, such as emailAddress or customfield_12316243. May be repeated.", func(field string) error {
+		if len(field) == 0 {
+			return errors.New("the field must not be empty")
+		}
+		o.CassetteRedactFields = append(o.CassetteRedactFields, field)
+		return nil
+	})
+}
+
+func (o *JiraOptions) Validate() error {
+	if o.Endpoint == "" {
+		return nil
+	}
+	if _, err := url.ParseRequestURI(o.Endpoint); err != nil {
+		return fmt.Errorf("--jira-endpoint %q is invalid: %w", o.Endpoint, err)
+	}
+	if (o.Username != "") != (o.PasswordFile != "") {
+		return errors.New("--jira-username and --jira-password-file must be specified together")
+	}
+	if o.BearerTokenFile != "" && o.Username != "" {
+		return errors.New("--jira-bearer-token-file and --jira-username are mutually exclusive")
+	}
+	if o.BearerTokenFile != "" && o.PasswordFile != "" {
+		return errors.New("--jira-bearer-token-file and --jira-password-file are mutually exclusive")
+	}
+	if o.MaxRetries < 0 {
+		return errors.New("--jira-max-retries must not be negative")
+	}
+	if o.CassettePath != "" {
+		switch CassetteMode(o.CassetteMode) {
+		case CassetteRecord, CassetteReplay:
+		default:
+			return fmt.Errorf("--jira-cassette-mode must be %q or %q, not %q", CassetteRecord, CassetteReplay, o.CassetteMode)
+		}
+	}
+	return nil
+}
+
+// RateLimiter returns the limiter shared by every client created from the options, whose State is the current backoff.
+func (o *JiraOptions) RateLimiter() *RateLimiter {
+	o.once.Do(func() {
+		o.limiter = NewRateLimiter(o.QPS, o.Burst)
+	})
+	return o.limiter
+}
+
+// cassetteTransport returns the transport of the cassette shared by every client created from the options, or
+// Transport without a cassette. Sharing it keeps the interactions of every client in a single recording.
+func (o *JiraOptions) cassetteTransport() (http.RoundTripper, error) {
+	if o.CassettePath == "" {
+		return o.Transport, nil
+	}
+	o.cassetteOnce.Do(func() {
+		redactor := CassetteRedactor{Fields: o.CassetteRedactFields}
+		switch CassetteMode(o.CassetteMode) {
+		case CassetteRecord:
+			o.cassette = &RecordingTransport{Path: o.CassettePath, Upstream: o.Transport, Redactor: redactor}
+		case CassetteReplay:
+			o.cassette, o.cassetteErr = NewReplayTransport(o.CassettePath, redactor)
+		default:
+			o.cassetteErr = fmt.Errorf("unknown --jira-cassette-mode %q", o.CassetteMode)
+		}
+	})
+	return o.cassette, o.cassetteErr
+}
+
+// Client creates a Jira client that reads through the shared rate limiter. Only the requests that it reads with go
+// through the cassette, the methods left to prow's client are sent to Jira as they are.
+func (o *JiraOptions) Client() (jiraClient.Client, error) {
+	if o.Endpoint == "" {
+		return nil, errors.New("empty --jira-endpoint, can not create a client")
+	}
+	upstream, err := o.cassetteTransport()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load --jira-cassette: %w", err)
+	}
+	var prowOptions []jiraClient.Option
+	var transport http.RoundTripper = &RateLimitTransport{
+		Limiter:    o.RateLimiter(),
+		MaxRetries: o.MaxRetries,
+		Upstream:   upstream,
+	}
+	if o.PasswordFile != "" {
+		if err := secret.Add(o.PasswordFile); err != nil {
+			return nil, fmt.Errorf("failed to get --jira-password-file: %w", err)
+		}
+		basicAuth := func() (string, string) {
+			return o.Username, string(secret.GetSecret(o.PasswordFile))
+		}
+		prowOptions = append(prowOptions, jiraClient.WithBasicAuth(basicAuth))
+		transport = &authTransport{upstream: transport, authorize: func(r *http.Request) {
+			r.SetBasicAuth(basicAuth())
+		}}
+	}
+	if o.BearerTokenFile != "" {
+		if err := secret.Add(o.BearerTokenFile); err != nil {
+			return nil, fmt.Errorf("failed to get --jira-bearer-token-file: %w", err)
+		}
+		bearerAuth := func() string {
+			return string(secret.GetSecret(o.BearerTokenFile))
+		}
+		prowOptions = append(prowOptions, jiraClient.WithBearerAuth(bearerAuth))
+		transport = &authTransport{upstream: transport, authorize: func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+bearerAuth())
+		}}
+	}
+	prow, err := jiraClient.NewClient(o.Endpoint, prowOptions...)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := jiraBaseClient.NewClient(&http.Client{Transport: transport}, o.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &client{Client: prow, upstream: reader}, nil
+}
+
+// authTransport authorizes every request with the current secret, which is reloaded when its file changes.
+type authTransport struct {
+	upstream  http.RoundTripper
+	authorize func(*http.Request)
+}
+
+func (t *authTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	t.authorize(request)
+	return t.upstream.RoundTrip(request)
+}
+
+// client reads from Jira through its own rate limited go-jira client. The methods that write to Jira, which none of
+// the commands call, are left to prow's client.
+type client struct {
+	jiraClient.Client
+	upstream *jiraBaseClient.Client
+}
+
+func (c *client) JiraClient() *jiraBaseClient.Client {
+	return c.upstream
+}
+
+func (c *client) GetIssue(id string) (*jiraBaseClient.Issue, error) {
+	issue, response, err := c.upstream.Issue.Get(id, &jiraBaseClient.GetQueryOptions{})
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return nil, jiraClient.NewNotFoundError(err)
+		}
+		return nil, jiraClient.HandleJiraError(response, err)
+	}
+	return issue, nil
+}
+
+func (c *client) SearchWithContext(ctx context.Context, jql string, options *jiraBaseClient.SearchOptions) ([]jiraBaseClient.Issue, *jiraBaseClient.Response, error) {
+	issues, response, err := c.upstream.Issue.SearchWithContext(ctx, jql, options)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return nil, response, jiraClient.NewNotFoundError(err)
+		}
+		return nil, response, jiraClient.HandleJiraError(response, err)
+	}
+	return issues, response, nil
+}
+
+func (c *client) GetRemoteLinks(id string) ([]jiraBaseClient.RemoteLink, error) {
+	links, response, err := c.upstream.Issue.GetRemoteLinks(id)
+	if err != nil {
+		return nil, jiraClient.HandleJiraError(response, err)
+	}
+	return *links, nil
+}
+
+func (c *client) ListProjects() (*jiraBaseClient.ProjectList, error) {
+	projects, response, err := c.upstream.Project.GetList()
+	if err != nil {
+		return nil, jiraClient.HandleJiraError(response, err)
+	}
+	return projects, nil
+}
+
+func (c *client) WithFields(fields logrus.Fields) jiraClient.Client {
+	return &client{Client: c.Client.WithFields(fields), upstream: c.upstream}
+}
+
+func (c *client) ForPlugin(plugin string) jiraClient.Client {
+	return &client{Client: c.Client.ForPlugin(plugin), upstream: c.upstream}
+}
@@ -0,0 +1,140 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"k8s.io/klog/v2"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	commentSearchBatchSize = 100
+	commentPageSize        = 100
+)
+
+// commentPage is the paginated list of comments that Jira embeds in the comment field of an issue and returns from
+// the /issue/{id}/comment endpoint.
+type commentPage struct {
+	StartAt    int                       `json:"startAt"`
+	MaxResults int                       `json:"maxResults"`
+	Total      int                       `json:"total"`
+	Comments   []*jiraBaseClient.Comment `json:"comments"`
+}
+
+type commentIssue struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	Fields struct {
+		Comment *commentPage `json:"comment"`
+	} `json:"fields"`
+}
+
+// IssueComments returns every comment of the requested issues, keyed by issue ID. Comments are requested in batches
+// and the comments of any issue whose embedded total exceeds the number Jira returned are paged through individually.
+// Issues that were already searched with their comment field should use PageComments instead.
+func IssueComments(ctx context.Context, backend SearchBackend, ids ...string) (map[string][]*jiraBaseClient.Comment, error) {
+	comments := make(map[string][]*jiraBaseClient.Comment, len(ids))
+	for start := 0; start < len(ids); start += commentSearchBatchSize {
+		end := start + commentSearchBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		issues, err := searchComments(ctx, backend, ids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			page := issue.Fields.Comment
+			if page == nil {
+				comments[issue.ID] = nil
+				continue
+			}
+			if comments[issue.ID], err = completeComments(ctx, backend, issue.ID, issue.Key, page.Comments, page.Total); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return comments, nil
+}
+
+// PageComments returns every comment of the issues of a search page, keyed by issue ID, without searching them again.
+// The comments embedded in an issue are returned unless the page reports more, in which case the comments of the issue
+// are paged through individually. Issues searched without their comment field are left out.
+func PageComments(ctx context.Context, backend SearchBackend, page SearchPage) (map[string][]*jiraBaseClient.Comment, error) {
+	comments := make(map[string][]*jiraBaseClient.Comment, len(page.CommentTotals))
+	for _, issue := range page.Issues {
+		total, ok := page.CommentTotals[issue.ID]
+		if !ok {
+			continue
+		}
+		var embedded []*jiraBaseClient.Comment
+		if issue.Fields != nil && issue.Fields.Comments != nil {
+			embedded = issue.Fields.Comments.Comments
+		}
+		var err error
+		if comments[issue.ID], err = completeComments(ctx, backend, issue.ID, issue.Key, embedded, total); err != nil {
+			return nil, err
+		}
+	}
+	return comments, nil
+}
+
+// completeComments returns the embedded comments of an issue, or pages through all of them if Jira truncated them.
+func completeComments(ctx context.Context, backend SearchBackend, id, key string, embedded []*jiraBaseClient.Comment, total int) ([]*jiraBaseClient.Comment, error) {
+	if len(embedded) >= total {
+		return embedded, nil
+	}
+	klog.V(5).Infof("Comments of %s were truncated at %d/%d, paging", key, len(embedded), total)
+	return IssueCommentList(ctx, backend, id)
+}
+
+func searchComments(ctx context.Context, backend SearchBackend, ids []string) ([]commentIssue, error) {
+	var issues []commentIssue
+	request := SearchRequest{
+		JQL:        fmt.Sprintf("id IN (%s)", strings.Join(ids, ",")),
+		Fields:     []string{"comment"},
+		MaxResults: len(ids),
+	}
+	for {
+		response, err := backend.Search(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("unable to search issue comments: %w", err)
+		}
+		for _, raw := range response.Issues {
+			var issue commentIssue
+			if err := json.Unmarshal(raw, &issue); err != nil {
+				return nil, fmt.Errorf("unable to decode issue comments: %w", err)
+			}
+			issues = append(issues, issue)
+		}
+		if len(response.Next) == 0 {
+			return issues, nil
+		}
+		request.Position = response.Next
+	}
+}
+
+// IssueCommentList pages through every comment of a single issue, oldest first.
+func IssueCommentList(ctx context.Context, backend SearchBackend, id string) ([]*jiraBaseClient.Comment, error) {
+	var comments []*jiraBaseClient.Comment
+	for startAt := 0; ; {
+		values := url.Values{}
+		values.Set("startAt", strconv.Itoa(startAt))
+		values.Set("maxResults", strconv.Itoa(commentPageSize))
+		values.Set("orderBy", "created")
+
+		var page commentPage
+		if err := getJSON(ctx, backend.Client(), fmt.Sprintf("rest/api/%s/issue/%s/comment?%s", backend.APIVersion(), id, values.Encode()), &page); err != nil {
+			return nil, fmt.Errorf("unable to get comments of issue %s at offset %d: %w", id, startAt, err)
+		}
+		comments = append(comments, page.Comments...)
+		startAt += len(page.Comments)
+		if len(page.Comments) == 0 || startAt >= page.Total {
+			return comments, nil
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package fakejira
+
+import (
+	"encoding/json"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ServeHTTP routes the requests under /rest/api/{version}/. Version 3 and the enhanced search are only served when the
+// deployment type is Cloud, and the search with startAt only when it is not.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	handlers := s.route(w, r)
+	if handlers == nil {
+		writeError(w, http.StatusNotFound, "No endpoint %s", r.URL.Path)
+		return
+	}
+	handler, ok := handlers[r.Method]
+	if !ok {
+		writeError(w, http.StatusMethodNotAllowed, "Method %s is not allowed for %s", r.Method, r.URL.Path)
+		return
+	}
+	handler()
+}
+
+// route returns the handlers of the path by method, or nil if the path does not exist.
+func (s *Server) route(w http.ResponseWriter, r *http.Request) map[string]func() {
+	if !strings.HasPrefix(r.URL.Path, "/rest/api/") {
+		return nil
+	}
+	version, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/rest/api/"), "/")
+	if version != "2" && (version != "3" || !s.cloud()) {
+		return nil
+	}
+	baseURL := "http://" + r.Host
+	query := r.URL.Query()
+
+	switch {
+	case path == "serverInfo":
+		return map[string]func(){http.MethodGet: func() { s.serveServerInfo(w, baseURL) }}
+	case path == "field":
+		return map[string]func(){http.MethodGet: func() { writeJSON(w, http.StatusOK, head(s.fields, len(s.fields))) }}
+	case path == "project":
+		return map[string]func(){http.MethodGet: func() { writeJSON(w, http.StatusOK, head(s.projects, len(s.projects))) }}
+	case path == "search" && !s.cloud():
+		return map[string]func(){http.MethodGet: func() { s.serveSearch(w, r, baseURL) }}
+	case path == "search/jql" && s.cloud():
+		return map[string]func(){http.MethodGet: func() { s.serveEnhancedSearch(w, r, baseURL) }}
+	case path == "issue":
+		return map[string]func(){http.MethodPost: func() { s.serveCreate(w, r, baseURL) }}
+	}
+
+	segments := strings.Split(path, "/")
+	if segments[0] != "issue" || len(segments) > 3 {
+		return nil
+	}
+	i, ok := s.issue(segments[1])
+	if !ok {
+		// every method of a missing issue is answered as Jira does
+		notFound := func() { writeError(w, http.StatusNotFound, "Issue Does Not Exist") }
+		return map[string]func(){http.MethodGet: notFound, http.MethodPost: notFound, http.MethodPut: notFound}
+	}
+	if len(segments) == 2 {
+		// Jira Server embeds every comment and the whole changelog in a single issue
+		limit := s.embeddedLimit()
+		if !s.cloud() {
+			limit = len(i.comments) + len(i.histories)
+		}
+		return map[string]func(){http.MethodGet: func() {
+			writeJSON(w, http.StatusOK, s.render(i, baseURL, splitList(query.Get("fields")), query.Get("expand"), limit))
+		}}
+	}
+	switch segments[2] {
+	case "comment":
+		return map[string]func(){
+			http.MethodGet:  func() { s.serveComments(w, r, i) },
+			http.MethodPost: func() { s.serveAddComment(w, r, i, baseURL) },
+		}
+	case "changelog":
+		// the changelog endpoint only exists on Jira Cloud
+		if !s.cloud() {
+			return nil
+		}
+		return map[string]func(){http.MethodGet: func() { s.serveChangelog(w, r, i) }}
+	case "remotelink":
+		return map[string]func(){
+			http.MethodGet:  func() { writeJSON(w, http.StatusOK, head(i.remoteLinks, len(i.remoteLinks))) },
+			http.MethodPost: func() { s.serveAddRemoteLink(w, r, i, baseURL) },
+		}
+	case "transitions":
+		return map[string]func(){
+			http.MethodGet: func() {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"transitions": head(s.transitions, len(s.transitions))})
+			},
+			http.MethodPost: func() { s.serveTransition(w, r, i) },
+		}
+	}
+	return nil
+}
+
+func (s *Server) cloud() bool {
+	return s.info.DeploymentType == "Cloud"
+}
+
+func (s *Server) serveServerInfo(w http.ResponseWriter, baseURL string) {
+	info := struct {
+		ServerInfo
+		ServerTime string `json:"serverTime"`
+	}{ServerInfo: s.info, ServerTime: s.now().Format(issueTimeFormat)}
+	if len(info.BaseURL) == 0 {
+		info.BaseURL = baseURL
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// page reads the startAt and maxResults of a request, capping maxResults at limit.
+func page(r *http.Request, defaultMaxResults, limit int) (int, int, bool) {
+	query := r.URL.Query()
+	startAt, maxResults := 0, defaultMaxResults
+	var err error
+	if v := query.Get("startAt"); len(v) > 0 {
+		if startAt, err = strconv.Atoi(v); err != nil || startAt < 0 {
+			return 0, 0, false
+		}
+	}
+	if v := query.Get("maxResults"); len(v) > 0 {
+		if maxResults, err = strconv.Atoi(v); err != nil || maxResults < 0 {
+			return 0, 0, false
+		}
+	}
+	if maxResults > limit {
+		maxResults = limit
+	}
+	return startAt, maxResults, true
+}
+
+// searchPage runs the JQL of the request and renders the page of issues that starts at startAt. Without fields, the
+// search of Jira Server returns the navigable fields, and the enhanced search of Jira Cloud only the ID of the issues.
+func (s *Server) searchPage(w http.ResponseWriter, r *http.Request, baseURL string, startAt, maxResults int) ([]interface{}, int, bool) {
+	query := r.URL.Query()
+	matched, err := s.search(query.Get("jql"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return nil, 0, false
+	}
+	fields := splitList(query.Get("fields"))
+	if len(fields) == 0 && !s.cloud() {
+		fields = []string{"*navigable"}
+	}
+	issues := []interface{}{}
+	for n := startAt; n < len(matched) && len(issues) < maxResults; n++ {
+		if len(fields) == 0 {
+			issues = append(issues, map[string]interface{}{"id": matched[n].id})
+			continue
+		}
+		issues = append(issues, s.render(matched[n], baseURL, fields, query.Get("expand"), s.embeddedLimit()))
+	}
+	return issues, len(matched), true
+}
+
+// serveSearch is the search of Jira Server, paged with startAt.
+func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request, baseURL string) {
+	startAt, maxResults, ok := page(r, DefaultMaxResults, s.maxResultsLimit())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "startAt and maxResults must be positive numbers")
+		return
+	}
+	issues, total, ok := s.searchPage(w, r, baseURL, startAt, maxResults)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"startAt":    startAt,
+		"maxResults": maxResults,
+		"total":      total,
+		"issues":     issues,
+	})
+}
+
+// serveEnhancedSearch is the search of Jira Cloud, paged with an opaque nextPageToken and without a total.
+func (s *Server) serveEnhancedSearch(w http.ResponseWriter, r *http.Request, baseURL string) {
+	_, maxResults, ok := page(r, DefaultMaxResults, s.maxResultsLimit())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "maxResults must be a positive number")
+		return
+	}
+	startAt := 0
+	if token := r.URL.Query().Get("nextPageToken"); len(token) > 0 {
+		var err error
+		if startAt, err = strconv.Atoi(strings.TrimPrefix(token, "page-")); err != nil || !strings.HasPrefix(token, "page-") {
+			writeError(w, http.StatusBadRequest, "Invalid nextPageToken %q", token)
+			return
+		}
+	}
+	issues, total, ok := s.searchPage(w, r, baseURL, startAt, maxResults)
+	if !ok {
+		return
+	}
+	result := map[string]interface{}{"issues": issues, "isLast": startAt+len(issues) >= total}
+	if next := startAt + len(issues); next < total {
+		result["nextPageToken"] = "page-" + strconv.Itoa(next)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) serveComments(w http.ResponseWriter, r *http.Request, i *issue) {
+	startAt, maxResults, ok := page(r, s.embeddedLimit(), s.embeddedLimit())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "startAt and maxResults must be positive numbers")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"startAt":    startAt,
+		"maxResults": maxResults,
+		"total":      len(i.comments),
+		"comments":   window(i.comments, startAt, maxResults),
+	})
+}
+
+func (s *Server) serveChangelog(w http.ResponseWriter, r *http.Request, i *issue) {
+	startAt, maxResults, ok := page(r, s.embeddedLimit(), s.embeddedLimit())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "startAt and maxResults must be positive numbers")
+		return
+	}
+	values := window(i.histories, startAt, maxResults)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"startAt":    startAt,
+		"maxResults": maxResults,
+		"total":      len(i.histories),
+		"isLast":     startAt+len(values) >= len(i.histories),
+		"values":     values,
+	})
+}
+
+// window returns at most n items from start.
+func window[T any](items []T, start, n int) []T {
+	if start > len(items) {
+		start = len(items)
+	}
+	return head(items[start:], n)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// decode reads the JSON body of the request, answering with a 400 if it is invalid.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	data, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: %v", err)
+		return false
+	}
+	return true
+}
+
+// serveCreate creates an issue in the project of its fields, numbered after the largest key of the project.
+func (s *Server) serveCreate(w http.ResponseWriter, r *http.Request, baseURL string) {
+	var request struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if !decode(w, r, &request) {
+		return
+	}
+	projectRef, _ := request.Fields["project"].(map[string]interface{})
+	var project *Project
+	for n := range s.projects {
+		if p := &s.projects[n]; projectRef != nil && (projectRef["key"] == p.Key || projectRef["id"] == p.ID) {
+			project = p
+		}
+	}
+	if project == nil {
+		writeJSON(w, http.StatusBadRequest, errorMessages{Errors: map[string]string{"project": "project is required"}})
+		return
+	}
+	if summary, _ := request.Fields["summary"].(string); len(summary) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorMessages{Errors: map[string]string{"summary": "You must specify a summary of the issue."}})
+		return
+	}
+
+	id, number := 0, int64(0)
+	for _, other := range s.issues {
+		if n, err := strconv.Atoi(other.id); err == nil && n > id {
+			id = n
+		}
+		if p, n := splitKey(other.key); p == project.Key && n > number {
+			number = n
+		}
+	}
+	i := &issue{id: strconv.Itoa(id + 1), key: project.Key + "-" + strconv.FormatInt(number+1, 10), fields: request.Fields}
+	delete(i.fields, "comment")
+	i.fields["project"] = map[string]interface{}{"id": project.ID, "key": project.Key, "name": project.Name}
+	i.fields["created"] = s.now().Format(issueTimeFormat)
+	if _, ok := i.fields["status"]; !ok {
+		i.fields["status"] = map[string]interface{}{"name": "New"}
+	}
+	s.touch(i)
+	s.issues = append(s.issues, i)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"id": i.id, "key": i.key, "self": baseURL + "/rest/api/2/issue/" + i.id})
+}
+
+func (s *Server) serveAddComment(w http.ResponseWriter, r *http.Request, i *issue, baseURL string) {
+	var comment map[string]interface{}
+	if !decode(w, r, &comment) {
+		return
+	}
+	if body, _ := comment["body"].(string); len(body) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorMessages{Errors: map[string]string{"comment": "Comment body can not be empty!"}})
+		return
+	}
+	id := 0
+	for _, other := range s.issues {
+		for _, existing := range other.comments {
+			existingID, _ := existing["id"].(string)
+			if n, err := strconv.Atoi(existingID); err == nil && n > id {
+				id = n
+			}
+		}
+	}
+	now := s.now().Format(issueTimeFormat)
+	comment["id"] = strconv.Itoa(id + 1)
+	comment["self"] = baseURL + "/rest/api/2/issue/" + i.id + "/comment/" + comment["id"].(string)
+	comment["created"] = now
+	comment["updated"] = now
+	i.comments = append(i.comments, comment)
+	s.touch(i)
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (s *Server) serveAddRemoteLink(w http.ResponseWriter, r *http.Request, i *issue, baseURL string) {
+	var link jiraBaseClient.RemoteLink
+	if !decode(w, r, &link) {
+		return
+	}
+	if link.Object == nil || len(link.Object.URL) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorMessages{Errors: map[string]string{"url": "'url' is required."}})
+		return
+	}
+	id := 0
+	for _, other := range s.issues {
+		for _, existing := range other.remoteLinks {
+			if existing.ID > id {
+				id = existing.ID
+			}
+		}
+	}
+	link.ID = id + 1
+	link.Self = baseURL + "/rest/api/2/issue/" + i.id + "/remotelink/" + strconv.Itoa(link.ID)
+	i.remoteLinks = append(i.remoteLinks, link)
+	s.touch(i)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"id": link.ID, "self": link.Self})
+}
+
+// serveTransition moves the issue to the status of the transition and records the change of status.
+func (s *Server) serveTransition(w http.ResponseWriter, r *http.Request, i *issue) {
+	var request struct {
+		Transition struct {
+			ID string `json:"id"`
+		} `json:"transition"`
+	}
+	if !decode(w, r, &request) {
+		return
+	}
+	for _, transition := range s.transitions {
+		if transition.ID != request.Transition.ID {
+			continue
+		}
+		var status map[string]interface{}
+		if err := roundTrip(transition.To, &status); err != nil {
+			writeError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		from, _ := i.fields["status"].(map[string]interface{})
+		item := jiraBaseClient.ChangelogItems{Field: "status", FieldType: "jira", To: transition.To.ID, ToString: transition.To.Name}
+		if from != nil {
+			item.From, item.FromString = from["id"], displayValue(from["name"])
+		}
+		i.fields["status"] = status
+		s.touch(i, item)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeError(w, http.StatusBadRequest, "Transition id '%s' is not valid for this issue.", request.Transition.ID)
+}
@@ -0,0 +1,702 @@
+package fakejira
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The JQL understood by the fake is the subset that the commands and their tests send: clauses of a field, an
+// operator and a value or list of values, combined with AND, OR, NOT and parentheses (or the & and | shorthands), and
+// an ORDER BY. Any other field, operator or function is rejected the way Jira rejects an invalid query, so that a test
+// does not silently pass against a query that a real Jira would not run.
+
+// query is a parsed JQL query.
+type query struct {
+	// where is nil when the query matches every issue
+	where   expression
+	orderBy []ordering
+}
+
+type ordering struct {
+	field      jqlField
+	descending bool
+}
+
+// env is what a query is evaluated against besides the issue: the time zone that dates without an offset are in and
+// the current time of the server for relative dates.
+type env struct {
+	location *time.Location
+	now      time.Time
+}
+
+type expression interface {
+	match(e env, i *issue) (bool, error)
+}
+
+type and []expression
+
+func (a and) match(e env, i *issue) (bool, error) {
+	for _, expr := range a {
+		if ok, err := expr.match(e, i); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+type or []expression
+
+func (o or) match(e env, i *issue) (bool, error) {
+	for _, expr := range o {
+		if ok, err := expr.match(e, i); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+type not struct {
+	expression
+}
+
+func (n not) match(e env, i *issue) (bool, error) {
+	ok, err := n.expression.match(e, i)
+	return !ok, err
+}
+
+type fieldKind int
+
+const (
+	// kindValue fields are compared to the id, key, name or value of the field, ignoring case
+	kindValue fieldKind = iota
+	// kindText fields can only be searched for the words they contain
+	kindText
+	kindDate
+	kindNumber
+	// kindKey fields are issue keys, ordered by project and number
+	kindKey
+)
+
+// jqlField is a field that can be searched, and the fields of an issue it is read from.
+type jqlField struct {
+	name   string
+	kind   fieldKind
+	fields []string
+}
+
+// jqlFields maps the names of the fields of JQL to the fields of an issue. Custom fields are resolved by Server.field.
+var jqlFields = map[string]jqlField{
+	"affectedversion": {kind: kindValue, fields: []string{"versions"}},
+	"assignee":        {kind: kindValue, fields: []string{"assignee"}},
+	"comment":         {kind: kindText, fields: []string{"comment"}},
+	"component":       {kind: kindValue, fields: []string{"components"}},
+	"created":         {kind: kindDate, fields: []string{"created"}},
+	"createddate":     {kind: kindDate, fields: []string{"created"}},
+	"creator":         {kind: kindValue, fields: []string{"creator"}},
+	"description":     {kind: kindText, fields: []string{"description"}},
+	"duedate":         {kind: kindDate, fields: []string{"duedate"}},
+	"fixversion":      {kind: kindValue, fields: []string{"fixVersions"}},
+	"id":              {kind: kindNumber, fields: []string{"id"}},
+	"issue":           {kind: kindKey, fields: []string{"key"}},
+	"issuekey":        {kind: kindKey, fields: []string{"key"}},
+	"issuetype":       {kind: kindValue, fields: []string{"issuetype"}},
+	"key":             {kind: kindKey, fields: []string{"key"}},
+	"labels":          {kind: kindValue, fields: []string{"labels"}},
+	"level":           {kind: kindValue, fields: []string{"security"}},
+	"priority":        {kind: kindValue, fields: []string{"priority"}},
+	"project":         {kind: kindValue, fields: []string{"project"}},
+	"reporter":        {kind: kindValue, fields: []string{"reporter"}},
+	"resolution":      {kind: kindValue, fields: []string{"resolution"}},
+	"resolutiondate":  {kind: kindDate, fields: []string{"resolutiondate"}},
+	"resolved":        {kind: kindDate, fields: []string{"resolutiondate"}},
+	"status":          {kind: kindValue, fields: []string{"status"}},
+	"summary":         {kind: kindText, fields: []string{"summary"}},
+	"text":            {kind: kindText, fields: []string{"summary", "description", "environment", "comment"}},
+	"type":            {kind: kindValue, fields: []string{"issuetype"}},
+	"updated":         {kind: kindDate, fields: []string{"updated"}},
+	"updateddate":     {kind: kindDate, fields: []string{"updated"}},
+}
+
+// values returns the searchable values of the field of the issue: the id, key, name and value of objects, every
+// element of arrays and the body of comments.
+func (f jqlField) values(i *issue) []string {
+	var values []string
+	for _, name := range f.fields {
+		switch name {
+		case "id":
+			values = append(values, i.id)
+		case "key":
+			values = append(values, i.key)
+		case "comment":
+			for _, comment := range i.comments {
+				values = appendValues(values, comment["body"])
+			}
+		default:
+			values = appendValues(values, i.fields[name])
+		}
+	}
+	return values
+}
+
+func appendValues(values []string, v interface{}) []string {
+	switch v := v.(type) {
+	case nil:
+	case string:
+		if len(v) > 0 {
+			values = append(values, v)
+		}
+	case float64:
+		values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		values = append(values, strconv.FormatBool(v))
+	case []interface{}:
+		for _, item := range v {
+			values = appendValues(values, item)
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"id", "key", "name", "value", "accountId", "emailAddress", "displayName"} {
+			values = appendValues(values, v[key])
+		}
+	}
+	return values
+}
+
+// clause compares a field to the values of the query.
+type clause struct {
+	field    jqlField
+	operator string
+	values   []value
+}
+
+// value is a literal of a query. EMPTY and NULL are represented by empty.
+type value struct {
+	text  string
+	empty bool
+}
+
+func (c *clause) match(e env, i *issue) (bool, error) {
+	actual := c.field.values(i)
+	switch c.operator {
+	case "is":
+		return len(actual) == 0, nil
+	case "is not":
+		return len(actual) > 0, nil
+	}
+	if len(c.values) == 1 && c.values[0].empty {
+		switch c.operator {
+		case "=":
+			return len(actual) == 0, nil
+		case "!=":
+			return len(actual) > 0, nil
+		}
+	}
+
+	switch c.operator {
+	case "~", "!~":
+		contains := false
+		for _, v := range actual {
+			if strings.Contains(strings.ToLower(v), strings.ToLower(c.values[0].text)) {
+				contains = true
+				break
+			}
+		}
+		return contains == (c.operator == "~"), nil
+	case "=", "in":
+		return c.any(e, actual, func(n int) bool { return n == 0 })
+	case "!=", "not in":
+		// like Jira, issues without a value match neither = nor !=
+		if len(actual) == 0 {
+			return false, nil
+		}
+		found, err := c.any(e, actual, func(n int) bool { return n == 0 })
+		return !found, err
+	case ">":
+		return c.any(e, actual, func(n int) bool { return n > 0 })
+	case ">=":
+		return c.any(e, actual, func(n int) bool { return n >= 0 })
+	case "<":
+		return c.any(e, actual, func(n int) bool { return n < 0 })
+	case "<=":
+		return c.any(e, actual, func(n int) bool { return n <= 0 })
+	}
+	return false, fmt.Errorf("the operator '%s' is not supported", c.operator)
+}
+
+// any reports whether any value of the issue compares to any value of the query as accepted.
+func (c *clause) any(e env, actual []string, accept func(n int) bool) (bool, error) {
+	for _, expected := range c.values {
+		if expected.empty {
+			if len(actual) == 0 && accept(0) {
+				return true, nil
+			}
+			continue
+		}
+		for _, v := range actual {
+			n, err := c.field.compare(e, v, expected.text)
+			if err != nil {
+				return false, err
+			}
+			if accept(n) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// compare compares a value of an issue to a literal of the query.
+func (f jqlField) compare(e env, actual, expected string) (int, error) {
+	switch f.kind {
+	case kindDate:
+		a, err := parseIssueTime(actual)
+		if err != nil {
+			return 0, err
+		}
+		b, err := parseQueryDate(e, expected)
+		if err != nil {
+			return 0, fmt.Errorf("date value '%s' for field '%s' is invalid: %v", expected, f.name, err)
+		}
+		return a.Compare(b), nil
+	case kindNumber:
+		a, err := strconv.ParseInt(actual, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		b, err := strconv.ParseInt(expected, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("the value '%s' is not a valid %s", expected, f.name)
+		}
+		return compareInts(a, b), nil
+	case kindKey:
+		return compareKeys(actual, expected), nil
+	default:
+		if strings.EqualFold(actual, expected) {
+			return 0, nil
+		}
+		return strings.Compare(strings.ToLower(actual), strings.ToLower(expected)), nil
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareKeys orders issue keys by project and then by number.
+func compareKeys(a, b string) int {
+	aProject, aNumber := splitKey(a)
+	bProject, bNumber := splitKey(b)
+	if n := strings.Compare(strings.ToUpper(aProject), strings.ToUpper(bProject)); n != 0 {
+		return n
+	}
+	return compareInts(aNumber, bNumber)
+}
+
+func splitKey(key string) (string, int64) {
+	project, number, _ := strings.Cut(key, "-")
+	n, _ := strconv.ParseInt(number, 10, 64)
+	return project, n
+}
+
+// issueTimeFormat is the format of the dates of issues.
+const issueTimeFormat = "2006-01-02T15:04:05.000-0700"
+
+func parseIssueTime(s string) (time.Time, error) {
+	return time.Parse(issueTimeFormat, s)
+}
+
+var relativeDatePattern = regexp.MustCompile(`^([-+]?)(\d+)([wdhm])$`)
+
+// parseQueryDate parses the absolute dates of JQL, which are in the time zone of the server and have a precision of a
+// minute, and relative dates such as -14d.
+func parseQueryDate(e env, s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if m := relativeDatePattern.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		unit := map[string]time.Duration{"w": 7 * 24 * time.Hour, "d": 24 * time.Hour, "h": time.Hour, "m": time.Minute}[m[3]]
+		d := time.Duration(n) * unit
+		if m[1] == "-" {
+			d = -d
+		}
+		return e.now.Add(d), nil
+	}
+	for _, layout := range []string{"2006/1/2 15:4", "2006-1-2 15:4", "2006/1/2", "2006-1-2"} {
+		if t, err := time.ParseInLocation(layout, s, e.location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("use the format \"yyyy/MM/dd HH:mm\", \"yyyy-MM-dd\" or a period such as \"-14d\"")
+}
+
+// sortIssues orders the issues by the ORDER BY of the query. Issues that compare equal keep their order, as do all
+// issues of a query without ORDER BY.
+func (q *query) sortIssues(e env, issues []*issue) {
+	sort.SliceStable(issues, func(a, b int) bool {
+		for _, order := range q.orderBy {
+			n := order.field.order(e, issues[a], issues[b])
+			if n == 0 {
+				continue
+			}
+			if order.descending {
+				return n > 0
+			}
+			return n < 0
+		}
+		return false
+	})
+}
+
+// order compares the first value of the field of two issues. Issues without a value are ordered last.
+func (f jqlField) order(e env, a, b *issue) int {
+	aValues, bValues := f.values(a), f.values(b)
+	switch {
+	case len(aValues) == 0 && len(bValues) == 0:
+		return 0
+	case len(aValues) == 0:
+		return 1
+	case len(bValues) == 0:
+		return -1
+	}
+	if f.kind == kindDate {
+		aTime, aErr := parseIssueTime(aValues[0])
+		bTime, bErr := parseIssueTime(bValues[0])
+		if aErr == nil && bErr == nil {
+			return aTime.Compare(bTime)
+		}
+	}
+	n, err := f.compare(e, aValues[0], bValues[0])
+	if err != nil {
+		return strings.Compare(aValues[0], bValues[0])
+	}
+	return n
+}
+
+// token is a lexical element of a query. Quoted strings are never keywords.
+type token struct {
+	text   string
+	quoted bool
+}
+
+func (t token) is(keywords ...string) bool {
+	if t.quoted {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(t.text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenize(jql string) ([]token, error) {
+	var tokens []token
+	runes := []rune(jql)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				b.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("the quoted string starting at character %d is not terminated", i)
+			}
+			tokens = append(tokens, token{text: b.String(), quoted: true})
+			i = j + 1
+		case r == '(' || r == ')' || r == ',' || r == '~':
+			tokens = append(tokens, token{text: string(r)})
+			i++
+		case r == '!' || r == '<' || r == '>' || r == '=':
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '!' && runes[i+1] == '~')) {
+				tokens = append(tokens, token{text: string(runes[i : i+2])})
+				i += 2
+			} else {
+				tokens = append(tokens, token{text: string(r)})
+				i++
+			}
+		case r == '&' || r == '|':
+			tokens = append(tokens, token{text: string(r)})
+			i++
+			if i < len(runes) && runes[i] == r {
+				i++
+			}
+		default:
+			j := i
+			for ; j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune(`"'(),~!<>=&|`, runes[j]); j++ {
+			}
+			tokens = append(tokens, token{text: string(runes[i:j])})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser of JQL. resolve returns the field of a name, or an error if it does not exist.
+type parser struct {
+	tokens  []token
+	pos     int
+	resolve func(name string) (jqlField, error)
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) next() (token, bool) {
+	t, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return t, ok
+}
+
+func (p *parser) parse() (*query, error) {
+	q := &query{}
+	if t, ok := p.peek(); ok && !t.is("order") {
+		where, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		q.where = where
+	}
+	if t, ok := p.next(); ok {
+		if !t.is("order") {
+			return nil, fmt.Errorf("expecting either 'OR' or 'AND' but got '%s'", t.text)
+		}
+		if t, ok := p.next(); !ok || !t.is("by") {
+			return nil, fmt.Errorf("expecting 'BY' after 'ORDER'")
+		}
+		for {
+			t, ok := p.next()
+			if !ok {
+				return nil, fmt.Errorf("expecting a field name after 'ORDER BY'")
+			}
+			field, err := p.resolve(t.text)
+			if err != nil {
+				return nil, err
+			}
+			order := ordering{field: field}
+			if t, ok := p.peek(); ok && t.is("asc", "desc") {
+				order.descending = t.is("desc")
+				p.pos++
+			}
+			q.orderBy = append(q.orderBy, order)
+			if t, ok := p.peek(); !ok || t.text != "," || t.quoted {
+				break
+			}
+			p.pos++
+		}
+		if t, ok := p.next(); ok {
+			return nil, fmt.Errorf("unexpected '%s' after the ORDER BY clause", t.text)
+		}
+	}
+	return q, nil
+}
+
+func (p *parser) parseOr() (expression, error) {
+	expr, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := or{expr}
+	for {
+		t, ok := p.peek()
+		if !ok || !t.is("or", "|") {
+			break
+		}
+		p.pos++
+		if expr, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *parser) parseAnd() (expression, error) {
+	expr, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	exprs := and{expr}
+	for {
+		t, ok := p.peek()
+		if !ok || !t.is("and", "&") {
+			break
+		}
+		p.pos++
+		if expr, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *parser) parseUnary() (expression, error) {
+	t, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("the query ended where a clause was expected")
+	}
+	switch {
+	case t.is("not", "!"):
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not{expr}, nil
+	case t.is("("):
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.next(); !ok || !t.is(")") {
+			return nil, fmt.Errorf("expecting ')' to close '('")
+		}
+		return expr, nil
+	}
+	field, err := p.resolve(t.text)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseClause(field)
+}
+
+func (p *parser) parseClause(field jqlField) (expression, error) {
+	t, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("expecting an operator after the field '%s'", field.name)
+	}
+	c := &clause{field: field, operator: strings.ToLower(t.text)}
+	switch {
+	case t.is("not"):
+		if t, ok := p.next(); !ok || !t.is("in") {
+			return nil, fmt.Errorf("expecting 'IN' after 'NOT'")
+		}
+		c.operator = "not in"
+	case t.is("is"):
+		if t, ok := p.peek(); ok && t.is("not") {
+			p.pos++
+			c.operator = "is not"
+		}
+		if t, ok := p.next(); !ok || !t.is("empty", "null") {
+			return nil, fmt.Errorf("expecting 'EMPTY' after '%s'", strings.ToUpper(c.operator))
+		}
+		return c, nil
+	case t.is("in", "=", "!=", ">", ">=", "<", "<=", "~", "!~"):
+	default:
+		return nil, fmt.Errorf("expecting an operator after the field '%s' but got '%s'", field.name, t.text)
+	}
+
+	if err := field.supports(c.operator); err != nil {
+		return nil, err
+	}
+	if c.operator == "in" || c.operator == "not in" {
+		if t, ok := p.next(); !ok || !t.is("(") {
+			return nil, fmt.Errorf("expecting '(' after '%s'", strings.ToUpper(c.operator))
+		}
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, v)
+			t, ok := p.next()
+			if ok && t.is(")") {
+				break
+			}
+			if !ok || !t.is(",") {
+				return nil, fmt.Errorf("expecting ',' or ')' in the list of values of '%s'", field.name)
+			}
+		}
+		return c, nil
+	}
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	c.values = []value{v}
+	return c, nil
+}
+
+// supports rejects the operators that Jira does not allow for the field.
+func (f jqlField) supports(operator string) error {
+	switch {
+	case f.kind == kindText && operator != "~" && operator != "!~":
+		return fmt.Errorf("the operator '%s' is not supported by the '%s' field", operator, f.name)
+	case f.kind != kindText && (operator == "~" || operator == "!~"):
+		return fmt.Errorf("the operator '%s' is not supported by the '%s' field", operator, f.name)
+	case f.kind == kindValue && strings.ContainsAny(operator, "<>"):
+		return fmt.Errorf("the operator '%s' is not supported by the '%s' field", operator, f.name)
+	}
+	return nil
+}
+
+func (p *parser) parseValue() (value, error) {
+	t, ok := p.next()
+	if !ok {
+		return value{}, fmt.Errorf("the query ended where a value was expected")
+	}
+	switch {
+	case t.is("empty", "null"):
+		return value{empty: true}, nil
+	case t.quoted:
+		return value{text: t.text}, nil
+	case t.is("(", ")", ",", "and", "or", "&", "|"):
+		return value{}, fmt.Errorf("expecting a value but got '%s'", t.text)
+	}
+	// functions are not supported, except now() which is the current time of the server
+	if next, ok := p.peek(); ok && next.is("(") {
+		if !t.is("now") {
+			return value{}, fmt.Errorf("the function '%s' is not supported", t.text)
+		}
+		p.pos++
+		if !p.expect(")") {
+			return value{}, fmt.Errorf("expecting ')' after '%s('", t.text)
+		}
+		return value{text: "0m"}, nil
+	}
+	return value{text: t.text}, nil
+}
+
+func (p *parser) expect(text string) bool {
+	t, ok := p.next()
+	return ok && t.is(text)
+}
+
+// parseQuery parses the JQL, resolving the names of fields with resolve.
+func parseQuery(jql string, resolve func(name string) (jqlField, error)) (*query, error) {
+	tokens, err := tokenize(jql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, resolve: resolve}
+	return p.parse()
+}
@@ -0,0 +1,446 @@
+// Package fakejira is an in-memory Jira for tests. It serves the REST endpoints that the commands of this repository
+// read from and write to, from a Dataset that is built in code or loaded from a fixture, so that the commands can run
+// end-to-end against an httptest.Server without a network:
+//
+//	dataset, err := fakejira.LoadDataset("testdata/dataset.json")
+//	...
+//	jira, err := fakejira.New(dataset)
+//	...
+//	server := httptest.NewServer(jira)
+//	defer server.Close()
+package fakejira
+
+import (
+	"encoding/json"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxResults is the number of issues of a search page when the request does not set maxResults.
+	DefaultMaxResults = 50
+	// DefaultMaxResultsLimit is the largest page of a search, as maxResults is capped by Jira.
+	DefaultMaxResultsLimit = 1000
+	// DefaultEmbeddedLimit is the number of comments and changelog histories embedded in an issue, beyond which they
+	// must be paged through with their own endpoints.
+	DefaultEmbeddedLimit = 100
+)
+
+// Dataset is the content of a fake Jira, in the JSON representation of the Jira REST API.
+type Dataset struct {
+	ServerInfo  ServerInfo                  `json:"serverInfo"`
+	Fields      []jiraBaseClient.Field      `json:"fields,omitempty"`
+	Projects    []Project                   `json:"projects,omitempty"`
+	Transitions []jiraBaseClient.Transition `json:"transitions,omitempty"`
+	Issues      []Issue                     `json:"issues,omitempty"`
+}
+
+// ServerInfo is served from /serverInfo, with the current time of the server in ServerTimeZone. JQL dates without an
+// offset are also interpreted in ServerTimeZone, which defaults to UTC.
+type ServerInfo struct {
+	BaseURL        string `json:"baseUrl,omitempty"`
+	Version        string `json:"version,omitempty"`
+	DeploymentType string `json:"deploymentType,omitempty"`
+	ServerTitle    string `json:"serverTitle,omitempty"`
+	ServerTimeZone string `json:"serverTimeZone,omitempty"`
+}
+
+// Project is a project of the fake. Issues created in it are numbered after the largest key of the project.
+type Project struct {
+	ID   string `json:"id"`
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// Issue is an issue of the dataset. Fields are served as they are, except for the comments, which are read from the
+// comments of the comment field, and the changelog, which is only embedded when it is expanded. RemoteLinks are served
+// from the remote link endpoint of the issue.
+type Issue struct {
+	ID          string                      `json:"id"`
+	Key         string                      `json:"key"`
+	Fields      map[string]interface{}      `json:"fields"`
+	Changelog   *jiraBaseClient.Changelog   `json:"changelog,omitempty"`
+	RemoteLinks []jiraBaseClient.RemoteLink `json:"remoteLinks,omitempty"`
+}
+
+// LoadDataset reads a dataset from a JSON fixture.
+func LoadDataset(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read dataset: %w", err)
+	}
+	dataset := &Dataset{}
+	if err := json.Unmarshal(data, dataset); err != nil {
+		return nil, fmt.Errorf("unable to decode dataset %s: %w", path, err)
+	}
+	return dataset, nil
+}
+
+// issue is the state of an issue of the server.
+type issue struct {
+	id     string
+	key    string
+	fields map[string]interface{}
+	// comments are the comments of the comment field, which is not kept in fields
+	comments    []map[string]interface{}
+	histories   []jiraBaseClient.ChangelogHistory
+	remoteLinks []jiraBaseClient.RemoteLink
+}
+
+// Server is a fake Jira that serves the issues of a Dataset. Searches, comments, changelogs and remote links are
+// served on both API versions, the enhanced search of Jira Cloud only when the deployment type is Cloud. Issues can be
+// created, commented, transitioned and linked, which updates them as Jira would.
+type Server struct {
+	// Now is the clock of the server, which relative dates in JQL and the changes made to issues use. It defaults to
+	// time.Now.
+	Now func() time.Time
+	// MaxResultsLimit caps the maxResults of searches, DefaultMaxResultsLimit if it is not set.
+	MaxResultsLimit int
+	// EmbeddedLimit is the number of comments and changelog histories embedded in a search result or issue,
+	// DefaultEmbeddedLimit if it is not set.
+	EmbeddedLimit int
+
+	lock        sync.Mutex
+	info        ServerInfo
+	location    *time.Location
+	fields      []jiraBaseClient.Field
+	projects    []Project
+	transitions []jiraBaseClient.Transition
+	issues      []*issue
+	requests    []string
+}
+
+// New creates a server for the dataset. The dataset is copied, so later changes to it are not served.
+func New(dataset *Dataset) (*Server, error) {
+	s := &Server{
+		info:        dataset.ServerInfo,
+		fields:      dataset.Fields,
+		projects:    dataset.Projects,
+		transitions: dataset.Transitions,
+		location:    time.UTC,
+	}
+	if len(s.info.DeploymentType) == 0 {
+		s.info.DeploymentType = "Server"
+	}
+	if len(s.info.Version) == 0 {
+		s.info.Version = "9.12.0"
+	}
+	if len(s.info.ServerTimeZone) > 0 {
+		location, err := time.LoadLocation(s.info.ServerTimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server time zone: %w", err)
+		}
+		s.location = location
+	}
+	for _, source := range dataset.Issues {
+		i, err := newIssue(source)
+		if err != nil {
+			return nil, err
+		}
+		s.issues = append(s.issues, i)
+	}
+	return s, nil
+}
+
+func newIssue(source Issue) (*issue, error) {
+	if len(source.ID) == 0 || len(source.Key) == 0 {
+		return nil, fmt.Errorf("issue %q must have an id and a key", source.Key)
+	}
+	i := &issue{id: source.ID, key: source.Key, fields: make(map[string]interface{}, len(source.Fields))}
+	// the fields are copied through JSON so that the issue shares nothing with the dataset
+	if err := roundTrip(source.Fields, &i.fields); err != nil {
+		return nil, fmt.Errorf("invalid fields of issue %s: %w", source.Key, err)
+	}
+	if i.fields == nil {
+		i.fields = make(map[string]interface{})
+	}
+	if comment, ok := i.fields["comment"]; ok {
+		var page struct {
+			Comments []map[string]interface{} `json:"comments"`
+		}
+		if err := roundTrip(comment, &page); err != nil {
+			return nil, fmt.Errorf("invalid comments of issue %s: %w", source.Key, err)
+		}
+		i.comments = page.Comments
+		delete(i.fields, "comment")
+	}
+	if source.Changelog != nil {
+		i.histories = append(i.histories, source.Changelog.Histories...)
+	}
+	i.remoteLinks = append(i.remoteLinks, source.RemoteLinks...)
+	return i, nil
+}
+
+func roundTrip(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
+
+// Requests returns the method and path of every request served so far, in order.
+func (s *Server) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Issue returns the issue with the given ID or key, with its comments and changelog, as a client decodes it.
+func (s *Server) Issue(idOrKey string) (*jiraBaseClient.Issue, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i, ok := s.issue(idOrKey)
+	if !ok {
+		return nil, false
+	}
+	out := &jiraBaseClient.Issue{}
+	if err := roundTrip(s.render(i, "", nil, "changelog", len(i.comments)+len(i.histories)), out); err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// UpdateIssue sets the fields of the issue, records the change in its changelog and marks it updated now.
+func (s *Server) UpdateIssue(idOrKey string, fields map[string]interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i, ok := s.issue(idOrKey)
+	if !ok {
+		return fmt.Errorf("issue %s does not exist", idOrKey)
+	}
+	var items []jiraBaseClient.ChangelogItems
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var value interface{}
+		if err := roundTrip(fields[name], &value); err != nil {
+			return fmt.Errorf("invalid field %s: %w", name, err)
+		}
+		items = append(items, jiraBaseClient.ChangelogItems{
+			Field:      name,
+			FieldType:  "jira",
+			FromString: displayValue(i.fields[name]),
+			ToString:   displayValue(value),
+		})
+		i.fields[name] = value
+	}
+	s.touch(i, items...)
+	return nil
+}
+
+// displayValue is the string of a field value that changelogs record.
+func displayValue(v interface{}) string {
+	return strings.Join(appendValues(nil, displayable(v)), ", ")
+}
+
+// displayable reduces objects to their name or value, as they are displayed in a changelog.
+func displayable(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, key := range []string{"name", "value", "displayName", "key"} {
+			if value, ok := v[key]; ok {
+				return value
+			}
+		}
+		return nil
+	case []interface{}:
+		values := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			values = append(values, displayable(item))
+		}
+		return values
+	}
+	return v
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now().In(s.location)
+	}
+	return time.Now().In(s.location)
+}
+
+// touch marks the issue as updated now, with a changelog history of the items if there are any.
+func (s *Server) touch(i *issue, items ...jiraBaseClient.ChangelogItems) {
+	now := s.now().Format(issueTimeFormat)
+	i.fields["updated"] = now
+	if len(items) == 0 {
+		return
+	}
+	id := 0
+	for _, other := range s.issues {
+		for _, history := range other.histories {
+			if n, err := strconv.Atoi(history.Id); err == nil && n > id {
+				id = n
+			}
+		}
+	}
+	i.histories = append(i.histories, jiraBaseClient.ChangelogHistory{Id: strconv.Itoa(id + 1), Created: now, Items: items})
+}
+
+func (s *Server) issue(idOrKey string) (*issue, bool) {
+	for _, i := range s.issues {
+		if i.id == idOrKey || strings.EqualFold(i.key, idOrKey) {
+			return i, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Server) env() env {
+	return env{location: s.location, now: s.now()}
+}
+
+// field resolves a field of JQL: one of jqlFields, cf[id], the ID of a field of the dataset or its name.
+func (s *Server) field(name string) (jqlField, error) {
+	if field, ok := jqlFields[strings.ToLower(name)]; ok {
+		field.name = name
+		return field, nil
+	}
+	id := name
+	if strings.HasPrefix(strings.ToLower(name), "cf[") && strings.HasSuffix(name, "]") {
+		id = "customfield_" + name[3:len(name)-1]
+	}
+	for _, field := range s.fields {
+		if field.ID != id && !strings.EqualFold(field.Name, name) {
+			continue
+		}
+		kind := kindValue
+		switch field.Schema.Type {
+		case "date", "datetime":
+			kind = kindDate
+		case "number":
+			kind = kindNumber
+		case "string":
+			if !strings.HasSuffix(field.Schema.Custom, ":select") {
+				kind = kindText
+			}
+		}
+		return jqlField{name: name, kind: kind, fields: []string{field.ID}}, nil
+	}
+	return jqlField{}, fmt.Errorf("field '%s' does not exist or you do not have permission to view it", name)
+}
+
+// search returns the issues that match the JQL, in the order of the query.
+func (s *Server) search(jql string) ([]*issue, error) {
+	q, err := parseQuery(jql, s.field)
+	if err != nil {
+		return nil, fmt.Errorf("error in the JQL query: %v", err)
+	}
+	e := s.env()
+	var matched []*issue
+	for _, i := range s.issues {
+		if q.where != nil {
+			ok, err := q.where.match(e, i)
+			if err != nil {
+				return nil, fmt.Errorf("error in the JQL query: %v", err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		matched = append(matched, i)
+	}
+	q.sortIssues(e, matched)
+	return matched, nil
+}
+
+// render returns the JSON object of the issue with the requested fields, all of them if there are none or *all is
+// requested, and the changelog if it is expanded. *navigable requests every field but the comments.
+func (s *Server) render(i *issue, baseURL string, fields []string, expand string, limit int) map[string]interface{} {
+	all, navigable := len(fields) == 0, false
+	requested := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "*all":
+			all = true
+		case "*navigable":
+			navigable = true
+		default:
+			requested[field] = true
+		}
+	}
+	rendered := make(map[string]interface{}, len(i.fields)+1)
+	for name, value := range i.fields {
+		if all || navigable || requested[name] {
+			rendered[name] = value
+		}
+	}
+	if all || requested["comment"] {
+		rendered["comment"] = map[string]interface{}{
+			"startAt":    0,
+			"maxResults": limit,
+			"total":      len(i.comments),
+			"comments":   head(i.comments, limit),
+		}
+	}
+	out := map[string]interface{}{
+		"id":     i.id,
+		"key":    i.key,
+		"self":   baseURL + "/rest/api/2/issue/" + i.id,
+		"fields": rendered,
+	}
+	for _, e := range strings.Split(expand, ",") {
+		if strings.TrimSpace(e) == "changelog" {
+			out["changelog"] = map[string]interface{}{
+				"startAt":    0,
+				"maxResults": limit,
+				"total":      len(i.histories),
+				"histories":  head(i.histories, limit),
+			}
+		}
+	}
+	return out
+}
+
+// head returns at most the first n items.
+func head[T any](items []T, n int) []T {
+	if len(items) > n {
+		items = items[:n]
+	}
+	if items == nil {
+		items = []T{}
+	}
+	return items
+}
+
+func (s *Server) embeddedLimit() int {
+	if s.EmbeddedLimit > 0 {
+		return s.EmbeddedLimit
+	}
+	return DefaultEmbeddedLimit
+}
+
+func (s *Server) maxResultsLimit() int {
+	if s.MaxResultsLimit > 0 {
+		return s.MaxResultsLimit
+	}
+	return DefaultMaxResultsLimit
+}
+
+// errorMessages is the body of the errors of Jira.
+type errorMessages struct {
+	ErrorMessages []string          `json:"errorMessages"`
+	Errors        map[string]string `json:"errors"`
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, errorMessages{ErrorMessages: []string{fmt.Sprintf(format, args...)}, Errors: map[string]string{}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,370 @@
+package fakejira
+
+import (
+	"context"
+	"encoding/json"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	helpers "github.com/bradmwilliams/jira-migration/pkg/jira"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// now is the clock of the fake in the tests, a week after the last change of the dataset.
+var now = time.Date(2024, 6, 18, 12, 0, 0, 0, time.UTC)
+
+func newTestServer(t *testing.T, deployment string) (*Server, *httptest.Server) {
+	t.Helper()
+	dataset, err := LoadDataset("testdata/dataset.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deployment) > 0 {
+		dataset.ServerInfo.DeploymentType = deployment
+	}
+	fake, err := New(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Now = func() time.Time { return now }
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func newTestBackend(t *testing.T, server *httptest.Server) helpers.SearchBackend {
+	t.Helper()
+	options := &helpers.JiraOptions{Endpoint: server.URL}
+	client, err := options.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := helpers.NewSearchBackend(context.Background(), client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func keys(issues []*issue) []string {
+	var keys []string
+	for _, i := range issues {
+		keys = append(keys, i.key)
+	}
+	return keys
+}
+
+func TestSearchJQL(t *testing.T) {
+	fake, _ := newTestServer(t, "")
+	for _, tt := range []struct {
+		jql      string
+		expected []string
+		err      string
+	}{
+		{jql: "", expected: []string{"OCPBUGS-35865", "OCPBUGS-35866", "OCPBUGS-35867", "OCPBUGS-35868", "TRT-1716"}},
+		{jql: "project=OCPBUGS ORDER BY key DESC", expected: []string{"OCPBUGS-35868", "OCPBUGS-35867", "OCPBUGS-35866", "OCPBUGS-35865"}},
+		{jql: "id IN (15000005,15000002)", expected: []string{"OCPBUGS-35866", "TRT-1716"}},
+		{jql: "project = 12323832 OR key = ocpbugs-35866", expected: []string{"OCPBUGS-35866", "TRT-1716"}},
+		// the query that ci-search-jira-client runs by default
+		{jql: "project=OCPBUGS&created>='-14d'&status!='CLOSED'&affectedVersion IN (4.14,4.13,4.12)", expected: []string{"OCPBUGS-35865", "OCPBUGS-35868"}},
+		// dates are in the time zone of the server, at minute precision
+		{jql: `updated >= "2024/06/12 16:40" ORDER BY updated ASC`, expected: []string{"OCPBUGS-35865", "OCPBUGS-35867"}},
+		{jql: `updated >= "2024/06/12 16:41"`, expected: []string{"OCPBUGS-35867"}},
+		{jql: `(project=OCPBUGS)&updated>='2024/6/14 9:0'`, expected: []string{"OCPBUGS-35867"}},
+		{jql: `created >= 2024-06-07 AND created < "2024-06-11" ORDER BY created DESC`, expected: []string{"OCPBUGS-35867", "TRT-1716"}},
+		{jql: `assignee IS EMPTY AND project = OCPBUGS`, expected: []string{"OCPBUGS-35866", "OCPBUGS-35867", "OCPBUGS-35868"}},
+		{jql: `resolution != Duplicate`, expected: nil},
+		{jql: `NOT status in (New, Closed)`, expected: []string{"OCPBUGS-35865"}},
+		{jql: `text ~ "encryption" OR comment ~ "every restart"`, expected: []string{"OCPBUGS-35865", "OCPBUGS-35866"}},
+		{jql: `"Target Version" = 4.17.0 AND cf[12313441] = Approved`, expected: []string{"OCPBUGS-35865"}},
+		{jql: `level IS NOT EMPTY`, expected: []string{"OCPBUGS-35868"}},
+		{jql: `updated >= now()`, expected: nil},
+		{jql: `project = OCPBUGS AND sprint = 1`, err: "field 'sprint' does not exist"},
+		{jql: `summary = "Embargoed vulnerability"`, err: "the operator '=' is not supported by the 'summary' field"},
+		{jql: `created >= "yesterday"`, err: "date value 'yesterday' for field 'created' is invalid"},
+		{jql: `project = OCPBUGS AND (status = New`, err: "expecting ')'"},
+		{jql: `updated >= startOfDay()`, err: "the function 'startOfDay' is not supported"},
+	} {
+		t.Run(tt.jql, func(t *testing.T) {
+			issues, err := fake.search(tt.jql)
+			if len(tt.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := keys(issues); !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	for _, deployment := range []string{"Server", "Cloud"} {
+		t.Run(deployment, func(t *testing.T) {
+			fake, server := newTestServer(t, deployment)
+			backend := newTestBackend(t, server)
+			if string(backend.Deployment()) != deployment {
+				t.Fatalf("expected the deployment to be detected as %s, got %s", deployment, backend.Deployment())
+			}
+
+			it := helpers.NewSearchIterator(backend, "project=OCPBUGS ORDER BY created ASC", &jiraBaseClient.SearchOptions{MaxResults: 3}, nil)
+			var actual []string
+			for it.Next(context.Background()) {
+				for _, issue := range it.Page().Issues {
+					actual = append(actual, issue.Key)
+				}
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if expected := []string{"OCPBUGS-35865", "OCPBUGS-35866", "OCPBUGS-35867", "OCPBUGS-35868"}; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected %v, got %v", expected, actual)
+			}
+
+			searches := 0
+			for _, request := range fake.Requests() {
+				if strings.Contains(request, "/search") {
+					searches++
+				}
+			}
+			if searches != 2 {
+				t.Errorf("expected two pages, got %d searches in %v", searches, fake.Requests())
+			}
+		})
+	}
+}
+
+func TestSearchWithoutFields(t *testing.T) {
+	for _, tt := range []struct {
+		deployment string
+		path       string
+		expected   func(issue map[string]interface{}) bool
+	}{
+		{
+			// the navigable fields, without the comments
+			deployment: "Server",
+			path:       "/rest/api/2/search",
+			expected: func(issue map[string]interface{}) bool {
+				fields, _ := issue["fields"].(map[string]interface{})
+				_, comment := fields["comment"]
+				return issue["key"] == "OCPBUGS-35865" && fields["summary"] != nil && !comment
+			},
+		},
+		{
+			// only the ID of the issue
+			deployment: "Cloud",
+			path:       "/rest/api/3/search/jql",
+			expected: func(issue map[string]interface{}) bool {
+				return len(issue) == 1 && issue["id"] == "15000001"
+			},
+		},
+	} {
+		t.Run(tt.deployment, func(t *testing.T) {
+			_, server := newTestServer(t, tt.deployment)
+			response, err := http.Get(server.URL + tt.path + "?jql=" + url.QueryEscape("key = OCPBUGS-35865"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			var result struct {
+				Issues []map[string]interface{} `json:"issues"`
+			}
+			if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Issues) != 1 || !tt.expected(result.Issues[0]) {
+				t.Errorf("unexpected issues %v", result.Issues)
+			}
+		})
+	}
+}
+
+func TestTruncatedCommentsAndChangelogs(t *testing.T) {
+	for _, tt := range []struct {
+		deployment string
+		// comments and changelog are the paths that the truncated lists are paged through
+		comments  string
+		changelog string
+	}{
+		{deployment: "Server", comments: "/rest/api/2/issue/15000001/comment", changelog: "/rest/api/2/issue/15000001"},
+		{deployment: "Cloud", comments: "/rest/api/3/issue/15000001/comment", changelog: "/rest/api/3/issue/15000001/changelog"},
+	} {
+		t.Run(tt.deployment, func(t *testing.T) {
+			fake, server := newTestServer(t, tt.deployment)
+			fake.EmbeddedLimit = 2
+			backend := newTestBackend(t, server)
+			ctx := context.Background()
+
+			comments, err := helpers.IssueComments(ctx, backend, "15000001", "15000002", "15000005")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(comments["15000001"]) != 3 || len(comments["15000002"]) != 1 || len(comments["15000005"]) != 0 {
+				t.Errorf("expected every comment, got %d, %d and %d", len(comments["15000001"]), len(comments["15000002"]), len(comments["15000005"]))
+			}
+			if comments["15000001"][1].Visibility.Value != "Red Hat Employee" {
+				t.Errorf("expected the visibility of the comment to be served, got %#v", comments["15000001"][1])
+			}
+
+			// the comments of a page of issues searched with their comment field are completed without searching again
+			it := helpers.NewSearchIterator(backend, "key in (OCPBUGS-35865, OCPBUGS-35866)", &jiraBaseClient.SearchOptions{Fields: []string{"*all"}}, func(int, int) {})
+			if !it.Next(ctx) {
+				t.Fatalf("expected a page of issues: %v", it.Err())
+			}
+			requests := len(fake.Requests())
+			pageComments, err := helpers.PageComments(ctx, backend, it.Page())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pageComments["15000001"]) != 3 || len(pageComments["15000002"]) != 1 {
+				t.Errorf("expected every comment of the page, got %d and %d", len(pageComments["15000001"]), len(pageComments["15000002"]))
+			}
+			// the comment endpoint returns pages of the embedded limit as well
+			if actual, expected := fake.Requests()[requests:], []string{"GET " + tt.comments, "GET " + tt.comments}; !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected only the truncated comments to be requested %v, got %v", expected, actual)
+			}
+
+			changelogs, err := helpers.IssueChangelogs(ctx, backend, "15000001", "15000003")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changelogs["15000001"]) != 3 || len(changelogs["15000003"]) != 1 {
+				t.Errorf("expected every changelog history, got %d and %d", len(changelogs["15000001"]), len(changelogs["15000003"]))
+			}
+
+			links, err := helpers.IssueRemoteLinks(ctx, backend, "15000001")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(links["15000001"]) != 1 || links["15000001"][0].Object.URL != "https://github.com/openshift/cluster-kube-apiserver-operator/pull/1700" {
+				t.Errorf("expected the remote link of the issue, got %#v", links)
+			}
+			catalog, err := helpers.GetFieldCatalog(ctx, backend.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(catalog.Fields()) != 5 {
+				t.Errorf("expected the fields of the dataset, got %v", catalog)
+			}
+
+			for _, path := range []string{tt.comments, tt.changelog} {
+				found := false
+				for _, request := range fake.Requests() {
+					found = found || request == "GET "+path
+				}
+				if !found {
+					t.Errorf("expected the truncated list to be paged through %s, got %v", path, fake.Requests())
+				}
+			}
+		})
+	}
+}
+
+func TestChangelogEndpoint(t *testing.T) {
+	for _, tt := range []struct {
+		deployment string
+		path       string
+		expected   int
+	}{
+		{deployment: "Server", path: "/rest/api/2/issue/15000001/changelog", expected: http.StatusNotFound},
+		{deployment: "Cloud", path: "/rest/api/3/issue/15000001/changelog", expected: http.StatusOK},
+	} {
+		t.Run(tt.deployment, func(t *testing.T) {
+			_, server := newTestServer(t, tt.deployment)
+			response, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, response.StatusCode)
+			}
+		})
+	}
+}
+
+func TestWrites(t *testing.T) {
+	fake, server := newTestServer(t, "")
+	options := &helpers.JiraOptions{Endpoint: server.URL}
+	client, err := options.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := client.CreateIssue(&jiraBaseClient.Issue{Fields: &jiraBaseClient.IssueFields{
+		Project: jiraBaseClient.Project{Key: "OCPBUGS"},
+		Type:    jiraBaseClient.IssueType{Name: "Bug"},
+		Summary: "A clone of OCPBUGS-35865",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != "15000006" || created.Key != "OCPBUGS-35869" {
+		t.Errorf("expected the issue to be numbered after the last issue of the project, got %s %s", created.ID, created.Key)
+	}
+	if _, err := client.CreateIssue(&jiraBaseClient.Issue{Fields: &jiraBaseClient.IssueFields{Project: jiraBaseClient.Project{Key: "NOPE"}, Summary: "x"}}); err == nil {
+		t.Errorf("expected an issue of an unknown project to be rejected")
+	}
+
+	if _, err := client.AddComment(created.ID, &jiraBaseClient.Comment{Body: "Cloned for 4.16."}); err != nil {
+		t.Fatal(err)
+	}
+	transitions, err := client.GetTransitions(created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transitions) != 3 {
+		t.Fatalf("expected the transitions of the dataset, got %v", transitions)
+	}
+	if err := client.DoTransition(created.Key, transitions[2].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DoTransition(created.Key, "99"); err == nil {
+		t.Errorf("expected an unknown transition to be rejected")
+	}
+	if _, err := client.AddRemoteLink(created.ID, &jiraBaseClient.RemoteLink{Object: &jiraBaseClient.RemoteLinkObject{URL: "https://github.com/openshift/origin/pull/1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	issue, err := client.GetIssue(created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Fields.Status.Name != "Closed" || issue.Fields.Summary != "A clone of OCPBUGS-35865" || issue.Fields.Comments == nil || len(issue.Fields.Comments.Comments) != 1 {
+		t.Errorf("expected the created issue to be closed and commented, got %#v", issue.Fields)
+	}
+	if !time.Time(issue.Fields.Created).Equal(now) || !time.Time(issue.Fields.Updated).Equal(now) {
+		t.Errorf("expected the issue to be created and updated by the clock of the server, got %v and %v", issue.Fields.Created, issue.Fields.Updated)
+	}
+	links, err := client.GetRemoteLinks(created.Key)
+	if err != nil || len(links) != 1 {
+		t.Errorf("expected the added remote link, got %v: %v", links, err)
+	}
+	if _, err := client.GetIssue("OCPBUGS-1"); err == nil {
+		t.Errorf("expected a missing issue to be an error")
+	}
+
+	if err := fake.UpdateIssue("OCPBUGS-35869", map[string]interface{}{"priority": map[string]string{"name": "Blocker"}}); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := fake.Issue("15000006")
+	if stored.Changelog == nil || len(stored.Changelog.Histories) != 2 {
+		t.Fatalf("expected the transition and the update in the changelog, got %#v", stored.Changelog)
+	}
+	status, priority := stored.Changelog.Histories[0].Items[0], stored.Changelog.Histories[1].Items[0]
+	if status.Field != "status" || status.FromString != "New" || status.ToString != "Closed" {
+		t.Errorf("expected the change of status to be recorded, got %#v", status)
+	}
+	if priority.Field != "priority" || priority.ToString != "Blocker" {
+		t.Errorf("expected the change of priority to be recorded, got %#v", priority)
+	}
+}
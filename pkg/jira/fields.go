@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	jiraClient "sigs.k8s.io/prow/prow/jira"
+	"sort"
+)
+
+// FieldCatalog maps the IDs of the fields of a Jira instance, such as customfield_12319940, to their definition.
+// A nil catalog knows no fields.
+type FieldCatalog struct {
+	fields map[string]jiraBaseClient.Field
+}
+
+// GetFieldCatalog fetches every field known to the Jira instance. The catalog rarely changes, so callers are expected
+// to fetch it once per run and reuse it.
+func GetFieldCatalog(ctx context.Context, client jiraClient.Client) (*FieldCatalog, error) {
+	var fields []jiraBaseClient.Field
+	if err := getJSON(ctx, client, "rest/api/2/field", &fields); err != nil {
+		return nil, fmt.Errorf("unable to get field catalog: %w", err)
+	}
+	return NewFieldCatalog(fields), nil
+}
+
+func NewFieldCatalog(fields []jiraBaseClient.Field) *FieldCatalog {
+	catalog := &FieldCatalog{fields: make(map[string]jiraBaseClient.Field, len(fields))}
+	for _, field := range fields {
+		catalog.fields[field.ID] = field
+	}
+	return catalog
+}
+
+// Field returns the definition of the field with the given ID.
+func (c *FieldCatalog) Field(id string) (jiraBaseClient.Field, bool) {
+	if c == nil {
+		return jiraBaseClient.Field{}, false
+	}
+	field, ok := c.fields[id]
+	return field, ok
+}
+
+// FieldByCustomType returns the first field, ordered by ID, with the given custom type, such as
+// com.pyxis.greenhopper.jira:gh-epic-link.
+func (c *FieldCatalog) FieldByCustomType(custom string) (jiraBaseClient.Field, bool) {
+	for _, field := range c.Fields() {
+		if field.Schema.Custom == custom {
+			return field, true
+		}
+	}
+	return jiraBaseClient.Field{}, false
+}
+
+// Fields returns every field in the catalog ordered by ID.
+func (c *FieldCatalog) Fields() []jiraBaseClient.Field {
+	if c == nil {
+		return nil
+	}
+	fields := make([]jiraBaseClient.Field, 0, len(c.fields))
+	for _, field := range c.fields {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].ID < fields[j].ID
+	})
+	return fields
+}
@@ -6,22 +6,11 @@ import (
 	"strings"
 )
 
+// FilterIssueComments replaces every restricted comment with a placeholder, as DefaultRedactionPolicy does.
 func FilterIssueComments(issueComments *[]jiraBaseClient.Issue) {
+	policy := DefaultRedactionPolicy()
 	for _, issue := range *issueComments {
-		var filteredCommentList []*jiraBaseClient.Comment
-		for _, comment := range issue.Fields.Comments.Comments {
-			if comment.Visibility.Value == "" {
-				filteredCommentList = append(filteredCommentList, comment)
-			} else {
-				filteredCommentList = append(filteredCommentList, &jiraBaseClient.Comment{Body: "<private comment>",
-					Author:  jiraBaseClient.User{DisplayName: "UNKNOWN"},
-					Created: comment.Created,
-					Updated: comment.Updated,
-					ID:      comment.ID,
-				})
-			}
-		}
-		issue.Fields.Comments.Comments = filteredCommentList
+		issue.Fields.Comments.Comments = policy.RedactComments(issue.Fields.Comments.Comments)
 	}
 }
 
@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// PanicSignatureFrames is the number of frames that identify a panic.
+const PanicSignatureFrames = 3
+
+// PanicSignature identifies a Go panic or stack trace independently of the addresses, goroutine IDs, line numbers and
+// values of the run that produced it, so that the same crash reported in different issues, or by different CI runs,
+// has the same signature.
+type PanicSignature struct {
+	// Message is the panic message with numbers, addresses and IDs replaced. It is empty for a stack trace that was
+	// pasted without its panic.
+	Message string
+	// Frames are the functions at the top of the stack of the panicking goroutine, excluding the Go runtime and the
+	// crash handlers of Kubernetes.
+	Frames []string
+}
+
+// Hash returns a short, stable hash of the signature.
+func (s *PanicSignature) Hash() string {
+	sum := sha256.Sum256([]byte(s.Message + "\n" + strings.Join(s.Frames, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+var (
+	panicMessagePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^panic: (.*?)(?: \[recovered\])?$`),
+		regexp.MustCompile(`^fatal error: (.*)$`),
+		// k8s.io/apimachinery/pkg/util/runtime.HandleCrash
+		regexp.MustCompile(`Observed a panic: (.*)$`),
+	}
+	panicGoroutinePattern = regexp.MustCompile(`^goroutine [0-9]+ \[[^\]]*\]:$`)
+	panicFilePattern      = regexp.MustCompile(`^\S+\.go:[0-9]+(?: \+0x[0-9a-f]+)?$`)
+
+	// panicIgnoredFrames are the frames that every panic passes through.
+	panicIgnoredFrames = []string{"runtime.", "runtime/debug.", "k8s.io/apimachinery/pkg/util/runtime."}
+
+	panicUUIDPattern    = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+	panicAddressPattern = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`)
+	panicNumberPattern  = regexp.MustCompile(`\b[0-9]+\b`)
+	panicClosurePattern = regexp.MustCompile(`\.func[0-9]+(?:\.[0-9]+)*`)
+	panicGenericPattern = regexp.MustCompile(`\[[^\]]*\]`)
+)
+
+// FindPanic returns the signature of the first Go panic or goroutine stack trace in the text, if any. Only the stack
+// of the first goroutine, which is the one that panicked, is used. A panic message without a stack is not enough to
+// identify a crash and is ignored.
+func FindPanic(text string) (*PanicSignature, bool) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	message := ""
+	messageLine := -1
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		for _, pattern := range panicMessagePatterns {
+			if m := pattern.FindStringSubmatch(line); m != nil {
+				message, messageLine = m[1], i
+				break
+			}
+		}
+		if !panicGoroutinePattern.MatchString(line) {
+			continue
+		}
+		// the message is only part of this stack if it was printed just before it
+		if messageLine < 0 || i-messageLine > 5 {
+			message = ""
+		}
+		frames := panicFrames(lines[i+1:])
+		if len(frames) == 0 {
+			message, messageLine = "", -1
+			continue
+		}
+		return &PanicSignature{Message: normalizePanicMessage(message), Frames: frames}, true
+	}
+	return nil, false
+}
+
+// panicFrames returns the top frames of a goroutine stack, which is a function call followed by its file and line for
+// every frame and ends at the first line that is neither.
+func panicFrames(lines []string) []string {
+	var frames []string
+	for i := 0; i+1 < len(lines) && len(frames) < PanicSignatureFrames; i += 2 {
+		function, ok := panicFunction(strings.TrimSpace(lines[i]))
+		if !ok || !panicFilePattern.MatchString(strings.TrimSpace(lines[i+1])) {
+			break
+		}
+		function = normalizePanicFunction(function)
+		if ignoredPanicFrame(function) {
+			continue
+		}
+		frames = append(frames, function)
+	}
+	return frames
+}
+
+// panicFunction returns the function of a call in a stack, which is followed by its arguments. The function itself
+// may contain parentheses, as in pkg.(*Type).Method(0xc000123456).
+func panicFunction(call string) (string, bool) {
+	if !strings.HasSuffix(call, ")") {
+		return "", false
+	}
+	depth := 0
+	for i := len(call) - 1; i > 0; i-- {
+		switch call[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return call[:i], !strings.ContainsAny(call[:i], " \t")
+			}
+		}
+	}
+	return "", false
+}
+
+func ignoredPanicFrame(function string) bool {
+	if function == "panic" {
+		return true
+	}
+	for _, prefix := range panicIgnoredFrames {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePanicFunction removes the parts of a function name that change between builds: the vendor directory, the
+// numbering of closures and the type arguments of generic functions.
+func normalizePanicFunction(function string) string {
+	if i := strings.LastIndex(function, "/vendor/"); i >= 0 {
+		function = function[i+len("/vendor/"):]
+	}
+	function = panicClosurePattern.ReplaceAllString(function, ".func")
+	return panicGenericPattern.ReplaceAllString(function, "")
+}
+
+// normalizePanicMessage replaces the UUIDs, addresses and numbers in a panic message, such as the index and length of
+// an index out of range.
+func normalizePanicMessage(message string) string {
+	message = panicUUIDPattern.ReplaceAllString(message, "<uuid>")
+	message = panicAddressPattern.ReplaceAllString(message, "0x?")
+	message = panicNumberPattern.ReplaceAllString(message, "N")
+	return strings.TrimSpace(message)
+}
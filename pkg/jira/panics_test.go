@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindPanic(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		text     string
+		expected *PanicSignature
+	}{
+		{
+			name: "nil pointer dereference",
+			text: `The operator crashed:
+{code}
+panic: runtime error: invalid memory address or nil pointer dereference [recovered]
+	panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x18 pc=0x1a2b3c]
+
+goroutine 412 [running]:
+runtime/debug.Stack()
+	/usr/lib/golang/src/runtime/debug/stack.go:24 +0x5e
+panic({0x1c2e0a0?, 0x2f6e1b0?})
+	/usr/lib/golang/src/runtime/panic.go:914 +0x21f
+github.com/openshift/cluster-network-operator/vendor/k8s.io/client-go/tools/cache.(*processorListener).run.func1.2()
+	/go/src/github.com/openshift/cluster-network-operator/vendor/k8s.io/client-go/tools/cache/shared_informer.go:975 +0x9c
+github.com/openshift/cluster-network-operator/pkg/controller.(*Reconciler[...]).Reconcile(0xc000a1b2c0, {0x2f6e1b0, 0xc001234560})
+	/go/src/github.com/openshift/cluster-network-operator/pkg/controller/reconciler.go:120 +0x1f4
+main.main()
+	/go/src/github.com/openshift/cluster-network-operator/cmd/main.go:42 +0x25
+main.init()
+	/go/src/github.com/openshift/cluster-network-operator/cmd/main.go:12 +0x25
+{code}`,
+			expected: &PanicSignature{
+				Message: "runtime error: invalid memory address or nil pointer dereference",
+				Frames: []string{
+					"k8s.io/client-go/tools/cache.(*processorListener).run.func",
+					"github.com/openshift/cluster-network-operator/pkg/controller.(*Reconciler).Reconcile",
+					"main.main",
+				},
+			},
+		},
+		{
+			name: "Kubernetes crash handler",
+			text: `E0301 12:00:00.000000       1 runtime.go:79] Observed a panic: runtime error: index out of range [5] with length 3
+goroutine 7 [running]:
+k8s.io/apimachinery/pkg/util/runtime.logPanic({0x1b2c3d0, 0xc0004e2f60})
+	/go/pkg/mod/k8s.io/apimachinery/pkg/util/runtime/runtime.go:75 +0x85
+github.com/example/pkg.process(...)
+	/go/src/github.com/example/pkg/process.go:88
+created by github.com/example/pkg.Start in goroutine 1
+	/go/src/github.com/example/pkg/start.go:12 +0x1f`,
+			expected: &PanicSignature{
+				Message: "runtime error: index out of range [N] with length N",
+				Frames:  []string{"github.com/example/pkg.process"},
+			},
+		},
+		{
+			name: "stack without a panic",
+			text: "goroutine 1 [chan receive, 12 minutes]:\nmain.wait(0xc00001e0c0)\n\t/src/main.go:10 +0x2a",
+			expected: &PanicSignature{
+				Frames: []string{"main.wait"},
+			},
+		},
+		{
+			name: "panic without a stack",
+			text: "panic: unable to start the server\nthe pod then restarted",
+		},
+		{
+			name: "prose",
+			text: "The goroutine leak causes a panic: see the attached logs.",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, ok := FindPanic(tt.text)
+			if ok != (tt.expected != nil) {
+				t.Fatalf("expected a panic to be found: %v, got %v", tt.expected != nil, actual)
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected\n%#v\ngot\n%#v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPanicSignatureHash(t *testing.T) {
+	first, _ := FindPanic("panic: missing key 9f1c2d3e-1111-2222-3333-444455556666 at 0xc000123456\n\ngoroutine 1 [running]:\nmain.run(0x1)\n\t/src/main.go:10 +0x2a")
+	second, _ := FindPanic("panic: missing key 0a1b2c3d-aaaa-bbbb-cccc-ddddeeeeffff at 0xc000654321\n\ngoroutine 23 [running]:\nmain.run(0x2)\n\t/build/main.go:14 +0x3b")
+	if first == nil || second == nil {
+		t.Fatalf("expected both panics to be found")
+	}
+	if first.Hash() != second.Hash() {
+		t.Errorf("expected the same crash to have the same signature, got %s and %s", first.Hash(), second.Hash())
+	}
+	if other := (&PanicSignature{Message: first.Message, Frames: []string{"main.other"}}); other.Hash() == first.Hash() {
+		t.Errorf("expected a different stack to have a different signature")
+	}
+}
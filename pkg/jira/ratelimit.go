@@ -0,0 +1,235 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/time/rate"
+	"io"
+	"k8s.io/klog/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is the number of times a throttled request is retried before its response is returned.
+	DefaultMaxRetries = 5
+
+	minBackoff = time.Second
+	maxBackoff = 2 * time.Minute
+)
+
+var errRequestNotReplayable = errors.New("the throttled request cannot be retried because its body cannot be read again")
+
+// RateLimitState is the current view of a RateLimiter of the budget of requests that Jira allows.
+type RateLimitState struct {
+	// BackoffUntil is the time before which no request is sent, because Jira throttled a request or reported that
+	// the budget is spent. It is zero, or in the past, when requests are not backing off.
+	BackoffUntil time.Time
+	// Throttled is the number of 429 and 503 responses received.
+	Throttled int
+	// Consecutive is the number of throttled responses since the last response that was not throttled, which grows
+	// the backoff when Jira does not send Retry-After.
+	Consecutive int
+	// Limit and Remaining are the size and content of the budget from the last X-RateLimit-Limit and
+	// X-RateLimit-Remaining headers, or -1 when Jira did not send them.
+	Limit     int
+	Remaining int
+	// Rate is the number of requests per second that the token bucket currently allows.
+	Rate rate.Limit
+}
+
+// BackingOff returns true if requests are delayed until BackoffUntil.
+func (s RateLimitState) BackingOff(now time.Time) bool {
+	return now.Before(s.BackoffUntil)
+}
+
+// RateLimiter is a token bucket shared by every request to Jira, which adapts to the rate that Jira advertises in the
+// X-RateLimit-FillRate and X-RateLimit-Interval-Seconds headers and backs off when Jira throttles a request.
+type RateLimiter struct {
+	limiter *rate.Limiter
+	// configured is the rate that was requested, which the advertised rate may only lower.
+	configured rate.Limit
+
+	lock  sync.Mutex
+	state RateLimitState
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRateLimiter creates a limiter of qps requests per second with bursts of burst requests. A qps of zero or less
+// does not limit the rate, but still honors the throttling of Jira.
+func NewRateLimiter(qps float64, burst int) *RateLimiter {
+	limit := rate.Limit(qps)
+	if qps <= 0 {
+		limit = rate.Inf
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		limiter:    rate.NewLimiter(limit, burst),
+		configured: limit,
+		state:      RateLimitState{Limit: -1, Remaining: -1, Rate: limit},
+		now:        time.Now,
+		sleep:      sleepContext,
+	}
+}
+
+// State returns the current backoff state and budget.
+func (l *RateLimiter) State() RateLimitState {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.state
+}
+
+// Wait blocks until any backoff is over and a token is available.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.lock.Lock()
+	until := l.state.BackoffUntil
+	l.lock.Unlock()
+	if wait := until.Sub(l.now()); wait > 0 {
+		if err := l.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// Observe updates the budget from the rate limit headers of a response and backs off if the response was throttled or
+// the budget is spent. It returns true if the request should be retried.
+func (l *RateLimiter) Observe(response *http.Response) bool {
+	now := l.now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	header := response.Header
+	l.state.Limit = headerInt(header, "X-RateLimit-Limit", l.state.Limit)
+	l.state.Remaining = headerInt(header, "X-RateLimit-Remaining", -1)
+	fillRate, interval := headerInt(header, "X-RateLimit-FillRate", 0), headerInt(header, "X-RateLimit-Interval-Seconds", 0)
+	if fillRate > 0 && interval > 0 {
+		advertised := rate.Limit(float64(fillRate) / float64(interval))
+		if advertised < l.configured && advertised != l.state.Rate {
+			klog.V(2).Infof("Jira allows %d requests every %ds, lowering the request rate to %.2f/s", fillRate, interval, float64(advertised))
+		}
+		if advertised > l.configured {
+			advertised = l.configured
+		}
+		l.limiter.SetLimit(advertised)
+		l.state.Rate = advertised
+	}
+
+	throttled := response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable
+	if !throttled {
+		l.state.Consecutive = 0
+		if l.state.Remaining == 0 {
+			// the budget is spent, wait for it to be refilled rather than for Jira to reject the next request
+			l.backoff(now, l.retryAfter(header, now))
+		}
+		return false
+	}
+	l.state.Throttled++
+	l.state.Consecutive++
+	l.backoff(now, l.retryAfter(header, now))
+	return true
+}
+
+// retryAfter returns the delay that Jira asks for in the Retry-After or X-RateLimit-Reset headers, or an exponential
+// backoff when it sends neither.
+func (l *RateLimiter) retryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); len(value) > 0 {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return at.Sub(now)
+		}
+	}
+	if value := header.Get("X-RateLimit-Reset"); len(value) > 0 {
+		if at, err := time.Parse(time.RFC3339, value); err == nil {
+			return at.Sub(now)
+		}
+	}
+	backoff := minBackoff
+	for i := 1; i < l.state.Consecutive && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+func (l *RateLimiter) backoff(now time.Time, delay time.Duration) {
+	if delay < 0 {
+		delay = 0
+	}
+	if until := now.Add(delay); until.After(l.state.BackoffUntil) {
+		l.state.BackoffUntil = until
+	}
+}
+
+func headerInt(header http.Header, name string, fallback int) int {
+	value, err := strconv.Atoi(header.Get(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimitTransport sends every request through a shared RateLimiter and retries the requests that Jira throttles,
+// after the delay that Jira asks for. The response of the last attempt is returned once MaxRetries is reached.
+type RateLimitTransport struct {
+	Limiter    *RateLimiter
+	MaxRetries int
+	// Upstream sends the requests, http.DefaultTransport when it is nil.
+	Upstream http.RoundTripper
+}
+
+func (t *RateLimitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	upstream := t.Upstream
+	if upstream == nil {
+		upstream = http.DefaultTransport
+	}
+	for attempt := 0; ; attempt++ {
+		if err := t.Limiter.Wait(request.Context()); err != nil {
+			return nil, err
+		}
+		attemptRequest := request
+		if attempt > 0 && request.Body != nil {
+			if request.GetBody == nil {
+				return nil, errRequestNotReplayable
+			}
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptRequest = request.Clone(request.Context())
+			attemptRequest.Body = body
+		}
+		response, err := upstream.RoundTrip(attemptRequest)
+		if err != nil {
+			return nil, err
+		}
+		if !t.Limiter.Observe(response) || attempt >= t.MaxRetries {
+			return response, nil
+		}
+		state := t.Limiter.State()
+		klog.V(2).Infof("Jira throttled %s %s with %s, retrying in %s (attempt %d/%d)", request.Method, request.URL.Path, response.Status, state.BackoffUntil.Sub(t.Limiter.now()).Round(time.Millisecond), attempt+1, t.MaxRetries)
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+	}
+}
@@ -0,0 +1,167 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newThrottlingServer serves /serverInfo as Jira Server does, after throttling the first requests with the responses
+// in order.
+func newThrottlingServer(throttle ...func(w http.ResponseWriter)) (*httptest.Server, *int) {
+	var lock sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		request := requests
+		requests++
+		lock.Unlock()
+		if request < len(throttle) {
+			throttle[request](w)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "500")
+		w.Header().Set("X-RateLimit-Remaining", "499")
+		w.Header().Set("X-RateLimit-FillRate", "10")
+		w.Header().Set("X-RateLimit-Interval-Seconds", "5")
+		json.NewEncoder(w).Encode(ServerInfo{DeploymentType: DeploymentServer, Version: "9.12.0"})
+	}))
+	return server, &requests
+}
+
+// newTestLimiterClient creates a client of the server whose limiter records the delays it waits for instead of
+// sleeping.
+func newTestLimiterClient(server *httptest.Server, maxRetries int) (*JiraOptions, *[]time.Duration) {
+	options := &JiraOptions{Endpoint: server.URL, QPS: 100, Burst: 100, MaxRetries: maxRetries}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var delays []time.Duration
+	limiter := options.RateLimiter()
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		now = now.Add(d)
+		return nil
+	}
+	return options, &delays
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	server, requests := newThrottlingServer(
+		func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "7")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		},
+		func(w http.ResponseWriter) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		},
+		func(w http.ResponseWriter) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		},
+	)
+	defer server.Close()
+	options, delays := newTestLimiterClient(server, 5)
+	client, err := options.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := GetServerInfo(context.Background(), client)
+	if err != nil {
+		t.Fatalf("expected the throttled request to be retried, got %v", err)
+	}
+	if info.DeploymentType != DeploymentServer {
+		t.Errorf("expected the server info, got %#v", info)
+	}
+	if *requests != 4 {
+		t.Errorf("expected 4 requests, got %d", *requests)
+	}
+	// Retry-After, then an exponential backoff when Jira does not say how long to wait
+	expected := []time.Duration{7 * time.Second, 2 * time.Second, 4 * time.Second}
+	if len(*delays) != len(expected) {
+		t.Fatalf("expected delays %v, got %v", expected, *delays)
+	}
+	for i := range expected {
+		if (*delays)[i] != expected[i] {
+			t.Errorf("expected delays %v, got %v", expected, *delays)
+			break
+		}
+	}
+
+	state := options.RateLimiter().State()
+	if state.Throttled != 3 || state.Consecutive != 0 {
+		t.Errorf("expected 3 throttled responses and none since the last success, got %#v", state)
+	}
+	if state.Limit != 500 || state.Remaining != 499 || state.Rate != rate.Limit(2) {
+		t.Errorf("expected the budget and rate advertised by Jira, got %#v", state)
+	}
+}
+
+func TestRateLimitTransportMaxRetries(t *testing.T) {
+	tooMany := func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}
+	server, requests := newThrottlingServer(tooMany, tooMany, tooMany)
+	defer server.Close()
+	options, _ := newTestLimiterClient(server, 1)
+	client, err := options.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetServerInfo(context.Background(), client); err == nil {
+		t.Errorf("expected the last throttled response to be returned as an error")
+	}
+	if *requests != 2 {
+		t.Errorf("expected a single retry, got %d requests", *requests)
+	}
+}
+
+func TestRateLimiterSharedBackoff(t *testing.T) {
+	limiter := NewRateLimiter(0, 1)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	var lock sync.Mutex
+	var delays []time.Duration
+	limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		lock.Lock()
+		defer lock.Unlock()
+		delays = append(delays, d)
+		return nil
+	}
+
+	// the budget is spent without a request being throttled
+	response := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	response.Header.Set("X-RateLimit-Remaining", "0")
+	response.Header.Set("X-RateLimit-Reset", now.Add(30*time.Second).Format(time.RFC3339))
+	if limiter.Observe(response) {
+		t.Errorf("expected a successful response not to be retried")
+	}
+	if state := limiter.State(); !state.BackingOff(now) || !state.BackoffUntil.Equal(now.Add(30*time.Second)) {
+		t.Errorf("expected a backoff until the budget is reset, got %#v", state)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.Wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(delays) != 3 {
+		t.Fatalf("expected every worker to back off, got %v", delays)
+	}
+	for _, delay := range delays {
+		if delay != 30*time.Second {
+			t.Errorf("expected every worker to wait for the reset, got %v", delays)
+		}
+	}
+}
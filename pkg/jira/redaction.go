@@ -0,0 +1,215 @@
+package helpers
+
+import (
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"github.com/openshift/ci-search/jira"
+)
+
+// RedactionAction decides what happens to text that is not visible to everyone.
+type RedactionAction string
+
+const (
+	// RedactionKeep exports the text unchanged.
+	RedactionKeep RedactionAction = "keep"
+	// RedactionPlaceholder replaces the text and author with placeholders, as FilterIssueComments does, so that the
+	// existence and timing of a comment is still exported. For an issue with a security level the summary,
+	// description, custom fields and the text of its changelog are replaced as well.
+	RedactionPlaceholder RedactionAction = "placeholder"
+	// RedactionDrop removes the comment, or the whole issue, from the export.
+	RedactionDrop RedactionAction = "drop"
+)
+
+const (
+	PrivateCommentPlaceholder = "<private comment>"
+	PrivateAuthorPlaceholder  = "UNKNOWN"
+	PrivateIssuePlaceholder   = "<private issue>"
+)
+
+// VisibilityRule applies an action to the comments restricted to a group or project role. An empty Type or Value
+// matches any.
+type VisibilityRule struct {
+	Type   string          `json:"type,omitempty"`
+	Value  string          `json:"value,omitempty"`
+	Action RedactionAction `json:"action"`
+}
+
+// RedactionPolicy decides which comments and issues are exported. Public comments are always kept. Restricted
+// comments use the action of the first matching rule, or Default when none match. Issues with a security level use
+// SecurityLevel for the issue as a whole:
+//
+//	redaction:
+//	  default: placeholder
+//	  securityLevel: drop
+//	  rules:
+//	  - type: group
+//	    value: jira-users
+//	    action: keep
+type RedactionPolicy struct {
+	Default       RedactionAction  `json:"default,omitempty"`
+	SecurityLevel RedactionAction  `json:"securityLevel,omitempty"`
+	Rules         []VisibilityRule `json:"rules,omitempty"`
+}
+
+// DefaultRedactionPolicy replaces every restricted comment with a placeholder and drops issues with a security level,
+// matching the issues that jira.FilterPrivateIssues excludes from the informers of ci-search.
+func DefaultRedactionPolicy() *RedactionPolicy {
+	return &RedactionPolicy{
+		Default:       RedactionPlaceholder,
+		SecurityLevel: RedactionDrop,
+	}
+}
+
+// Complete fills in the defaults for any action that is not set.
+func (p *RedactionPolicy) Complete() {
+	defaults := DefaultRedactionPolicy()
+	if len(p.Default) == 0 {
+		p.Default = defaults.Default
+	}
+	if len(p.SecurityLevel) == 0 {
+		p.SecurityLevel = defaults.SecurityLevel
+	}
+}
+
+func (p *RedactionPolicy) Validate() error {
+	if err := validateRedactionAction(p.Default); err != nil {
+		return fmt.Errorf("default: %v", err)
+	}
+	if err := validateRedactionAction(p.SecurityLevel); err != nil {
+		return fmt.Errorf("securityLevel: %v", err)
+	}
+	for i, rule := range p.Rules {
+		if err := validateRedactionAction(rule.Action); err != nil {
+			return fmt.Errorf("rules[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func validateRedactionAction(action RedactionAction) error {
+	switch action {
+	case RedactionKeep, RedactionPlaceholder, RedactionDrop:
+		return nil
+	default:
+		return fmt.Errorf("action %q must be one of %s, %s or %s", action, RedactionKeep, RedactionPlaceholder, RedactionDrop)
+	}
+}
+
+// CommentAction returns the action for a comment based on its visibility.
+func (p *RedactionPolicy) CommentAction(comment *jiraBaseClient.Comment) RedactionAction {
+	if len(comment.Visibility.Type) == 0 && len(comment.Visibility.Value) == 0 {
+		return RedactionKeep
+	}
+	for _, rule := range p.Rules {
+		if (len(rule.Type) == 0 || rule.Type == comment.Visibility.Type) && (len(rule.Value) == 0 || rule.Value == comment.Visibility.Value) {
+			return rule.Action
+		}
+	}
+	return p.Default
+}
+
+// IssueAction returns the action for the issue as a whole, which is RedactionKeep unless the issue has a security
+// level.
+func (p *RedactionPolicy) IssueAction(issue *jiraBaseClient.Issue) RedactionAction {
+	if issue.Fields == nil || jira.FilterPrivateIssues(issue) {
+		return RedactionKeep
+	}
+	return p.SecurityLevel
+}
+
+// RedactComments applies the policy to every comment. The comments are not modified.
+func (p *RedactionPolicy) RedactComments(comments []*jiraBaseClient.Comment) []*jiraBaseClient.Comment {
+	redacted := make([]*jiraBaseClient.Comment, 0, len(comments))
+	for _, comment := range comments {
+		switch p.CommentAction(comment) {
+		case RedactionKeep:
+			redacted = append(redacted, comment)
+		case RedactionPlaceholder:
+			redacted = append(redacted, placeholderComment(comment))
+		}
+	}
+	return redacted
+}
+
+// RedactIssue applies the policy to an issue and its comments, including the comments embedded in its fields. It
+// returns false if the issue must not be exported at all. The issue is copied before it is redacted, so the input is
+// never modified.
+func (p *RedactionPolicy) RedactIssue(issue *jira.IssueComments) (*jira.IssueComments, bool) {
+	action := p.IssueAction(&issue.Info)
+	if action == RedactionDrop {
+		return nil, false
+	}
+	redacted := *issue
+	if issue.Info.Fields != nil {
+		fields := *issue.Info.Fields
+		redacted.Info.Fields = &fields
+	}
+	if action == RedactionPlaceholder {
+		redacted.Info.Fields.Summary = PrivateIssuePlaceholder
+		redacted.Info.Fields.Description = PrivateIssuePlaceholder
+		redacted.Info.Fields.Unknowns = nil
+		redacted.Comments = make([]*jiraBaseClient.Comment, 0, len(issue.Comments))
+		for _, comment := range issue.Comments {
+			redacted.Comments = append(redacted.Comments, placeholderComment(comment))
+		}
+	} else {
+		redacted.Comments = p.RedactComments(issue.Comments)
+	}
+	if redacted.Info.Fields != nil && redacted.Info.Fields.Comments != nil {
+		redacted.Info.Fields.Comments = &jiraBaseClient.Comments{Comments: redacted.Comments}
+	}
+	return &redacted, true
+}
+
+// redactedChangelogFields are the fields whose changelog holds free text. Custom fields are redacted as well.
+var redactedChangelogFields = map[string]bool{
+	"summary":     true,
+	"description": true,
+	"environment": true,
+	"Comment":     true,
+}
+
+// RedactChangelog applies the issue action to the changelog of the issue. The values of the fields that hold free
+// text are replaced for issues that are exported with placeholders. The histories are not modified.
+func (p *RedactionPolicy) RedactChangelog(issue *jiraBaseClient.Issue, histories []jiraBaseClient.ChangelogHistory) []jiraBaseClient.ChangelogHistory {
+	switch p.IssueAction(issue) {
+	case RedactionDrop:
+		return nil
+	case RedactionKeep:
+		return histories
+	}
+	redacted := make([]jiraBaseClient.ChangelogHistory, 0, len(histories))
+	for _, history := range histories {
+		items := make([]jiraBaseClient.ChangelogItems, 0, len(history.Items))
+		for _, item := range history.Items {
+			if redactedChangelogFields[item.Field] || item.FieldType == "custom" {
+				item.From, item.To = nil, nil
+				item.FromString, item.ToString = PrivateIssuePlaceholder, PrivateIssuePlaceholder
+			}
+			items = append(items, item)
+		}
+		history.Items = items
+		redacted = append(redacted, history)
+	}
+	return redacted
+}
+
+// RedactRemoteLinks applies the issue action to the remote links of the issue. The titles and URLs of remote links may
+// describe the issue, so they are only exported for issues that are kept.
+func (p *RedactionPolicy) RedactRemoteLinks(issue *jiraBaseClient.Issue, links []jiraBaseClient.RemoteLink) []jiraBaseClient.RemoteLink {
+	if p.IssueAction(issue) != RedactionKeep {
+		return nil
+	}
+	return links
+}
+
+func placeholderComment(comment *jiraBaseClient.Comment) *jiraBaseClient.Comment {
+	return &jiraBaseClient.Comment{
+		Body:       PrivateCommentPlaceholder,
+		Author:     jiraBaseClient.User{DisplayName: PrivateAuthorPlaceholder},
+		Created:    comment.Created,
+		Updated:    comment.Updated,
+		ID:         comment.ID,
+		Visibility: comment.Visibility,
+	}
+}
@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"github.com/openshift/ci-search/jira"
+	"testing"
+)
+
+func TestCommentAction(t *testing.T) {
+	policy := &RedactionPolicy{
+		Default: RedactionDrop,
+		Rules: []VisibilityRule{
+			{Type: "group", Value: "jira-users", Action: RedactionKeep},
+			{Type: "role", Action: RedactionPlaceholder},
+		},
+	}
+	tests := []struct {
+		name       string
+		visibility jiraBaseClient.CommentVisibility
+		expected   RedactionAction
+	}{
+		{name: "public", expected: RedactionKeep},
+		{name: "matching group", visibility: jiraBaseClient.CommentVisibility{Type: "group", Value: "jira-users"}, expected: RedactionKeep},
+		{name: "any role", visibility: jiraBaseClient.CommentVisibility{Type: "role", Value: "Developers"}, expected: RedactionPlaceholder},
+		{name: "unmatched group", visibility: jiraBaseClient.CommentVisibility{Type: "group", Value: "secret"}, expected: RedactionDrop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := policy.CommentAction(&jiraBaseClient.Comment{Visibility: tt.visibility}); actual != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRedactIssue(t *testing.T) {
+	private := &jiraBaseClient.Comment{ID: "2", Body: "private text", Author: jiraBaseClient.User{DisplayName: "Jane"}, Visibility: jiraBaseClient.CommentVisibility{Type: "group", Value: "secret"}}
+	public := &jiraBaseClient.Comment{ID: "1", Body: "public text"}
+	newIssue := func(security bool) *jira.IssueComments {
+		fields := &jiraBaseClient.IssueFields{
+			Summary:     "private summary",
+			Description: "private description",
+			Comments:    &jiraBaseClient.Comments{Comments: []*jiraBaseClient.Comment{public, private}},
+			Unknowns:    map[string]interface{}{},
+		}
+		if security {
+			fields.Unknowns["security"] = map[string]interface{}{"id": "1", "name": "Embargoed"}
+		}
+		return &jira.IssueComments{
+			Info:     jiraBaseClient.Issue{ID: "1", Key: "OCPBUGS-1", Fields: fields},
+			Comments: []*jiraBaseClient.Comment{public, private},
+		}
+	}
+
+	issue := newIssue(false)
+	redacted, ok := DefaultRedactionPolicy().RedactIssue(issue)
+	if !ok {
+		t.Fatalf("expected a public issue to be exported")
+	}
+	if redacted.Comments[0] != public || redacted.Comments[1].Body != PrivateCommentPlaceholder || redacted.Comments[1].Author.DisplayName != PrivateAuthorPlaceholder {
+		t.Errorf("expected the restricted comment to be replaced, got %#v", redacted.Comments[1])
+	}
+	if embedded := redacted.Info.Fields.Comments.Comments; embedded[1].Body != PrivateCommentPlaceholder {
+		t.Errorf("expected the embedded comments to be redacted, got %#v", embedded[1])
+	}
+	if issue.Comments[1].Body != "private text" || issue.Info.Fields.Comments.Comments[1].Body != "private text" {
+		t.Errorf("expected the input issue to be left unmodified")
+	}
+
+	if _, ok := DefaultRedactionPolicy().RedactIssue(newIssue(true)); ok {
+		t.Errorf("expected an issue with a security level to be dropped by default")
+	}
+
+	policy := &RedactionPolicy{Default: RedactionDrop, SecurityLevel: RedactionPlaceholder}
+	redacted, ok = policy.RedactIssue(newIssue(true))
+	if !ok {
+		t.Fatalf("expected an issue with a security level to be exported with placeholders")
+	}
+	if redacted.Info.Fields.Summary != PrivateIssuePlaceholder || redacted.Info.Fields.Description != PrivateIssuePlaceholder {
+		t.Errorf("expected the summary and description to be replaced, got %q and %q", redacted.Info.Fields.Summary, redacted.Info.Fields.Description)
+	}
+	for _, comment := range redacted.Comments {
+		if comment.Body != PrivateCommentPlaceholder {
+			t.Errorf("expected every comment of a private issue to be replaced, got %q", comment.Body)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"regexp"
+	"strconv"
+)
+
+// IssueRemoteLinks returns the remote links of every requested issue, keyed by issue ID. Jira has no way to request
+// the remote links of several issues at once, so every issue is requested individually.
+func IssueRemoteLinks(ctx context.Context, backend SearchBackend, ids ...string) (map[string][]jiraBaseClient.RemoteLink, error) {
+	links := make(map[string][]jiraBaseClient.RemoteLink, len(ids))
+	for _, id := range ids {
+		var issueLinks []jiraBaseClient.RemoteLink
+		if err := getJSON(ctx, backend.Client(), fmt.Sprintf("rest/api/%s/issue/%s/remotelink", backend.APIVersion(), id), &issueLinks); err != nil {
+			return nil, fmt.Errorf("unable to get remote links of issue %s: %w", id, err)
+		}
+		links[id] = issueLinks
+	}
+	return links, nil
+}
+
+// PullRequest identifies a GitHub pull request.
+type PullRequest struct {
+	Org    string
+	Repo   string
+	Number int
+}
+
+func (p PullRequest) URL() string {
+	return fmt.Sprintf("https://github.com/%s/%s/pull/%d", p.Org, p.Repo, p.Number)
+}
+
+func (p PullRequest) String() string {
+	return fmt.Sprintf("%s/%s#%d", p.Org, p.Repo, p.Number)
+}
+
+// pullRequestPattern matches the URL of a pull request, including links to its files, commits or a single comment.
+var pullRequestPattern = regexp.MustCompile(`https?://(?:www\.)?github\.com/([A-Za-z0-9][A-Za-z0-9-]*)/([A-Za-z0-9._-]+)/pull/([0-9]+)`)
+
+// ParsePullRequestURL parses the URL of a GitHub pull request.
+func ParsePullRequestURL(url string) (PullRequest, bool) {
+	m := pullRequestPattern.FindStringSubmatch(url)
+	if m == nil {
+		return PullRequest{}, false
+	}
+	return newPullRequest(m)
+}
+
+// FindPullRequests returns every GitHub pull request whose URL is mentioned in the text, in order of first mention.
+func FindPullRequests(text string) []PullRequest {
+	var pulls []PullRequest
+	seen := make(map[PullRequest]bool)
+	for _, m := range pullRequestPattern.FindAllStringSubmatch(text, -1) {
+		pull, ok := newPullRequest(m)
+		if !ok || seen[pull] {
+			continue
+		}
+		seen[pull] = true
+		pulls = append(pulls, pull)
+	}
+	return pulls
+}
+
+func newPullRequest(m []string) (PullRequest, bool) {
+	number, err := strconv.Atoi(m[3])
+	if err != nil {
+		return PullRequest{}, false
+	}
+	return PullRequest{Org: m[1], Repo: m[2], Number: number}, true
+}
@@ -0,0 +1,33 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindPullRequests(t *testing.T) {
+	text := `Fixed by https://github.com/openshift/installer/pull/7890 and [the backport|https://github.com/openshift/installer/pull/7901/files].
+Reverted in https://github.com/openshift/origin/pull/28500#issuecomment-1234, see https://github.com/openshift/installer/pull/7890 again.
+Not a pull request: https://github.com/openshift/installer/issues/12 or https://github.com/openshift/installer/pull/abc`
+	expected := []PullRequest{
+		{Org: "openshift", Repo: "installer", Number: 7890},
+		{Org: "openshift", Repo: "installer", Number: 7901},
+		{Org: "openshift", Repo: "origin", Number: 28500},
+	}
+	if actual := FindPullRequests(text); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, actual)
+	}
+}
+
+func TestParsePullRequestURL(t *testing.T) {
+	pull, ok := ParsePullRequestURL("https://github.com/openshift/cluster-network-operator/pull/2000/commits/abc")
+	if !ok || pull != (PullRequest{Org: "openshift", Repo: "cluster-network-operator", Number: 2000}) {
+		t.Fatalf("unexpected pull request %v", pull)
+	}
+	if pull.URL() != "https://github.com/openshift/cluster-network-operator/pull/2000" || pull.String() != "openshift/cluster-network-operator#2000" {
+		t.Errorf("unexpected formatting %s %s", pull.URL(), pull)
+	}
+	if _, ok := ParsePullRequestURL("https://issues.redhat.com/browse/OCPBUGS-1"); ok {
+		t.Errorf("expected a Jira URL not to be a pull request")
+	}
+}
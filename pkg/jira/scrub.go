@@ -0,0 +1,159 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ScrubPattern is a user-defined detector. Every match of Pattern is replaced with <redacted:Name>.
+type ScrubPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+type detector struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// builtinDetectors are applied in order, so that a PEM block or a pull secret is redacted as a whole before the
+// detectors for the smaller secrets inside it run.
+var builtinDetectors = []detector{
+	{name: "pem", pattern: regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]+-----.*?-----END [A-Z0-9 ]+-----`)},
+	{name: "pull-secret", pattern: regexp.MustCompile(`"auth"\s*:\s*"[A-Za-z0-9+/=]{8,}"`)},
+	{name: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)},
+	{name: "aws-access-key", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "aws-secret-key", pattern: regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?[A-Za-z0-9/+=]{40}["']?`)},
+	{name: "kubeconfig-credential", pattern: regexp.MustCompile(`(?m)\b(?:client-key-data|client-certificate-data|token|password)\s*:\s*["']?[A-Za-z0-9+/=._~-]{16,}["']?`)},
+	{name: "email", pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+}
+
+// ScrubReport counts the secrets redacted per detector.
+type ScrubReport map[string]int
+
+func (r ScrubReport) Add(other ScrubReport) {
+	for name, count := range other {
+		r[name] += count
+	}
+}
+
+func (r ScrubReport) String() string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, r[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Scrubber redacts secrets and personal information from free text, such as kubeconfigs, pull secrets, tokens and
+// email addresses pasted into descriptions and comments. It keeps a running report of everything it redacted.
+type Scrubber struct {
+	detectors []detector
+
+	lock   sync.Mutex
+	report ScrubReport
+}
+
+// NewScrubber returns a scrubber with the built-in detectors followed by the given patterns.
+func NewScrubber(patterns []ScrubPattern) (*Scrubber, error) {
+	s := &Scrubber{
+		detectors: append([]detector(nil), builtinDetectors...),
+		report:    ScrubReport{},
+	}
+	for _, p := range patterns {
+		if len(p.Name) == 0 {
+			return nil, fmt.Errorf("scrub pattern %q must have a name", p.Pattern)
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrub pattern %s: %v", p.Name, err)
+		}
+		s.detectors = append(s.detectors, detector{name: p.Name, pattern: re})
+	}
+	return s, nil
+}
+
+// Scrub returns the text with every detected secret replaced with <redacted:detector> and what was redacted.
+func (s *Scrubber) Scrub(text string) (string, ScrubReport) {
+	report := ScrubReport{}
+	if len(text) == 0 {
+		return text, report
+	}
+	for _, d := range s.detectors {
+		text = d.pattern.ReplaceAllStringFunc(text, func(string) string {
+			report[d.name]++
+			return "<redacted:" + d.name + ">"
+		})
+	}
+	if len(report) > 0 {
+		s.lock.Lock()
+		s.report.Add(report)
+		s.lock.Unlock()
+	}
+	return text, report
+}
+
+// ScrubString is Scrub without the report of the single call.
+func (s *Scrubber) ScrubString(text string) string {
+	text, _ = s.Scrub(text)
+	return text
+}
+
+// MarshalIndent marshals v as indented JSON with every string value scrubbed, for logging full issues. The values are
+// scrubbed before they are encoded, as the detectors do not match the escaped quotes of text embedded in JSON strings.
+func (s *Scrubber) MarshalIndent(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(s.scrubValue(decoded)); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// scrubValue scrubs every string in a decoded JSON value.
+func (s *Scrubber) scrubValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return s.ScrubString(value)
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = s.scrubValue(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = s.scrubValue(item)
+		}
+	}
+	return v
+}
+
+// Report returns everything redacted by the scrubber so far.
+func (s *Scrubber) Report() ScrubReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	report := ScrubReport{}
+	report.Add(s.report)
+	return report
+}
@@ -0,0 +1,160 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"k8s.io/klog/v2"
+)
+
+const DefaultSearchPageSize = 500
+
+// SearchProgressFunc is invoked after every page with the number of issues fetched so far and the total number of
+// issues that Jira reported for the query, which is -1 on Jira Cloud.
+type SearchProgressFunc func(fetched, total int)
+
+// SearchPage is a single page of search results.
+type SearchPage struct {
+	Issues  []jiraBaseClient.Issue
+	StartAt int
+	// Total is the number of issues matching the query, or -1 if the backend does not report it.
+	Total int
+	// CommentTotals is the number of comments of every issue searched with its comment field, keyed by issue ID.
+	// Jira embeds at most a hundred of them, see PageComments.
+	CommentTotals map[string]int
+}
+
+// SearchIterator pages through every issue matching a JQL query with the pagination of its SearchBackend.
+//
+//	it := NewSearchIterator(backend, jql, nil, nil)
+//	for it.Next(ctx) {
+//		page := it.Page()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type SearchIterator struct {
+	backend  SearchBackend
+	request  SearchRequest
+	progress SearchProgressFunc
+
+	// skip is the number of issues still to be skipped to honor the StartAt of the options on backends that
+	// cannot seek.
+	skip    int
+	page    SearchPage
+	startAt int
+	fetched int
+	done    bool
+	err     error
+}
+
+// NewSearchIterator creates an iterator for the given query. If options is nil or does not specify MaxResults, pages
+// of DefaultSearchPageSize are requested. If progress is nil, progress is logged.
+func NewSearchIterator(backend SearchBackend, jql string, options *jiraBaseClient.SearchOptions, progress SearchProgressFunc) *SearchIterator {
+	it := &SearchIterator{
+		backend:  backend,
+		request:  SearchRequest{JQL: jql},
+		progress: progress,
+	}
+	if options != nil {
+		it.request.Fields = options.Fields
+		it.request.Expand = options.Expand
+		it.request.MaxResults = options.MaxResults
+		it.request.Position, it.skip = backend.Offset(options.StartAt)
+		it.startAt = options.StartAt
+	}
+	if it.request.MaxResults <= 0 {
+		it.request.MaxResults = DefaultSearchPageSize
+	}
+	if it.progress == nil {
+		it.progress = func(fetched, total int) {
+			klog.V(4).Infof("Fetched %d/%d issues for query: %s", fetched, total, jql)
+		}
+	}
+	return it
+}
+
+// Next fetches the next page of issues. It returns false once every page has been read or an error occurred.
+func (it *SearchIterator) Next(ctx context.Context) bool {
+	for {
+		if it.done || it.err != nil {
+			return false
+		}
+		if err := ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+
+		response, err := it.backend.Search(ctx, it.request)
+		if err != nil {
+			it.err = fmt.Errorf("unable to search issues at offset %d: %w", it.startAt, err)
+			return false
+		}
+		issues := make([]jiraBaseClient.Issue, 0, len(response.Issues))
+		commentTotals := make(map[string]int)
+		for _, raw := range response.Issues {
+			var issue jiraBaseClient.Issue
+			if err := json.Unmarshal(raw, &issue); err != nil {
+				it.err = fmt.Errorf("unable to decode issue at offset %d: %w", it.startAt+len(issues), err)
+				return false
+			}
+			// the total of the comments is dropped by the types of go-jira
+			var comments commentIssue
+			if err := json.Unmarshal(raw, &comments); err != nil {
+				it.err = fmt.Errorf("unable to decode issue comments at offset %d: %w", it.startAt+len(issues), err)
+				return false
+			}
+			if comments.Fields.Comment != nil {
+				commentTotals[issue.ID] = comments.Fields.Comment.Total
+			}
+			issues = append(issues, issue)
+		}
+		it.request.Position = response.Next
+		if len(issues) == 0 || len(response.Next) == 0 {
+			it.done = true
+		}
+		if it.skip > 0 {
+			skipped := it.skip
+			if skipped > len(issues) {
+				skipped = len(issues)
+			}
+			it.skip -= skipped
+			issues = issues[skipped:]
+			if len(issues) == 0 {
+				continue
+			}
+		}
+
+		it.page = SearchPage{
+			Issues:        issues,
+			StartAt:       it.startAt,
+			Total:         response.Total,
+			CommentTotals: commentTotals,
+		}
+		it.fetched += len(issues)
+		it.startAt += len(issues)
+		it.progress(it.fetched, response.Total)
+		return len(issues) > 0
+	}
+}
+
+// Page returns the page fetched by the last call to Next.
+func (it *SearchIterator) Page() SearchPage {
+	return it.page
+}
+
+// Err returns the first error encountered while paging.
+func (it *SearchIterator) Err() error {
+	return it.err
+}
+
+// SearchAllIssues returns every issue matching the query.
+func SearchAllIssues(ctx context.Context, backend SearchBackend, jql string, options *jiraBaseClient.SearchOptions, progress SearchProgressFunc) ([]jiraBaseClient.Issue, error) {
+	var issues []jiraBaseClient.Issue
+	it := NewSearchIterator(backend, jql, options, progress)
+	for it.Next(ctx) {
+		issues = append(issues, it.Page().Issues...)
+	}
+	return issues, it.Err()
+}
@@ -0,0 +1,276 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"github.com/openshift/ci-search/jira"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWatchOverlap is how far before the last observed change every poll of a watch searches again, to find the
+// issues that Jira indexed late or that changed within the same minute.
+const DefaultWatchOverlap = 5 * time.Minute
+
+// watchPageSize is the number of issues requested per page of a list or a poll.
+const watchPageSize = 500
+
+// WatchOptions configure the polling of NewWatchInformer.
+type WatchOptions struct {
+	// Interval is the time between two polls.
+	Interval time.Duration
+	// MaxInterval is the maximum duration of a watch, after which the issues are listed again.
+	MaxInterval time.Duration
+	// ResyncInterval is how often the informer resyncs its cache.
+	ResyncInterval time.Duration
+	// Overlap is how far before the last observed change every poll searches again. Changes that were already
+	// delivered are not delivered twice.
+	Overlap time.Duration
+}
+
+// NewWatchInformer is a replacement of jira.NewInformer whose watch does not miss or duplicate changes. The informer of
+// ci-search searches for the issues updated since the last change rounded down to the minute, in the hard-coded time
+// zone of America/New_York, and starts its watch from the clock of the local host. This watch instead:
+//
+//   - keeps its position from the update times of the issues returned by Jira, never from the local clock, so that a
+//     skewed clock neither delays nor shifts it;
+//   - searches from Overlap before the last observed change, in the time zone reported by /serverInfo, so that changes
+//     within the same minute and issues indexed late are found;
+//   - delivers every (issue ID, updated) pair once, including the ones that were listed before the watch started;
+//   - pages with the pagination of the backend, which only moves forward on Jira Cloud, so every list and poll reads
+//     each matching issue once rather than searching again from an offset.
+func NewWatchInformer(backend SearchBackend, options WatchOptions, argsFn func(metav1.ListOptions) jira.SearchIssuesArgs, includeFn func(issue *jiraBaseClient.Issue) bool) cache.SharedIndexInformer {
+	lw := NewWatchListWatcher(backend, options, argsFn, includeFn)
+	return cache.NewSharedIndexInformer(&cache.ListWatch{ListFunc: lw.List, WatchFunc: lw.Watch}, &jira.Issue{}, options.ResyncInterval, nil)
+}
+
+// WatchListWatcher lists and watches the issues of a JQL query for NewWatchInformer.
+type WatchListWatcher struct {
+	options   WatchOptions
+	argsFn    func(metav1.ListOptions) jira.SearchIssuesArgs
+	includeFn func(issue *jiraBaseClient.Issue) bool
+
+	// search returns every issue matching the arguments
+	search   func(ctx context.Context, args jira.SearchIssuesArgs) ([]jiraBaseClient.Issue, error)
+	location func(ctx context.Context) (*time.Location, error)
+
+	lock sync.Mutex
+	// seen is the last update time delivered for every issue, by the last list or a watch
+	seen map[string]time.Time
+}
+
+// NewWatchListWatcher searches the backend for the issues of the arguments. The IncludeFields of the arguments are the
+// fields requested, DefaultSearchFields when empty, and the MaxResults and StartAt of the arguments are ignored.
+func NewWatchListWatcher(backend SearchBackend, options WatchOptions, argsFn func(metav1.ListOptions) jira.SearchIssuesArgs, includeFn func(issue *jiraBaseClient.Issue) bool) *WatchListWatcher {
+	return &WatchListWatcher{
+		options:   options,
+		argsFn:    argsFn,
+		includeFn: includeFn,
+		search: func(ctx context.Context, args jira.SearchIssuesArgs) ([]jiraBaseClient.Issue, error) {
+			jql := args.Jql
+			if !args.LastChangeTime.IsZero() {
+				jql = WatchJQL(jql, args.LastChangeTime)
+			}
+			return SearchAllIssues(ctx, backend, jql, &jiraBaseClient.SearchOptions{Fields: args.IncludeFields, MaxResults: watchPageSize}, nil)
+		},
+		location: func(ctx context.Context) (*time.Location, error) {
+			info, err := GetServerInfo(ctx, backend.Client())
+			if err != nil {
+				return nil, err
+			}
+			return info.Location(), nil
+		},
+		seen: make(map[string]time.Time),
+	}
+}
+
+// List returns every issue at once, whatever the limit of the options, since the page tokens of Jira Cloud cannot be
+// resumed from the offset of a continue token.
+func (lw *WatchListWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	issues, err := lw.search(context.Background(), lw.argsFn(options))
+	if err != nil {
+		return nil, err
+	}
+	list := jira.NewIssueList(issues, lw.includeFn)
+
+	// a list replaces everything that was delivered before
+	lw.lock.Lock()
+	lw.seen = make(map[string]time.Time)
+	for _, item := range list.Items {
+		lw.seen[item.Name] = time.Time(item.Info.Fields.Updated)
+	}
+	lw.lock.Unlock()
+	klog.V(6).Infof("Listed issues total=%d items=%d", len(issues), len(list.Items))
+	return list, nil
+}
+
+func (lw *WatchListWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	var rv metav1.Time
+	if err := rv.UnmarshalQueryParameter(options.ResourceVersion); err != nil {
+		return nil, err
+	}
+	w := &overlapWatcher{
+		lw:     lw,
+		args:   lw.argsFn(options),
+		cursor: rv.Time,
+		ch:     make(chan watch.Event, 100),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+var watchOrderByPattern = regexp.MustCompile(`(?i)\s+order\s+by\s+.*$`)
+
+// WatchJQL restricts the query to the issues updated at or after since, in the time zone of since, ordered from the
+// oldest change. Dates in JQL have a precision of a minute, so since is rounded down.
+func WatchJQL(jql string, since time.Time) string {
+	jql = watchOrderByPattern.ReplaceAllString(jql, "")
+	restriction := fmt.Sprintf(`updated >= "%s"`, since.Format("2006/01/02 15:04"))
+	if len(jql) > 0 {
+		restriction = fmt.Sprintf("(%s) AND %s", jql, restriction)
+	}
+	return restriction + " ORDER BY updated ASC, key ASC"
+}
+
+// overlapWatcher polls for the issues changed since Overlap before the last change it observed.
+type overlapWatcher struct {
+	lw   *WatchListWatcher
+	args jira.SearchIssuesArgs
+	// cursor is the latest update time observed, as reported by Jira
+	cursor time.Time
+
+	ch     chan watch.Event
+	lock   sync.Mutex
+	done   chan struct{}
+	closed bool
+}
+
+func (w *overlapWatcher) run() {
+	defer klog.V(7).Infof("Watcher exited")
+	defer close(w.ch)
+
+	// never watch longer than MaxInterval
+	var expired <-chan time.Time
+	if w.lw.options.MaxInterval > 0 {
+		timer := time.NewTimer(w.lw.options.MaxInterval)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	// polls wait a full interval rather than comparing the cursor, which is a time of the server, to the local clock
+	ticker := time.NewTicker(w.lw.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-expired:
+			klog.V(5).Infof("maximum duration reached %s", w.lw.options.MaxInterval)
+			w.send(watch.Event{Type: watch.Error, Object: &errors.NewResourceExpired(fmt.Sprintf("watch closed after %s, resync required", w.lw.options.MaxInterval)).ErrStatus})
+			return
+		case <-ticker.C:
+		}
+		events, err := w.poll(context.Background())
+		if err != nil {
+			klog.Errorf("Watcher search issues error: %v", err)
+			w.send(watch.Event{Type: watch.Error, Object: &errors.NewInternalError(err).ErrStatus})
+			return
+		}
+		for _, event := range events {
+			if !w.send(event) {
+				return
+			}
+		}
+	}
+}
+
+// poll searches for the issues changed since Overlap before the cursor and returns the changes that were not delivered
+// yet, from the oldest, and advances the cursor to the latest change.
+func (w *overlapWatcher) poll(ctx context.Context) ([]watch.Event, error) {
+	location, err := w.lw.location(ctx)
+	if err != nil {
+		return nil, err
+	}
+	since := w.cursor.Add(-w.lw.options.Overlap).In(location).Truncate(time.Minute)
+
+	args := w.args
+	args.Jql = WatchJQL(args.Jql, since)
+	args.LastChangeTime = time.Time{}
+	issues, err := w.lw.search(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	list := jira.NewIssueList(issues, w.lw.includeFn)
+	sort.SliceStable(list.Items, func(i, j int) bool {
+		return time.Time(list.Items[i].Info.Fields.Updated).Before(time.Time(list.Items[j].Info.Fields.Updated))
+	})
+
+	w.lw.lock.Lock()
+	defer w.lw.lock.Unlock()
+	var events []watch.Event
+	cursor := w.cursor
+	for i := range list.Items {
+		item := &list.Items[i]
+		updated := time.Time(item.Info.Fields.Updated)
+		last, seen := w.lw.seen[item.Name]
+		if seen && !updated.After(last) {
+			continue
+		}
+		eventType := watch.Modified
+		if !seen {
+			eventType = watch.Added
+		}
+		w.lw.seen[item.Name] = updated
+		if updated.After(cursor) {
+			cursor = updated
+		}
+		events = append(events, watch.Event{Type: eventType, Object: item})
+	}
+	klog.V(5).Infof("Watch observed %d changes of %d issues updated since %s", len(events), len(list.Items), since.Format(time.RFC3339))
+	w.cursor = cursor
+	return events, nil
+}
+
+// send delivers the event unless the watch was stopped. Only run sends, so the channel is never closed under it.
+func (w *overlapWatcher) send(event watch.Event) bool {
+	select {
+	case w.ch <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *overlapWatcher) Stop() {
+	defer func() {
+		// drain the channel if stop was invoked until the channel is closed
+		for range w.ch {
+		}
+	}()
+	w.stop()
+	klog.V(7).Infof("Stopped watch")
+}
+
+func (w *overlapWatcher) stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if !w.closed {
+		close(w.done)
+		w.closed = true
+	}
+}
+
+func (w *overlapWatcher) ResultChan() <-chan watch.Event {
+	return w.ch
+}
@@ -0,0 +1,269 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	jiraBaseClient "github.com/andygrunwald/go-jira"
+	"github.com/openshift/ci-search/jira"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"net/http"
+	"reflect"
+	"regexp"
+	jiraClient "sigs.k8s.io/prow/prow/jira"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeWatchJira is a Jira whose search compares the minute precision dates of JQL in its own time zone, and whose
+// search index sees every change only once it is indexed, after its update time.
+type fakeWatchJira struct {
+	location *time.Location
+
+	lock sync.Mutex
+	// now is the clock of the server, which is unrelated to the clock of the test
+	now     time.Time
+	changes []fakeChange
+	queries []string
+}
+
+type fakeChange struct {
+	id      string
+	created time.Time
+	updated time.Time
+	indexed time.Time
+}
+
+var fakeUpdatedPattern = regexp.MustCompile(`updated >= "([^"]+)"`)
+
+// change updates an issue at the given time, and makes the change visible to searches lag later.
+func (j *fakeWatchJira) change(id string, created, updated time.Time, lag time.Duration) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.changes = append(j.changes, fakeChange{id: id, created: created, updated: updated, indexed: updated.Add(lag)})
+}
+
+func (j *fakeWatchJira) setNow(now time.Time) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.now = now
+}
+
+func (j *fakeWatchJira) search(_ context.Context, args jira.SearchIssuesArgs) ([]jiraBaseClient.Issue, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.queries = append(j.queries, args.Jql)
+	var since time.Time
+	if m := fakeUpdatedPattern.FindStringSubmatch(args.Jql); m != nil {
+		var err error
+		if since, err = time.ParseInLocation("2006/01/02 15:04", m[1], j.location); err != nil {
+			return nil, err
+		}
+	}
+	latest := make(map[string]fakeChange)
+	for _, change := range j.changes {
+		if change.indexed.After(j.now) {
+			continue
+		}
+		if current, ok := latest[change.id]; !ok || change.updated.After(current.updated) {
+			latest[change.id] = change
+		}
+	}
+	var issues []jiraBaseClient.Issue
+	for _, change := range latest {
+		if change.updated.Before(since) {
+			continue
+		}
+		issues = append(issues, jiraBaseClient.Issue{ID: change.id, Key: "OCPBUGS-" + change.id, Fields: &jiraBaseClient.IssueFields{
+			Created: jiraBaseClient.Time(change.created.In(j.location)),
+			Updated: jiraBaseClient.Time(change.updated.In(j.location)),
+		}})
+	}
+	sort.Slice(issues, func(i, k int) bool {
+		return time.Time(issues[i].Fields.Updated).Before(time.Time(issues[k].Fields.Updated))
+	})
+	return issues, nil
+}
+
+func newFakeWatchListWatcher(j *fakeWatchJira, overlap time.Duration) *WatchListWatcher {
+	return &WatchListWatcher{
+		options: WatchOptions{Interval: time.Millisecond, Overlap: overlap},
+		argsFn: func(metav1.ListOptions) jira.SearchIssuesArgs {
+			return jira.SearchIssuesArgs{Jql: "project=OCPBUGS"}
+		},
+		search: j.search,
+		location: func(context.Context) (*time.Location, error) {
+			return j.location, nil
+		},
+		seen: make(map[string]time.Time),
+	}
+}
+
+// eventsOf summarizes events as type, issue ID and update time in UTC.
+func eventsOf(events []watch.Event) []string {
+	var summary []string
+	for _, event := range events {
+		issue := event.Object.(*jira.Issue)
+		summary = append(summary, fmt.Sprintf("%s %s %s", event.Type, issue.Name, time.Time(issue.Info.Fields.Updated).UTC().Format("15:04:05")))
+	}
+	return summary
+}
+
+func TestWatchSameMinuteUpdates(t *testing.T) {
+	// the server is five and a half hours ahead of UTC, and its clock is years behind the clock of the test
+	j := &fakeWatchJira{location: time.FixedZone("+0530", 5*60*60+30*60)}
+	at := func(hour, minute, second int) time.Time {
+		return time.Date(2020, 6, 1, hour, minute, second, 0, time.UTC)
+	}
+	j.change("1", at(11, 0, 0), at(12, 0, 10), 0)
+	j.setNow(at(12, 0, 30))
+
+	lw := newFakeWatchListWatcher(j, 2*time.Minute)
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := obj.(*jira.IssueList)
+	if len(list.Items) != 1 {
+		t.Fatalf("expected one listed issue, got %d", len(list.Items))
+	}
+	watcher, err := lw.Watch(metav1.ListOptions{ResourceVersion: list.ResourceVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := watcher.(*overlapWatcher)
+	watcher.Stop()
+
+	// an update before the cursor that is indexed late, and a second update in the same minute as the cursor
+	j.change("2", at(12, 0, 20), at(12, 0, 20), 40*time.Second)
+	j.change("1", at(11, 0, 0), at(12, 0, 50), 0)
+	j.setNow(at(12, 1, 30))
+	events, err := w.poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ADDED 2 12:00:20", "MODIFIED 1 12:00:50"}
+	if actual := eventsOf(events); fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if query := j.queries[len(j.queries)-1]; query != `(project=OCPBUGS) AND updated >= "2020/06/01 17:28" ORDER BY updated ASC, key ASC` {
+		t.Errorf("expected the search to start two minutes before the listed change in the time zone of the server, got %s", query)
+	}
+
+	// the overlap returns the same changes again, which are not delivered twice
+	j.change("3", at(12, 1, 59), at(12, 1, 59), 0)
+	j.setNow(at(12, 2, 0))
+	events, err = w.poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"ADDED 3 12:01:59"}
+	if actual := eventsOf(events); fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if events, err = w.poll(context.Background()); err != nil || len(events) != 0 {
+		t.Errorf("expected no changes, got %v: %v", eventsOf(events), err)
+	}
+}
+
+func TestWatchClockSkew(t *testing.T) {
+	for _, skew := range []time.Duration{-3 * time.Hour, 0, 90 * time.Minute} {
+		t.Run(skew.String(), func(t *testing.T) {
+			// the server runs in UTC-5 and its clock is skewed from the clock of the test, which the watch must not use
+			j := &fakeWatchJira{location: time.FixedZone("-0500", -5*60*60)}
+			serverNow := time.Now().Add(skew).Truncate(time.Second)
+			j.change("1", serverNow.Add(-time.Hour), serverNow.Add(-time.Minute), 0)
+			j.setNow(serverNow)
+
+			lw := newFakeWatchListWatcher(j, time.Minute)
+			obj, err := lw.List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			watcher, err := lw.Watch(metav1.ListOptions{ResourceVersion: obj.(*jira.IssueList).ResourceVersion})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer watcher.Stop()
+
+			j.change("2", serverNow, serverNow.Add(time.Second), 0)
+			j.setNow(serverNow.Add(2 * time.Second))
+			select {
+			case event := <-watcher.ResultChan():
+				if actual := eventsOf([]watch.Event{event}); actual[0] != "ADDED 2 "+serverNow.Add(time.Second).UTC().Format("15:04:05") {
+					t.Errorf("expected issue 2 to be added, got %v", actual)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected the watch to deliver the change despite a skew of %s", skew)
+			}
+		})
+	}
+}
+
+func TestWatchListPagesForward(t *testing.T) {
+	server := newTestSearchServer(t, DeploymentCloud)
+	defer server.Close()
+	var searches int32
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/search/jql") {
+			atomic.AddInt32(&searches, 1)
+		}
+		handler.ServeHTTP(w, r)
+	})
+	client, err := jiraClient.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewSearchBackendFor(client, DeploymentCloud, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lw := NewWatchListWatcher(backend, WatchOptions{}, func(metav1.ListOptions) jira.SearchIssuesArgs {
+		return jira.SearchIssuesArgs{Jql: "project=OCPBUGS"}
+	}, nil)
+
+	// the informer asks for pages of a limit, but the page tokens of Jira Cloud cannot resume from an offset
+	obj, err := lw.List(metav1.ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := obj.(*jira.IssueList)
+	var ids []string
+	for _, item := range list.Items {
+		ids = append(ids, item.Name)
+	}
+	if expected := []string{"100", "101", "102", "103", "104", "105", "106"}; !reflect.DeepEqual(ids, expected) || len(list.Continue) > 0 {
+		t.Errorf("expected every issue in a single list, got %v continuing at %q", ids, list.Continue)
+	}
+	if searches != 3 {
+		t.Errorf("expected every page to be read once, got %d searches", searches)
+	}
+}
+
+func TestWatchJQL(t *testing.T) {
+	since := time.Date(2024, 3, 1, 12, 34, 56, 0, time.FixedZone("-0500", -5*60*60))
+	if actual, expected := WatchJQL("project=OCPBUGS ORDER BY key", since), `(project=OCPBUGS) AND updated >= "2024/03/01 12:34" ORDER BY updated ASC, key ASC`; actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestServerInfoLocation(t *testing.T) {
+	for _, tt := range []struct {
+		info     ServerInfo
+		expected string
+	}{
+		{info: ServerInfo{ServerTime: "2024-03-01T07:00:00.000-0500"}, expected: "2024-03-01 07:00 -0500"},
+		{info: ServerInfo{ServerTimeZone: "UTC", ServerTime: "2024-03-01T07:00:00.000-0500"}, expected: "2024-03-01 12:00 +0000"},
+		{info: ServerInfo{}, expected: "2024-03-01 12:00 +0000"},
+	} {
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		if actual := now.In(tt.info.Location()).Format("2006-01-02 15:04 -0700"); actual != tt.expected {
+			t.Errorf("expected %s for %#v, got %s", tt.expected, tt.info, actual)
+		}
+	}
+}
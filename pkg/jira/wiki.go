@@ -0,0 +1,455 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type wikiBlockKind int
+
+const (
+	wikiParagraph wikiBlockKind = iota
+	wikiHeading
+	wikiCode
+	wikiQuote
+	wikiList
+	wikiTable
+	wikiRule
+)
+
+type wikiListItem struct {
+	depth   int
+	ordered bool
+	text    string
+}
+
+type wikiTableRow struct {
+	header bool
+	cells  []string
+}
+
+type wikiBlock struct {
+	kind     wikiBlockKind
+	level    int
+	text     string
+	language string
+	code     string
+	items    []wikiListItem
+	rows     []wikiTableRow
+	children []wikiBlock
+}
+
+// WikiDocument is a Jira wiki markup document, such as the description or a comment of an issue on Jira Server, parsed
+// into blocks so that it can be rendered as plain text or Markdown. The content of {code} and {noformat} blocks is
+// preserved verbatim.
+type WikiDocument struct {
+	blocks []wikiBlock
+}
+
+var (
+	wikiCodeStart    = regexp.MustCompile(`^\{(code|noformat)(?::([^}]*))?\}`)
+	wikiHeadingLine  = regexp.MustCompile(`^h([1-6])\.\s*(.*)$`)
+	wikiListLine     = regexp.MustCompile(`^([*#]+|-)\s+(.*)$`)
+	wikiQuoteLine    = regexp.MustCompile(`^bq\.\s+(.*)$`)
+	wikiRuleLine     = regexp.MustCompile(`^-{4,}$`)
+	wikiPanelLine    = regexp.MustCompile(`^\{panel(?::[^}]*)?\}$`)
+	wikiQuoteMacro   = regexp.MustCompile(`^\{quote\}`)
+	wikiBlockPattern = []*regexp.Regexp{wikiCodeStart, wikiHeadingLine, wikiListLine, wikiQuoteLine, wikiRuleLine, wikiPanelLine, wikiQuoteMacro}
+)
+
+// ParseWiki parses Jira wiki markup. Markup that is not recognized is kept as text.
+func ParseWiki(text string) *WikiDocument {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	// NUL delimits the placeholders used while rendering inline markup
+	text = strings.ReplaceAll(text, "\x00", " ")
+	return &WikiDocument{blocks: parseWikiBlocks(strings.Split(text, "\n"))}
+}
+
+func parseWikiBlocks(lines []string) []wikiBlock {
+	var blocks []wikiBlock
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, wikiBlock{kind: wikiParagraph, text: strings.Join(paragraph, "\n")})
+			paragraph = nil
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case len(line) == 0:
+			flush()
+		case wikiCodeStart.MatchString(line):
+			flush()
+			var block wikiBlock
+			block, i = parseWikiCode(lines, i)
+			blocks = append(blocks, block)
+		case wikiQuoteMacro.MatchString(line):
+			flush()
+			var inner []string
+			inner, i = wikiMacroBody(lines, i, "{quote}")
+			blocks = append(blocks, wikiBlock{kind: wikiQuote, children: parseWikiBlocks(inner)})
+		case wikiPanelLine.MatchString(line):
+			// panels only add decoration, so their content is rendered in place
+			flush()
+			var inner []string
+			inner, i = wikiMacroBody(lines, i, "{panel}")
+			blocks = append(blocks, parseWikiBlocks(inner)...)
+		case wikiHeadingLine.MatchString(line):
+			flush()
+			m := wikiHeadingLine.FindStringSubmatch(line)
+			level, _ := strconv.Atoi(m[1])
+			blocks = append(blocks, wikiBlock{kind: wikiHeading, level: level, text: m[2]})
+		case wikiQuoteLine.MatchString(line):
+			flush()
+			m := wikiQuoteLine.FindStringSubmatch(line)
+			blocks = append(blocks, wikiBlock{kind: wikiQuote, children: []wikiBlock{{kind: wikiParagraph, text: m[1]}}})
+		case wikiRuleLine.MatchString(line):
+			flush()
+			blocks = append(blocks, wikiBlock{kind: wikiRule})
+		case wikiListLine.MatchString(line):
+			flush()
+			block := wikiBlock{kind: wikiList}
+			for ; i < len(lines); i++ {
+				m := wikiListLine.FindStringSubmatch(strings.TrimSpace(lines[i]))
+				if m == nil {
+					break
+				}
+				block.items = append(block.items, wikiListItem{depth: len(m[1]), ordered: strings.HasSuffix(m[1], "#"), text: m[2]})
+			}
+			i--
+			blocks = append(blocks, block)
+		case strings.HasPrefix(line, "|"):
+			flush()
+			block := wikiBlock{kind: wikiTable}
+			for ; i < len(lines); i++ {
+				row := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(row, "|") {
+					break
+				}
+				block.rows = append(block.rows, parseWikiTableRow(row))
+			}
+			i--
+			blocks = append(blocks, block)
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// parseWikiCode parses a {code} or {noformat} block starting at lines[start] and returns the index of its last line.
+// The block may open and close on the same line.
+func parseWikiCode(lines []string, start int) (wikiBlock, int) {
+	line := strings.TrimSpace(lines[start])
+	m := wikiCodeStart.FindStringSubmatch(line)
+	block := wikiBlock{kind: wikiCode}
+	if m[1] == "code" {
+		block.language = wikiCodeLanguage(m[2])
+	}
+	closing := "{" + m[1] + "}"
+	rest := line[len(m[0]):]
+	if end := strings.Index(rest, closing); end >= 0 {
+		block.code = rest[:end]
+		return block, start
+	}
+	var code []string
+	if len(strings.TrimSpace(rest)) > 0 {
+		code = append(code, rest)
+	}
+	i := start + 1
+	for ; i < len(lines); i++ {
+		if end := strings.Index(lines[i], closing); end >= 0 {
+			if len(strings.TrimSpace(lines[i][:end])) > 0 {
+				code = append(code, lines[i][:end])
+			}
+			break
+		}
+		code = append(code, lines[i])
+	}
+	block.code = strings.Join(code, "\n")
+	return block, i
+}
+
+// wikiCodeLanguage returns the language of a {code:java} or {code:language=java|title=Example} macro.
+func wikiCodeLanguage(params string) string {
+	for _, param := range strings.Split(params, "|") {
+		key, value, ok := strings.Cut(param, "=")
+		switch {
+		case !ok && len(key) > 0:
+			return strings.TrimSpace(key)
+		case key == "language" || key == "lang":
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// wikiMacroBody returns the lines between an opening macro at lines[start] and its closing tag, and the index of the
+// line holding the closing tag.
+func wikiMacroBody(lines []string, start int, closing string) ([]string, int) {
+	line := strings.TrimSpace(lines[start])
+	rest := line[strings.Index(line, "}")+1:]
+	if end := strings.Index(rest, closing); end >= 0 {
+		return []string{rest[:end]}, start
+	}
+	body := []string{rest}
+	i := start + 1
+	for ; i < len(lines); i++ {
+		if end := strings.Index(lines[i], closing); end >= 0 {
+			body = append(body, lines[i][:end])
+			break
+		}
+		body = append(body, lines[i])
+	}
+	return body, i
+}
+
+func parseWikiTableRow(row string) wikiTableRow {
+	header := strings.HasPrefix(row, "||")
+	separator := "|"
+	if header {
+		separator = "||"
+	}
+	row = strings.TrimSuffix(strings.TrimPrefix(row, separator), separator)
+	var cells []string
+	for _, cell := range strings.Split(row, separator) {
+		cells = append(cells, strings.TrimSpace(strings.Trim(cell, "|")))
+	}
+	return wikiTableRow{header: header, cells: cells}
+}
+
+// PlainText renders the document as text without markup, for full-text analysis.
+func (d *WikiDocument) PlainText() string {
+	return renderWikiBlocks(d.blocks, false)
+}
+
+// Markdown renders the document as CommonMark with GitHub flavored tables and strikethrough.
+func (d *WikiDocument) Markdown() string {
+	return renderWikiBlocks(d.blocks, true)
+}
+
+// WikiToPlainText renders Jira wiki markup as plain text.
+func WikiToPlainText(text string) string {
+	return ParseWiki(text).PlainText()
+}
+
+// WikiToMarkdown renders Jira wiki markup as Markdown.
+func WikiToMarkdown(text string) string {
+	return ParseWiki(text).Markdown()
+}
+
+func renderWikiBlocks(blocks []wikiBlock, markdown bool) string {
+	var rendered []string
+	for _, block := range blocks {
+		if s := renderWikiBlock(block, markdown); len(s) > 0 {
+			rendered = append(rendered, s)
+		}
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+func renderWikiBlock(block wikiBlock, markdown bool) string {
+	switch block.kind {
+	case wikiHeading:
+		if markdown {
+			return strings.Repeat("#", block.level) + " " + renderWikiInline(block.text, markdown)
+		}
+		return renderWikiInline(block.text, markdown)
+	case wikiCode:
+		if markdown {
+			return "```" + block.language + "\n" + block.code + "\n```"
+		}
+		return block.code
+	case wikiQuote:
+		inner := renderWikiBlocks(block.children, markdown)
+		if !markdown {
+			return inner
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return strings.Join(lines, "\n")
+	case wikiList:
+		return renderWikiList(block.items, markdown)
+	case wikiTable:
+		return renderWikiTable(block.rows, markdown)
+	case wikiRule:
+		if markdown {
+			return "---"
+		}
+		return ""
+	default:
+		text := renderWikiInline(block.text, markdown)
+		if markdown {
+			// a single newline is a soft break in Markdown, so force the line breaks that Jira renders
+			text = strings.ReplaceAll(text, "\n", "  \n")
+		}
+		return text
+	}
+}
+
+func renderWikiList(items []wikiListItem, markdown bool) string {
+	lines := make([]string, 0, len(items))
+	counters := map[int]int{}
+	for _, item := range items {
+		for depth := range counters {
+			if depth > item.depth {
+				delete(counters, depth)
+			}
+		}
+		marker := "-"
+		if item.ordered {
+			counters[item.depth]++
+			marker = fmt.Sprintf("%d.", counters[item.depth])
+		}
+		indent := strings.Repeat("  ", item.depth-1)
+		if markdown && item.ordered {
+			indent = strings.Repeat("   ", item.depth-1)
+		}
+		lines = append(lines, indent+marker+" "+renderWikiInline(item.text, markdown))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderWikiTable(rows []wikiTableRow, markdown bool) string {
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		cells := make([]string, 0, len(row.cells))
+		for _, cell := range row.cells {
+			cells = append(cells, renderWikiInline(cell, markdown))
+		}
+		if !markdown {
+			lines = append(lines, strings.Join(cells, " | "))
+			continue
+		}
+		for j := range cells {
+			cells[j] = strings.ReplaceAll(cells[j], "|", `\|`)
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		// Markdown tables always start with a header row
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", len(cells)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	wikiEscape      = regexp.MustCompile(`\\([\\*_\-+^~?{}\[\]!|#])`)
+	wikiMonospace   = regexp.MustCompile(`\{\{(.+?)\}\}`)
+	wikiColor       = regexp.MustCompile(`\{color(?::[^}]*)?\}`)
+	wikiMacro       = regexp.MustCompile(`\{(?:anchor|span|status)(?::[^}]*)?\}`)
+	wikiMention     = regexp.MustCompile(`\[~([^\]]+)\]`)
+	wikiNamedLink   = regexp.MustCompile(`\[([^|\]]*)\|([^|\]]+)(?:\|[^\]]*)?\]`)
+	wikiBareLink    = regexp.MustCompile(`\[((?:https?|ftp|file|mailto):[^\]\s]+)\]`)
+	wikiImage       = regexp.MustCompile(`!([^!\s|]+\.[A-Za-z0-9]+)(?:\|[^!]*)?!`)
+	wikiLineBreak   = regexp.MustCompile(`\\\\`)
+	wikiPlaceholder = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// wikiEffects are the inline text effects. A marker only opens after whitespace or punctuation and only closes before
+// it, so that hyphenated words and snake_case identifiers are left alone.
+var wikiEffects = []struct {
+	pattern  *regexp.Regexp
+	markdown string
+}{
+	{pattern: wikiEffect(`\*`), markdown: "**"},
+	{pattern: wikiEffect(`_`), markdown: "_"},
+	{pattern: wikiEffect(`-`), markdown: "~~"},
+	{pattern: wikiEffect(`\+`), markdown: ""},
+	{pattern: wikiEffect(`\?\?`), markdown: "_"},
+	{pattern: wikiEffect(`\^`), markdown: ""},
+	{pattern: wikiEffect(`~`), markdown: ""},
+}
+
+func wikiEffect(marker string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|[\s(\[{'">|\x00])` + marker + `([^\s` + marker + `](?:[^` + marker + `\n]*?[^\s` + marker + `])?)` + marker + `($|[\s)\]}'".,;:!?<|\x00])`)
+}
+
+// renderWikiInline renders the inline markup of a block. Monospace spans, links and escaped characters are set aside
+// as placeholders first, so that the text effects never apply inside them.
+func renderWikiInline(text string, markdown bool) string {
+	var protected []string
+	protect := func(s string) string {
+		protected = append(protected, s)
+		return fmt.Sprintf("\x00%d\x00", len(protected)-1)
+	}
+
+	text = wikiLineBreak.ReplaceAllString(text, "\n")
+	text = wikiEscape.ReplaceAllStringFunc(text, func(s string) string {
+		return protect(s[1:])
+	})
+	text = wikiMonospace.ReplaceAllStringFunc(text, func(s string) string {
+		code := wikiMonospace.FindStringSubmatch(s)[1]
+		if markdown {
+			return protect("`" + code + "`")
+		}
+		return protect(code)
+	})
+	text = wikiColor.ReplaceAllString(text, "")
+	text = wikiMacro.ReplaceAllString(text, "")
+	text = wikiMention.ReplaceAllStringFunc(text, func(s string) string {
+		return protect("@" + wikiMention.FindStringSubmatch(s)[1])
+	})
+	text = wikiNamedLink.ReplaceAllStringFunc(text, func(s string) string {
+		m := wikiNamedLink.FindStringSubmatch(s)
+		label, target := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+		switch {
+		case markdown && len(label) > 0:
+			return protect("[" + label + "](" + target + ")")
+		case markdown:
+			return protect("<" + target + ">")
+		case len(label) == 0 || label == target:
+			return protect(target)
+		default:
+			return protect(label + " (" + target + ")")
+		}
+	})
+	text = wikiBareLink.ReplaceAllStringFunc(text, func(s string) string {
+		target := wikiBareLink.FindStringSubmatch(s)[1]
+		if markdown {
+			return protect("<" + target + ">")
+		}
+		return protect(target)
+	})
+	text = wikiImage.ReplaceAllStringFunc(text, func(s string) string {
+		source := wikiImage.FindStringSubmatch(s)[1]
+		if markdown {
+			return protect("![](" + source + ")")
+		}
+		return protect(source)
+	})
+
+	for _, effect := range wikiEffects {
+		marker := ""
+		if markdown && len(effect.markdown) > 0 {
+			// the Markdown markers are protected so that they are not matched again by the effects that share them
+			marker = protect(effect.markdown)
+		}
+		// adjacent spans share the whitespace between them, so apply each effect until nothing changes
+		for {
+			replaced := effect.pattern.ReplaceAllStringFunc(text, func(s string) string {
+				m := effect.pattern.FindStringSubmatch(s)
+				return m[1] + marker + m[2] + marker + m[3]
+			})
+			if replaced == text {
+				break
+			}
+			text = replaced
+		}
+	}
+
+	// protected text may itself hold placeholders, such as an escaped character inside a link label
+	for wikiPlaceholder.MatchString(text) {
+		text = wikiPlaceholder.ReplaceAllStringFunc(text, func(s string) string {
+			i, _ := strconv.Atoi(wikiPlaceholder.FindStringSubmatch(s)[1])
+			return protected[i]
+		})
+	}
+	return text
+}
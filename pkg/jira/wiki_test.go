@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"testing"
+)
+
+func TestWiki(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		plainText string
+		markdown  string
+	}{
+		{
+			name:      "effects",
+			text:      "This is *bold*, _italic_, -deleted- and {{oc get pods}}.",
+			plainText: "This is bold, italic, deleted and oc get pods.",
+			markdown:  "This is **bold**, _italic_, ~~deleted~~ and `oc get pods`.",
+		},
+		{
+			name:      "identifiers are not effects",
+			text:      "Set cluster_profile and e2e-aws-ovn with a*b*c",
+			plainText: "Set cluster_profile and e2e-aws-ovn with a*b*c",
+			markdown:  "Set cluster_profile and e2e-aws-ovn with a*b*c",
+		},
+		{
+			name:      "nested effects",
+			text:      "*_important_*",
+			plainText: "important",
+			markdown:  "**_important_**",
+		},
+		{
+			name:      "escapes",
+			text:      `Literal \*stars\* and \{braces\}`,
+			plainText: "Literal *stars* and {braces}",
+			markdown:  "Literal *stars* and {braces}",
+		},
+		{
+			name:      "headings and paragraphs",
+			text:      "h1. Summary\nFirst line\nsecond line\n\nh3. Details",
+			plainText: "Summary\n\nFirst line\nsecond line\n\nDetails",
+			markdown:  "# Summary\n\nFirst line  \nsecond line\n\n### Details",
+		},
+		{
+			name:      "links",
+			text:      "See [the job|https://prow.ci.openshift.org/job], [https://example.com] and [~jdoe]",
+			plainText: "See the job (https://prow.ci.openshift.org/job), https://example.com and @jdoe",
+			markdown:  "See [the job](https://prow.ci.openshift.org/job), <https://example.com> and @jdoe",
+		},
+		{
+			name:      "code is preserved",
+			text:      "Run:\n{code:bash}\noc get *pods* -n _ns_\n  [not|a link]\n{code}\ndone",
+			plainText: "Run:\n\noc get *pods* -n _ns_\n  [not|a link]\n\ndone",
+			markdown:  "Run:\n\n```bash\noc get *pods* -n _ns_\n  [not|a link]\n```\n\ndone",
+		},
+		{
+			name:      "noformat on one line",
+			text:      "{noformat}level=error msg=*failed*{noformat}",
+			plainText: "level=error msg=*failed*",
+			markdown:  "```\nlevel=error msg=*failed*\n```",
+		},
+		{
+			name:      "lists",
+			text:      "* one\n** nested\n* two\n# first\n# second",
+			plainText: "- one\n  - nested\n- two\n1. first\n2. second",
+			markdown:  "- one\n  - nested\n- two\n1. first\n2. second",
+		},
+		{
+			name:      "tables",
+			text:      "||Job||Result||\n|e2e-aws|*failed*|",
+			plainText: "Job | Result\ne2e-aws | failed",
+			markdown:  "| Job | Result |\n| --- | --- |\n| e2e-aws | **failed** |",
+		},
+		{
+			name:      "quotes and panels",
+			text:      "{quote}\nquoted\n{quote}\nbq. short\n{panel:title=Note}\ninside\n{panel}",
+			plainText: "quoted\n\nshort\n\ninside",
+			markdown:  "> quoted\n\n> short\n\ninside",
+		},
+		{
+			name:      "colors and line breaks",
+			text:      `{color:red}red{color}\\next`,
+			plainText: "red\nnext",
+			markdown:  "red  \nnext",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := ParseWiki(tt.text)
+			if actual := doc.PlainText(); actual != tt.plainText {
+				t.Errorf("expected plain text\n%q\ngot\n%q", tt.plainText, actual)
+			}
+			if actual := doc.Markdown(); actual != tt.markdown {
+				t.Errorf("expected markdown\n%q\ngot\n%q", tt.markdown, actual)
+			}
+		})
+	}
+}
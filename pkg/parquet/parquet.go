@@ -0,0 +1,252 @@
+// Package parquet writes and reads Parquet files of flat, optional columns. It implements the part of the format that
+// exporting rows needs: a single uncompressed row group with one PLAIN-encoded data page per column.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ColumnType is the logical type of a column.
+type ColumnType int
+
+const (
+	// String columns hold UTF-8 strings.
+	String ColumnType = iota
+	// Bytes columns hold []byte.
+	Bytes
+	// JSON columns hold JSON documents as strings.
+	JSON
+	// Int64 columns hold int64.
+	Int64
+	// Double columns hold float64.
+	Double
+	// Boolean columns hold bool.
+	Boolean
+	// Timestamp columns hold time.Time, stored as microseconds since the Unix epoch in UTC.
+	Timestamp
+)
+
+// Column is a named, optional column of a file.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+const magic = "PAR1"
+
+// The values of the enums of the Parquet metadata that are used.
+const (
+	physicalBoolean   = 0
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+	convertedJSON            = 19
+
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+
+	pageData = 0
+)
+
+func (t ColumnType) physical() (physical int32, converted int32, hasConverted bool) {
+	switch t {
+	case String:
+		return physicalByteArray, convertedUTF8, true
+	case JSON:
+		return physicalByteArray, convertedJSON, true
+	case Bytes:
+		return physicalByteArray, 0, false
+	case Int64:
+		return physicalInt64, 0, false
+	case Double:
+		return physicalDouble, 0, false
+	case Boolean:
+		return physicalBoolean, 0, false
+	default:
+		return physicalInt64, convertedTimestampMicros, true
+	}
+}
+
+// Write writes the rows as a Parquet file. Every row holds a value per column, in the order of the columns, where nil
+// is NULL.
+func Write(w io.Writer, columns []Column, rows [][]interface{}) error {
+	if len(columns) == 0 {
+		return errors.New("a file needs at least one column")
+	}
+	out := bytes.NewBufferString(magic)
+	schema := []interface{}{
+		[]thriftField{{4, "schema"}, {5, int32(len(columns))}},
+	}
+	var chunks []interface{}
+	var totalSize int64
+	for i, column := range columns {
+		physical, converted, hasConverted := column.Type.physical()
+		element := []thriftField{{1, physical}, {3, int32(repetitionOptional)}, {4, column.Name}}
+		if hasConverted {
+			element = append(element, thriftField{6, converted})
+		}
+		schema = append(schema, element)
+
+		page, err := encodePage(column, i, rows)
+		if err != nil {
+			return err
+		}
+		header := &thriftEncoder{}
+		if err := header.writeStruct([]thriftField{
+			{1, int32(pageData)},
+			{2, int32(len(page))},
+			{3, int32(len(page))},
+			{5, []thriftField{{1, int32(len(rows))}, {2, int32(encodingPlain)}, {3, int32(encodingRLE)}, {4, int32(encodingRLE)}}},
+		}); err != nil {
+			return err
+		}
+		offset := int64(out.Len())
+		out.Write(header.buf)
+		out.Write(page)
+		size := int64(len(header.buf) + len(page))
+		totalSize += size
+		chunks = append(chunks, []thriftField{
+			{2, offset},
+			{3, []thriftField{
+				{1, physical},
+				{2, thriftList{Type: thriftI32, Values: []interface{}{int32(encodingPlain), int32(encodingRLE)}}},
+				{3, thriftList{Type: thriftBinary, Values: []interface{}{column.Name}}},
+				{4, int32(codecUncompressed)},
+				{5, int64(len(rows))},
+				{6, size},
+				{7, size},
+				{9, offset},
+			}},
+		})
+	}
+
+	footer := &thriftEncoder{}
+	if err := footer.writeStruct([]thriftField{
+		{1, int32(1)},
+		{2, thriftList{Type: thriftStruct, Values: schema}},
+		{3, int64(len(rows))},
+		{4, thriftList{Type: thriftStruct, Values: []interface{}{
+			[]thriftField{{1, thriftList{Type: thriftStruct, Values: chunks}}, {2, totalSize}, {3, int64(len(rows))}},
+		}}},
+		{6, "jira-migration"},
+	}); err != nil {
+		return err
+	}
+	out.Write(footer.buf)
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer.buf))))
+	out.WriteString(magic)
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// encodePage encodes the definition levels and the values of the column at the index of the rows.
+func encodePage(column Column, index int, rows [][]interface{}) ([]byte, error) {
+	var levels, values []byte
+	var bits []bool
+	var run int
+	var defined bool
+	flush := func() {
+		if run == 0 {
+			return
+		}
+		levels = binary.AppendUvarint(levels, uint64(run)<<1)
+		if defined {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+		run = 0
+	}
+	for i, row := range rows {
+		if len(row) != 0 && len(row) <= index {
+			return nil, fmt.Errorf("row %d has no value for column %s", i, column.Name)
+		}
+		var value interface{}
+		if len(row) > index {
+			value = row[index]
+		}
+		if (value != nil) != defined {
+			flush()
+			defined = value != nil
+		}
+		run++
+		if value == nil {
+			continue
+		}
+		var err error
+		switch column.Type {
+		case String, JSON:
+			s, ok := value.(string)
+			if !ok {
+				err = fmt.Errorf("expected a string, got %T", value)
+				break
+			}
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(s)))
+			values = append(values, s...)
+		case Bytes:
+			b, ok := value.([]byte)
+			if !ok {
+				err = fmt.Errorf("expected bytes, got %T", value)
+				break
+			}
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(b)))
+			values = append(values, b...)
+		case Int64:
+			v, ok := value.(int64)
+			if !ok {
+				err = fmt.Errorf("expected an int64, got %T", value)
+				break
+			}
+			values = binary.LittleEndian.AppendUint64(values, uint64(v))
+		case Double:
+			v, ok := value.(float64)
+			if !ok {
+				err = fmt.Errorf("expected a float64, got %T", value)
+				break
+			}
+			values = binary.LittleEndian.AppendUint64(values, math.Float64bits(v))
+		case Boolean:
+			v, ok := value.(bool)
+			if !ok {
+				err = fmt.Errorf("expected a bool, got %T", value)
+				break
+			}
+			bits = append(bits, v)
+		case Timestamp:
+			v, ok := value.(time.Time)
+			if !ok {
+				err = fmt.Errorf("expected a time, got %T", value)
+				break
+			}
+			values = binary.LittleEndian.AppendUint64(values, uint64(v.UnixMicro()))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d, column %s: %v", i, column.Name, err)
+		}
+	}
+	flush()
+	if column.Type == Boolean {
+		values = make([]byte, (len(bits)+7)/8)
+		for i, bit := range bits {
+			if bit {
+				values[i/8] |= 1 << (i % 8)
+			}
+		}
+	}
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	page = append(page, levels...)
+	return append(page, values...), nil
+}
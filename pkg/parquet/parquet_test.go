@@ -0,0 +1,93 @@
+package parquet
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriteRead(t *testing.T) {
+	created := time.Date(2024, 6, 1, 12, 30, 0, 500000000, time.UTC)
+	testCases := []struct {
+		name    string
+		columns []Column
+		rows    [][]interface{}
+	}{
+		{
+			name: "every type",
+			columns: []Column{
+				{Name: "key", Type: String},
+				{Name: "fields", Type: JSON},
+				{Name: "attachment", Type: Bytes},
+				{Name: "votes", Type: Int64},
+				{Name: "score", Type: Double},
+				{Name: "blocker", Type: Boolean},
+				{Name: "created", Type: Timestamp},
+			},
+			rows: [][]interface{}{
+				{"OCPBUGS-1", `{"labels":["a"]}`, []byte{0, 1}, int64(-3), 1.5, true, created},
+				{nil, nil, nil, nil, nil, nil, nil},
+				{"OCPBUGS-3", "{}", []byte{}, int64(1 << 40), -0.25, false, created.Add(-time.Hour)},
+			},
+		},
+		{
+			name:    "no rows",
+			columns: []Column{{Name: "key", Type: String}},
+		},
+		{
+			name:    "runs of booleans and nulls",
+			columns: []Column{{Name: "blocker", Type: Boolean}},
+			rows: func() [][]interface{} {
+				var rows [][]interface{}
+				for i := 0; i < 200; i++ {
+					switch {
+					case i%7 == 0:
+						rows = append(rows, []interface{}{nil})
+					default:
+						rows = append(rows, []interface{}{i%3 == 0})
+					}
+				}
+				return rows
+			}(),
+		},
+		{
+			name: "many columns",
+			columns: func() []Column {
+				var columns []Column
+				for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q"} {
+					columns = append(columns, Column{Name: name, Type: Int64})
+				}
+				return columns
+			}(),
+			rows: [][]interface{}{
+				{int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(7), int64(8), int64(9), int64(10), int64(11), int64(12), int64(13), int64(14), int64(15), int64(16), int64(17)},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tc.columns, tc.rows); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			columns, rows, err := Read(buf.Bytes())
+			if err != nil {
+				t.Fatalf("unable to read the file: %v", err)
+			}
+			if !reflect.DeepEqual(tc.columns, columns) {
+				t.Errorf("unexpected columns:\nexpected: %v\nactual:   %v", tc.columns, columns)
+			}
+			if !reflect.DeepEqual(tc.rows, rows) {
+				t.Errorf("unexpected rows:\nexpected: %v\nactual:   %v", tc.rows, rows)
+			}
+		})
+	}
+}
+
+func TestWriteRejectsValuesOfOtherTypes(t *testing.T) {
+	err := Write(&bytes.Buffer{}, []Column{{Name: "votes", Type: Int64}}, [][]interface{}{{"3"}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,187 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Read reads a file written by Write and returns its columns and rows, with the values typed as Write takes them. It
+// reads uncompressed, PLAIN-encoded data pages of flat columns only.
+func Read(data []byte) ([]Column, [][]interface{}, error) {
+	if len(data) < 2*len(magic)+4 || string(data[:len(magic)]) != magic || string(data[len(data)-len(magic):]) != magic {
+		return nil, nil, errors.New("not a parquet file")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-len(magic)-4:]))
+	footerStart := len(data) - len(magic) - 4 - footerLength
+	if footerStart < len(magic) {
+		return nil, nil, errors.New("invalid footer length")
+	}
+	metadata, err := newThriftDecoder(bytes.NewReader(data[footerStart:])).readStruct()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read file metadata: %v", err)
+	}
+
+	schema, _ := metadata[2].(thriftList)
+	if len(schema.Values) < 2 {
+		return nil, nil, errors.New("file has no columns")
+	}
+	var columns []Column
+	for _, value := range schema.Values[1:] {
+		element, _ := value.(map[int16]interface{})
+		column, err := columnOf(element)
+		if err != nil {
+			return nil, nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	var rows [][]interface{}
+	rowGroups, _ := metadata[4].(thriftList)
+	for _, value := range rowGroups.Values {
+		rowGroup, _ := value.(map[int16]interface{})
+		numRows, _ := rowGroup[3].(int64)
+		chunks, _ := rowGroup[1].(thriftList)
+		if len(chunks.Values) != len(columns) {
+			return nil, nil, fmt.Errorf("row group has %d columns, expected %d", len(chunks.Values), len(columns))
+		}
+		groupRows := make([][]interface{}, numRows)
+		for i := range groupRows {
+			groupRows[i] = make([]interface{}, len(columns))
+		}
+		for i, chunk := range chunks.Values {
+			meta, _ := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+			offset, _ := meta[9].(int64)
+			if err := readChunk(data, offset, columns[i], i, groupRows); err != nil {
+				return nil, nil, fmt.Errorf("column %s: %v", columns[i].Name, err)
+			}
+		}
+		rows = append(rows, groupRows...)
+	}
+	return columns, rows, nil
+}
+
+func columnOf(element map[int16]interface{}) (Column, error) {
+	name, _ := element[4].(string)
+	if repetition, _ := element[3].(int32); repetition != repetitionOptional {
+		return Column{}, fmt.Errorf("column %s is not optional", name)
+	}
+	physical, _ := element[1].(int32)
+	converted, hasConverted := element[6].(int32)
+	for _, t := range []ColumnType{String, Bytes, JSON, Int64, Double, Boolean, Timestamp} {
+		p, c, h := t.physical()
+		if p == physical && h == hasConverted && (!h || c == converted) {
+			return Column{Name: name, Type: t}, nil
+		}
+	}
+	return Column{}, fmt.Errorf("column %s has an unsupported type", name)
+}
+
+// readChunk reads the data pages of a column chunk into the column at the index of the rows.
+func readChunk(data []byte, offset int64, column Column, index int, rows [][]interface{}) error {
+	row := 0
+	for row < len(rows) {
+		if offset < 0 || offset >= int64(len(data)) {
+			return errors.New("page offset out of range")
+		}
+		reader := bytes.NewReader(data[offset:])
+		header, err := newThriftDecoder(reader).readStruct()
+		if err != nil {
+			return fmt.Errorf("unable to read page header: %v", err)
+		}
+		start := offset + int64(len(data[offset:])-reader.Len())
+		size, _ := header[3].(int32)
+		if pageType, _ := header[1].(int32); pageType != pageData {
+			return fmt.Errorf("unsupported page type %d", pageType)
+		}
+		dataHeader, _ := header[5].(map[int16]interface{})
+		numValues, _ := dataHeader[1].(int32)
+		if start+int64(size) > int64(len(data)) || row+int(numValues) > len(rows) {
+			return errors.New("page out of range")
+		}
+		if err := decodePage(data[start:start+int64(size)], column, index, rows[row:row+int(numValues)]); err != nil {
+			return err
+		}
+		row += int(numValues)
+		offset = start + int64(size)
+	}
+	return nil
+}
+
+func decodePage(page []byte, column Column, index int, rows [][]interface{}) error {
+	if len(page) < 4 {
+		return errors.New("page too short")
+	}
+	length := int(binary.LittleEndian.Uint32(page))
+	if 4+length > len(page) {
+		return errors.New("definition levels out of range")
+	}
+	levels := bytes.NewReader(page[4 : 4+length])
+	values := page[4+length:]
+	defined := make([]bool, 0, len(rows))
+	for levels.Len() > 0 {
+		header, err := binary.ReadUvarint(levels)
+		if err != nil {
+			return err
+		}
+		if header&1 != 0 {
+			return errors.New("bit-packed definition levels are not supported")
+		}
+		level, err := levels.ReadByte()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < header>>1; i++ {
+			defined = append(defined, level == 1)
+		}
+	}
+	if len(defined) != len(rows) {
+		return fmt.Errorf("expected %d definition levels, got %d", len(rows), len(defined))
+	}
+
+	var bit int
+	for i, isDefined := range defined {
+		if !isDefined {
+			continue
+		}
+		var value interface{}
+		switch column.Type {
+		case String, JSON, Bytes:
+			if len(values) < 4 || 4+int(binary.LittleEndian.Uint32(values)) > len(values) {
+				return errors.New("values out of range")
+			}
+			n := int(binary.LittleEndian.Uint32(values))
+			if column.Type == Bytes {
+				value = append(make([]byte, 0, n), values[4:4+n]...)
+			} else {
+				value = string(values[4 : 4+n])
+			}
+			values = values[4+n:]
+		case Boolean:
+			if bit/8 >= len(values) {
+				return errors.New("values out of range")
+			}
+			value = values[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		default:
+			if len(values) < 8 {
+				return errors.New("values out of range")
+			}
+			v := binary.LittleEndian.Uint64(values)
+			switch column.Type {
+			case Int64:
+				value = int64(v)
+			case Double:
+				value = math.Float64frombits(v)
+			default:
+				value = time.UnixMicro(int64(v)).UTC()
+			}
+			values = values[8:]
+		}
+		rows[i][index] = value
+	}
+	return nil
+}
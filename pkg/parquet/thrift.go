@@ -0,0 +1,221 @@
+package parquet
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The types of the Thrift compact protocol that the Parquet metadata uses.
+const (
+	thriftBooleanTrue  = 1
+	thriftBooleanFalse = 2
+	thriftI32          = 5
+	thriftI64          = 6
+	thriftBinary       = 8
+	thriftListType     = 9
+	thriftStruct       = 12
+)
+
+// thriftField is a field of a Thrift struct. Value is an int32, an int64, a string, a bool, a []thriftField for a
+// struct or a thriftList.
+type thriftField struct {
+	ID    int16
+	Value interface{}
+}
+
+// thriftList is a list of values of the same Thrift type.
+type thriftList struct {
+	Type   byte
+	Values []interface{}
+}
+
+// thriftEncoder writes structs with the Thrift compact protocol, in which the file and page metadata of Parquet is
+// encoded.
+type thriftEncoder struct {
+	buf []byte
+}
+
+func (e *thriftEncoder) writeStruct(fields []thriftField) error {
+	var last int16
+	for _, field := range fields {
+		if field.Value == nil {
+			continue
+		}
+		fieldType, err := thriftType(field.Value)
+		if err != nil {
+			return fmt.Errorf("field %d: %w", field.ID, err)
+		}
+		if delta := field.ID - last; delta > 0 && delta <= 15 {
+			e.buf = append(e.buf, byte(delta)<<4|fieldType)
+		} else {
+			e.buf = append(e.buf, fieldType)
+			e.buf = binary.AppendVarint(e.buf, int64(field.ID))
+		}
+		last = field.ID
+		if fieldType == thriftBooleanTrue || fieldType == thriftBooleanFalse {
+			continue
+		}
+		if err := e.writeValue(field.Value); err != nil {
+			return fmt.Errorf("field %d: %w", field.ID, err)
+		}
+	}
+	e.buf = append(e.buf, 0)
+	return nil
+}
+
+func (e *thriftEncoder) writeValue(value interface{}) error {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			e.buf = append(e.buf, thriftBooleanTrue)
+		} else {
+			e.buf = append(e.buf, thriftBooleanFalse)
+		}
+	case int32:
+		e.buf = binary.AppendVarint(e.buf, int64(v))
+	case int64:
+		e.buf = binary.AppendVarint(e.buf, v)
+	case string:
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	case []thriftField:
+		return e.writeStruct(v)
+	case thriftList:
+		if len(v.Values) < 15 {
+			e.buf = append(e.buf, byte(len(v.Values))<<4|v.Type)
+		} else {
+			e.buf = append(e.buf, 0xf0|v.Type)
+			e.buf = binary.AppendUvarint(e.buf, uint64(len(v.Values)))
+		}
+		for _, item := range v.Values {
+			if err := e.writeValue(item); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported thrift value %T", value)
+	}
+	return nil
+}
+
+func thriftType(value interface{}) (byte, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return thriftBooleanTrue, nil
+		}
+		return thriftBooleanFalse, nil
+	case int32:
+		return thriftI32, nil
+	case int64:
+		return thriftI64, nil
+	case string:
+		return thriftBinary, nil
+	case []thriftField:
+		return thriftStruct, nil
+	case thriftList:
+		return thriftListType, nil
+	default:
+		return 0, fmt.Errorf("unsupported thrift value %T", value)
+	}
+}
+
+// thriftDecoder reads structs written with the Thrift compact protocol. Fields of types that Parquet does not use in
+// the metadata read here are rejected rather than skipped.
+type thriftDecoder struct {
+	r io.ByteReader
+}
+
+func newThriftDecoder(r io.Reader) *thriftDecoder {
+	if br, ok := r.(io.ByteReader); ok {
+		return &thriftDecoder{r: br}
+	}
+	return &thriftDecoder{r: bufio.NewReader(r)}
+}
+
+// readStruct returns the fields of the next struct by ID.
+func (d *thriftDecoder) readStruct() (map[int16]interface{}, error) {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		header, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+		fieldType := header & 0x0f
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			v, err := binary.ReadVarint(d.r)
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+		switch fieldType {
+		case thriftBooleanTrue:
+			fields[id] = true
+		case thriftBooleanFalse:
+			fields[id] = false
+		default:
+			if fields[id], err = d.readValue(fieldType); err != nil {
+				return nil, fmt.Errorf("field %d: %w", id, err)
+			}
+		}
+	}
+}
+
+func (d *thriftDecoder) readValue(valueType byte) (interface{}, error) {
+	switch valueType {
+	case thriftBooleanTrue, thriftBooleanFalse:
+		b, err := d.r.ReadByte()
+		return b == thriftBooleanTrue, err
+	case thriftI32:
+		v, err := binary.ReadVarint(d.r)
+		return int32(v), err
+	case thriftI64:
+		return binary.ReadVarint(d.r)
+	case thriftBinary:
+		n, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		for i := range b {
+			if b[i], err = d.r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		return string(b), nil
+	case thriftStruct:
+		return d.readStruct()
+	case thriftListType:
+		header, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = binary.ReadUvarint(d.r); err != nil {
+				return nil, err
+			}
+		}
+		list := thriftList{Type: header & 0x0f}
+		for i := uint64(0); i < size; i++ {
+			item, err := d.readValue(list.Type)
+			if err != nil {
+				return nil, err
+			}
+			list.Values = append(list.Values, item)
+		}
+		return list, nil
+	default:
+		return nil, errors.New("unsupported thrift type " + fmt.Sprint(valueType))
+	}
+}